@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	service2 "sms-service/internal/service"
+	"sort"
+	"time"
+)
+
+// runActions приводит target'ы и execution'ы Actions V2 в Zitadel к YAML-конфигурации.
+// Без -apply только печатает план
+func runActions(args []string) {
+	flags := flag.NewFlagSet("actions", flag.ExitOnError)
+	configPath := flags.String("f", "actions.yaml", "path to actions config")
+	apply := flags.Bool("apply", false, "apply the plan (default: print plan only)")
+	flags.Parse(args)
+
+	config, err := service2.LoadActionsConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load actions config: %v", err)
+	}
+
+	zitadelService, err := service2.NewZitadelService()
+	if err != nil {
+		log.Fatalf("Failed to initialize Zitadel service: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	provisioner := service2.NewActionsProvisioner(zitadelService)
+
+	plan, err := provisioner.Plan(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to build actions plan: %v", err)
+	}
+
+	if plan.Empty() {
+		fmt.Println("Actions are up to date, nothing to do")
+		return
+	}
+
+	fmt.Println("Actions plan:")
+	for _, change := range plan.Changes {
+		fmt.Println("  " + change.String())
+	}
+
+	if !*apply {
+		fmt.Println("\nRun with -apply to apply these changes")
+		return
+	}
+
+	signingKeys, err := provisioner.Apply(ctx, plan)
+	printSigningKeys(signingKeys)
+	if err != nil {
+		log.Fatalf("Failed to apply actions plan: %v", err)
+	}
+
+	fmt.Printf("\nApplied %d changes\n", len(plan.Changes))
+}
+
+// printSigningKeys печатает ключи подписи новых target'ов - Zitadel отдает их только при создании
+func printSigningKeys(keys map[string]string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "\nSigning keys of created targets (add them to ZITADEL_SIGNING_KEYS):")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s=%s\n", name, keys[name])
+	}
+}
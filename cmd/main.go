@@ -2,8 +2,13 @@ package main
 
 import (
 	"log"
+	"os"
 	"sms-service/internal/delivery"
+	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
+	"sms-service/internal/phone"
 	service2 "sms-service/internal/service"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -19,6 +24,26 @@ func main() {
 		log.Println("Environment variables loaded from .env file")
 	}
 
+	// Подкоманда: sms-service actions [-f actions.yaml] [-apply]
+	if len(os.Args) > 1 && os.Args[1] == "actions" {
+		runActions(os.Args[2:])
+		return
+	}
+
+	// Код страны для номеров, введенных в национальном формате ("8 912 ...")
+	if country := os.Getenv("PHONE_DEFAULT_COUNTRY"); country != "" {
+		if err := phone.SetDefaultCountry(country); err != nil {
+			log.Fatalf("Invalid PHONE_DEFAULT_COUNTRY: %v", err)
+		}
+	}
+
+	// Язык сообщений для клиентов без Accept-Language и поля locale
+	if locale := os.Getenv("I18N_DEFAULT_LOCALE"); locale != "" {
+		if err := i18n.SetDefaultLocale(locale); err != nil {
+			log.Fatalf("Invalid I18N_DEFAULT_LOCALE: %v", err)
+		}
+	}
+
 	zitadelService, err := service2.NewZitadelService()
 	if err != nil {
 		log.Fatalf("Failed to initialize Zitadel service: %v", err)
@@ -29,11 +54,100 @@ func main() {
 		log.Fatalf("Failed to initialize OIDC service: %v", err)
 	}
 
-	otpStore := service2.NewOTPStore()
-	authHandler := delivery.NewAuthHandler(oidcService, zitadelService, otpStore)
-	tokenHandler := delivery.NewTokenHandler(oidcService)
+	introspectionCache, introspectionPolicy, err := service2.NewIntrospectionCache()
+	if err != nil {
+		log.Fatalf("Failed to initialize introspection cache: %v", err)
+	}
+	if introspectionCache != nil {
+		oidcService.SetIntrospectionCache(introspectionCache, introspectionPolicy)
+	}
+
+	tokenRevocations, err := service2.NewTokenRevocations()
+	if err != nil {
+		log.Fatalf("Failed to initialize token revocation list: %v", err)
+	}
+	oidcService.SetTokenRevocations(tokenRevocations)
+
+	smsRouter, err := service2.NewSMSRouter()
+	if err != nil {
+		log.Fatalf("Failed to initialize SMS router: %v", err)
+	}
+
+	otpStore, err := service2.NewOTPStore()
+	if err != nil {
+		log.Fatalf("Failed to initialize OTP store: %v", err)
+	}
+	smsRouter.SetTracker(otpStore)
+
+	sendPolicy, err := service2.NewOTPSendPolicy()
+	if err != nil {
+		log.Fatalf("Failed to load OTP send policy: %v", err)
+	}
+
+	rateLimiter, err := service2.NewRateLimiter()
+	if err != nil {
+		log.Fatalf("Failed to initialize rate limiter: %v", err)
+	}
+
+	webhookVerifier, err := service2.NewWebhookVerifier()
+	if err != nil {
+		log.Fatalf("Failed to load Zitadel webhook signing keys: %v", err)
+	}
+	if err := webhookVerifier.RequireTargets("pre-registration", "post-create-user", "events"); err != nil {
+		log.Fatalf("Zitadel webhooks are not protected: %v", err)
+	}
+
+	eventDedup, err := service2.NewEventDeduplicator()
+	if err != nil {
+		log.Fatalf("Failed to initialize event deduplication: %v", err)
+	}
+	eventDispatcher := service2.NewEventDispatcher(eventDedup)
+	service2.RegisterDefaultEventHandlers(eventDispatcher)
+
+	responseMutators := service2.NewResponseMutatorRegistry()
+	responseMutators.Register(domain.ZitadelMethodCreateUser, service2.NewCustomerIDMutator(zitadelService, nil))
+
+	phonePolicy, err := service2.NewPhonePolicy()
+	if err != nil {
+		log.Fatalf("Failed to load phone policy: %v", err)
+	}
+
+	refreshTokenStore, err := service2.NewRefreshTokenStore()
+	if err != nil {
+		log.Fatalf("Failed to initialize refresh token store: %v", err)
+	}
+	refreshTokens := service2.NewRefreshTokenRotator(oidcService, refreshTokenStore)
+
+	logoutService := service2.NewLogoutService(oidcService, zitadelService, refreshTokens)
+
+	authHandler := delivery.NewAuthHandler(oidcService, zitadelService, otpStore, smsRouter, sendPolicy, phonePolicy, refreshTokens)
+	tokenHandler := delivery.NewTokenHandler(oidcService, refreshTokens, logoutService)
+	smsHandler, err := delivery.NewSMSHandler(smsRouter)
+	if err != nil {
+		log.Fatalf("Failed to initialize SMS handler: %v", err)
+	}
+	zitadelHandler := delivery.NewZitadelHandler(phonePolicy, eventDispatcher, responseMutators)
+
+	// За балансировщиком реальный IP клиента берется из заголовка PROXY_HEADER, но только
+	// в запросах от адресов TRUSTED_PROXIES (IP или CIDR через запятую): иначе клиент
+	// подставлял бы любой IP и получал новый лимит на каждый запрос. Берется первый валидный
+	// IP заголовка, поэтому прокси должен перезаписывать его, а не дописывать (X-Real-IP)
+	proxyHeader := os.Getenv("PROXY_HEADER")
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if proxyHeader != "" && len(trustedProxies) == 0 {
+		log.Fatalf("TRUSTED_PROXIES is required when PROXY_HEADER is set")
+	}
 
 	app := fiber.New(fiber.Config{
+		ProxyHeader:             proxyHeader,
+		EnableTrustedProxyCheck: proxyHeader != "",
+		TrustedProxies:          trustedProxies,
+		EnableIPValidation:      true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -52,16 +166,47 @@ func main() {
 		AllowCredentials: true,
 	}))
 
+	rateLimit := func(route, defaults string) fiber.Handler {
+		rules, err := service2.LoadRateLimitRules(route, defaults)
+		if err != nil {
+			log.Fatalf("Failed to load rate limits for %s: %v", route, err)
+		}
+		return delivery.RateLimitMiddleware(rateLimiter, route, rules)
+	}
+
+	sendOTPLimits := "ip=sliding_window:10/1m;subnet=sliding_window:100/1h;phone=token_bucket:5/10m"
+	verifyOTPLimits := "ip=sliding_window:20/1m;subnet=sliding_window:200/1h;phone=sliding_window:10/10m"
+
 	// Регистрация
-	app.Post("/api/auth/register/send-otp", authHandler.RegisterSendOTP)
-	app.Post("/api/auth/register/verify-otp", authHandler.RegisterVerifyOTP)
+	app.Post("/api/auth/register/send-otp", rateLimit("register_send_otp", sendOTPLimits), authHandler.RegisterSendOTP)
+	app.Post("/api/auth/register/verify-otp", rateLimit("register_verify_otp", verifyOTPLimits), authHandler.RegisterVerifyOTP)
 
 	// Логин
-	app.Post("/api/auth/login/send-otp", authHandler.SendOTP)
-	app.Post("/api/auth/login/verify-otp", authHandler.VerifyOTP)
+	app.Post("/api/auth/login/send-otp", rateLimit("login_send_otp", sendOTPLimits), authHandler.SendOTP)
+	app.Post("/api/auth/login/verify-otp", rateLimit("login_verify_otp", verifyOTPLimits), authHandler.VerifyOTP)
+
+	// Статус доставки OTP
+	app.Get("/api/auth/otp/status", rateLimit("otp_status", "ip=token_bucket:30/1m"), authHandler.OTPStatus)
+	if smsHandler.DLREnabled() {
+		// DLR приходят с адресов провайдеров пачками, лимит защищает хранилище от подделок с чужих адресов
+		app.Post("/api/sms/dlr/:provider", rateLimit("sms_dlr", "ip=sliding_window:600/1m"), smsHandler.DeliveryReceipt)
+	} else {
+		log.Printf("DLR callback disabled (SMS_DLR_CALLBACK=false)")
+	}
+
+	// Actions V2 webhook'и Zitadel
+	zitadel := app.Group("/api/zitadel", delivery.WebhookSignatureMiddleware(webhookVerifier, "/api/zitadel"))
+	zitadel.Post("/pre-registration", zitadelHandler.PreRegistration)
+	zitadel.Post("/post-create-user", zitadelHandler.PostCreateUser)
+	zitadel.Post("/events", zitadelHandler.Events)
 
 	// Проверка токена
-	app.Post("/api/auth/verify-token", tokenHandler.VerifyToken)
+	app.Post("/api/auth/verify-token", rateLimit("verify_token", "ip=token_bucket:60/1m"), tokenHandler.VerifyToken)
+	app.Post("/api/auth/refresh", rateLimit("refresh", "ip=sliding_window:30/1m"), tokenHandler.Refresh)
+
+	// Выход
+	app.Post("/api/auth/logout", rateLimit("logout", "ip=sliding_window:30/1m"), tokenHandler.Logout)
+	app.Post("/api/auth/logout/all", rateLimit("logout_all", "ip=sliding_window:10/1m"), tokenHandler.LogoutEverywhere)
 
 	log.Fatal(app.Listen(":2222"))
 }
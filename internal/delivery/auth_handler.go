@@ -1,10 +1,15 @@
 package delivery
 
 import (
+	"errors"
 	"log"
+	"math"
 	"os"
 	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
+	"sms-service/internal/phone"
 	"sms-service/internal/service"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -12,32 +17,44 @@ import (
 type AuthHandler struct {
 	oidcService    *service.OIDCService
 	zitadelService *service.ZitadelService
-	otpStore       *service.OTPStore
+	otpStore       service.OTPStore
+	smsSender      service.SMSSender
+	sendPolicy     *service.OTPSendPolicy
+	phonePolicy    *service.PhonePolicy
+	refreshTokens  *service.RefreshTokenRotator
+	returnCode     bool // вернуть OTP код в ответе (только для dev/test)
 }
 
 func NewAuthHandler(
 	oidcService *service.OIDCService,
 	zitadelService *service.ZitadelService,
-	otpStore *service.OTPStore,
+	otpStore service.OTPStore,
+	smsSender service.SMSSender,
+	sendPolicy *service.OTPSendPolicy,
+	phonePolicy *service.PhonePolicy,
+	refreshTokens *service.RefreshTokenRotator,
 ) *AuthHandler {
 	return &AuthHandler{
 		oidcService:    oidcService,
 		zitadelService: zitadelService,
 		otpStore:       otpStore,
+		smsSender:      smsSender,
+		sendPolicy:     sendPolicy,
+		phonePolicy:    phonePolicy,
+		refreshTokens:  refreshTokens,
+		returnCode:     os.Getenv("OTP_RETURN_CODE") == "true",
 	}
 }
 
 // POST /api/auth/login/send-otp
 func (h *AuthHandler) SendOTP(c *fiber.Ctx) error {
-	var req domain.LoginSendOTPRequest
-
-	if err := c.BodyParser(&req); err != nil {
-		log.Printf("Failed to parse SendOTP request: %v", err)
-		return respondBadRequest(c, "Invalid request body")
+	req, err := bindBody[domain.LoginSendOTPRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
 	}
 
-	if req.Phone == "" {
-		return respondBadRequest(c, domain.ErrPhoneRequired.Error())
+	if req.Phone, err = phone.Normalize(req.Phone); err != nil {
+		return respondDomainError(c, err)
 	}
 
 	log.Printf("OTP request for phone: %s", req.Phone)
@@ -46,26 +63,42 @@ func (h *AuthHandler) SendOTP(c *fiber.Ctx) error {
 	userID, err := h.zitadelService.FindUserByPhone(c.Context(), req.Phone)
 	if err != nil {
 		log.Printf("User not found for phone %s: %v", req.Phone, err)
-		return respondBadRequest(c, "User with this phone number not found")
+		return respondDomainError(c, err)
 	}
 
-	// Генерируем OTP код
-	code, err := h.otpStore.GenerateOTP(req.Phone)
+	// Проверяем паузу между отправками и квоты на номер
+	reservation, err := h.otpStore.ReserveSend(c.Context(), req.Phone, h.sendPolicy)
 	if err != nil {
-		log.Printf("Failed to generate OTP for %s: %v", req.Phone, err)
-		return respondInternalError(c, "Failed to generate OTP code", err.Error())
+		return respondReserveSendError(c, req.Phone, err)
 	}
 
-	log.Printf("OTP generated for %s: %s (user_exists=%v, user_id=%s)",
-		req.Phone, code, userID)
+	// Код сохраняется только после отправки: при сбое провайдера остается действующим предыдущий
+	otpKey := service.OTPKey{Purpose: domain.OTPPurposeLogin, Phone: req.Phone}
+	code := service.NewOTPCode()
+
+	loc := localizer(c)
+	sms, err := h.smsSender.Send(c.Context(), req.Phone, service.OTPMessageText(otpKey.Purpose, loc.Locale(), code))
+	if err != nil {
+		log.Printf("Failed to send OTP SMS to %s: %v", req.Phone, err)
+		h.releaseSend(c, reservation)
+		return respondInternalError(c, i18n.MsgOTPSendFailed, err)
+	}
+
+	// SMS уже отправлена, поэтому квота не возвращается
+	if err := h.otpStore.SaveOTP(c.Context(), service.OTPIssue{Key: otpKey, Nonce: req.Nonce, Code: code, Locale: loc.Locale(), SMS: sms}); err != nil {
+		log.Printf("Failed to save OTP for %s: %v", req.Phone, err)
+		return respondInternalError(c, i18n.MsgOTPGenerateFailed, err)
+	}
 
-	// TODO: В production отправить SMS через SMS-провайдера
-	// smsService.Send(req.Phone, fmt.Sprintf("Your verification code: %s", code))
+	log.Printf("OTP sent to %s (user_id=%s)", req.Phone, userID)
 
 	response := domain.LoginSendOTPResponse{
-		Success: true,
-		Message: "OTP code sent successfully",
-		Code:    code, // В production убрать
+		Success:    true,
+		Message:    loc.T(i18n.MsgOTPSent),
+		RetryAfter: retryAfterSeconds(reservation.RetryAfter),
+	}
+	if h.returnCode {
+		response.Code = code
 	}
 
 	return respondOK(c, response)
@@ -74,36 +107,39 @@ func (h *AuthHandler) SendOTP(c *fiber.Ctx) error {
 // VerifyOTP проверяет OTP и возвращает OAuth токены
 // POST /api/auth/login/verify-otp
 func (h *AuthHandler) VerifyOTP(c *fiber.Ctx) error {
-	var req domain.LoginVerifyOTPRequest
-
-	if err := c.BodyParser(&req); err != nil {
-		log.Printf("Failed to parse VerifyOTP request: %v", err)
-		return respondBadRequest(c, "Invalid request body")
+	req, err := bindBody[domain.LoginVerifyOTPRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
 	}
 
-	if req.Phone == "" || req.Code == "" {
-		return respondBadRequest(c, "Phone and code are required")
+	if req.Phone, err = phone.Normalize(req.Phone); err != nil {
+		return respondDomainError(c, err)
 	}
 
 	log.Printf("OTP verification attempt for phone: %s", req.Phone)
 
 	// Проверяем OTP код
-	if err := h.otpStore.VerifyOTP(req.Phone, req.Code); err != nil {
+	otpKey := service.OTPKey{Purpose: domain.OTPPurposeLogin, Phone: req.Phone}
+	if err := h.otpStore.VerifyOTP(c.Context(), otpKey, req.Code, req.Nonce); err != nil {
 		log.Printf("OTP verification failed for %s: %v", req.Phone, err)
-		return respondBadRequest(c, err.Error())
+		return respondDomainError(c, err)
 	}
 
 	log.Printf("OTP verified successfully for %s", req.Phone)
 
-	userID, err := h.zitadelService.FindUserByPhone(c.Context(), req.Phone)
+	user, err := h.zitadelService.LookupUserByPhone(c.Context(), req.Phone)
 	if err != nil {
-		return respondBadRequest(c, err.Error())
+		return respondDomainError(c, err)
 	}
+	userID := user.UserID
+
+	// Владение номером подтверждено, старый username можно привести к E.164
+	h.zitadelService.MigrateUsername(c.Context(), user)
 
 	actorToken := os.Getenv("ACCES_TOKEN_SERVICE_ACCOUNT")
 	if actorToken == "" {
 		log.Printf("ACCES_TOKEN_SERVICE_ACCOUNT not set, cannot perform Token Exchange")
-		return respondInternalError(c, "Failed to ACCES_TOKEN_SERVICE_ACCOUNT", err.Error()) // nil dereference
+		return respondInternalError(c, i18n.MsgTokenExchangeFailed, nil)
 	}
 
 	// Обмениваем user ID на OAuth токены через Token Exchange с impersonation
@@ -113,7 +149,18 @@ func (h *AuthHandler) VerifyOTP(c *fiber.Ctx) error {
 	// 3. Service account правами impersonation
 	tokens, err := h.oidcService.ExchangeUserIDForTokens(c.Context(), userID, actorToken)
 	if err != nil {
-		return respondInternalError(c, "Failed ExchangeUserIDForTokens", err.Error())
+		return respondInternalError(c, i18n.MsgTokenExchangeFailed, err)
+	}
+
+	// Начинаем цепочку ротации refresh token'а для /api/auth/refresh.
+	// Неучтенный токен нельзя ни обменять, ни отозвать при выходе, поэтому он отзывается
+	// в Zitadel и не отдается клиенту: вход проходит, но без refresh token'а
+	if err := h.refreshTokens.Track(c.Context(), userID, tokens); err != nil {
+		log.Printf("Failed to track refresh token for user %s, omitting it: %v", userID, err)
+		if revokeErr := h.oidcService.RevokeToken(c.Context(), tokens.RefreshToken, service.TokenTypeHintRefreshToken); revokeErr != nil {
+			log.Printf("Failed to revoke untracked refresh token for user %s: %v", userID, revokeErr)
+		}
+		tokens.RefreshToken = ""
 	}
 
 	response := domain.LoginVerifyOTPResponse{
@@ -131,41 +178,69 @@ func (h *AuthHandler) VerifyOTP(c *fiber.Ctx) error {
 
 // POST /api/auth/register/send-otp
 func (h *AuthHandler) RegisterSendOTP(c *fiber.Ctx) error {
-	var req domain.LoginSendOTPRequest
-
-	if err := c.BodyParser(&req); err != nil {
-		log.Printf("Failed to parse RegisterSendOTP request: %v", err)
-		return respondBadRequest(c, "Invalid request body")
+	req, err := bindBody[domain.LoginSendOTPRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
 	}
 
-	if req.Phone == "" {
-		return respondBadRequest(c, domain.ErrPhoneRequired.Error())
+	if req.Phone, err = phone.Normalize(req.Phone); err != nil {
+		return respondDomainError(c, err)
 	}
 
 	log.Printf("Registration OTP request for phone: %s", req.Phone)
 
-	_, err := h.zitadelService.FindUserByPhone(c.Context(), req.Phone)
+	// Та же политика номеров, что и в pre-registration webhook Zitadel
+	if err := h.phonePolicy.Check(req.Phone); err != nil {
+		log.Printf("Registration rejected for %s: %v", req.Phone, err)
+		return respondDomainError(c, err)
+	}
+
+	_, err = h.zitadelService.FindUserByPhone(c.Context(), req.Phone)
 	if err == nil {
 		log.Printf("User already exists with phone %s", req.Phone)
-		return respondBadRequest(c, "User with this phone number already exists")
+		return respondDomainError(c, domain.ErrUserAlreadyExists)
+	}
+	if errors.Is(err, domain.ErrUserAmbiguous) {
+		return respondDomainError(c, err)
+	}
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		log.Printf("Failed to look up user by phone %s: %v", req.Phone, err)
+		return respondInternalError(c, i18n.MsgUserLookupFailed, err)
 	}
 
-	// Генерируем OTP код
-	code, err := h.otpStore.GenerateOTP(req.Phone)
+	// Проверяем паузу между отправками и квоты на номер
+	reservation, err := h.otpStore.ReserveSend(c.Context(), req.Phone, h.sendPolicy)
 	if err != nil {
-		log.Printf("Failed to generate OTP for %s: %v", req.Phone, err)
-		return respondInternalError(c, "Failed to generate OTP code", err.Error())
+		return respondReserveSendError(c, req.Phone, err)
 	}
 
-	log.Printf("Registration OTP generated for %s: %s", req.Phone, code)
+	// Код сохраняется только после отправки: при сбое провайдера остается действующим предыдущий
+	otpKey := service.OTPKey{Purpose: domain.OTPPurposeRegister, Phone: req.Phone}
+	code := service.NewOTPCode()
 
-	// TODO: В production отправить SMS через SMS-провайдера
-	// smsService.Send(req.Phone, fmt.Sprintf("Your registration code: %s", code))
+	loc := localizer(c)
+	sms, err := h.smsSender.Send(c.Context(), req.Phone, service.OTPMessageText(otpKey.Purpose, loc.Locale(), code))
+	if err != nil {
+		log.Printf("Failed to send registration OTP SMS to %s: %v", req.Phone, err)
+		h.releaseSend(c, reservation)
+		return respondInternalError(c, i18n.MsgOTPSendFailed, err)
+	}
+
+	// SMS уже отправлена, поэтому квота не возвращается
+	if err := h.otpStore.SaveOTP(c.Context(), service.OTPIssue{Key: otpKey, Nonce: req.Nonce, Code: code, Locale: loc.Locale(), SMS: sms}); err != nil {
+		log.Printf("Failed to save registration OTP for %s: %v", req.Phone, err)
+		return respondInternalError(c, i18n.MsgOTPGenerateFailed, err)
+	}
+
+	log.Printf("Registration OTP sent to %s", req.Phone)
 
 	response := domain.LoginSendOTPResponse{
-		Success: true,
-		Message: "Registration OTP code sent successfully",
-		Code:    code, // В проде убрать
+		Success:    true,
+		Message:    loc.T(i18n.MsgRegisterOTPSent),
+		RetryAfter: retryAfterSeconds(reservation.RetryAfter),
+	}
+	if h.returnCode {
+		response.Code = code
 	}
 
 	return respondOK(c, response)
@@ -174,21 +249,20 @@ func (h *AuthHandler) RegisterSendOTP(c *fiber.Ctx) error {
 // RegisterVerifyOTP проверяет OTP и создает нового пользователя
 // POST /api/auth/register/verify-otp
 func (h *AuthHandler) RegisterVerifyOTP(c *fiber.Ctx) error {
-	var req domain.LoginVerifyOTPRequest
-
-	if err := c.BodyParser(&req); err != nil {
-		log.Printf("Failed to parse RegisterVerifyOTP request: %v", err)
-		return respondBadRequest(c, "Invalid request body")
+	req, err := bindBody[domain.LoginVerifyOTPRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
 	}
 
-	if req.Phone == "" || req.Code == "" {
-		return respondBadRequest(c, "Phone and code are required")
+	if req.Phone, err = phone.Normalize(req.Phone); err != nil {
+		return respondDomainError(c, err)
 	}
 
 	// Проверяем OTP код
-	if err := h.otpStore.VerifyOTP(req.Phone, req.Code); err != nil {
+	otpKey := service.OTPKey{Purpose: domain.OTPPurposeRegister, Phone: req.Phone}
+	if err := h.otpStore.VerifyOTP(c.Context(), otpKey, req.Code, req.Nonce); err != nil {
 		log.Printf("Registration OTP verification failed for %s: %v", req.Phone, err)
-		return respondBadRequest(c, err.Error())
+		return respondDomainError(c, err)
 	}
 
 	log.Printf("Registration OTP verified successfully for %s", req.Phone)
@@ -197,23 +271,87 @@ func (h *AuthHandler) RegisterVerifyOTP(c *fiber.Ctx) error {
 	existingUserID, err := h.zitadelService.FindUserByPhone(c.Context(), req.Phone)
 	if err == nil {
 		log.Printf("User already exists with phone %s, userID=%s", req.Phone, existingUserID)
-		return respondBadRequest(c, "User with this phone number already exists")
+		return respondDomainError(c, domain.ErrUserAlreadyExists)
+	}
+	if errors.Is(err, domain.ErrUserAmbiguous) {
+		return respondDomainError(c, err)
+	}
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		log.Printf("Failed to look up user by phone %s: %v", req.Phone, err)
+		return respondInternalError(c, i18n.MsgUserLookupFailed, err)
 	}
 
 	// Создаем нового пользователя
 	createResp, err := h.zitadelService.CreateUserByPhone(c.Context(), req.Phone)
 	if err != nil {
 		log.Printf("Failed to create user for %s: %v", req.Phone, err)
-		return respondInternalError(c, "Failed to create user", err.Error())
+		return respondInternalError(c, i18n.MsgUserCreateFailed, err)
 	}
 
 	log.Printf("User created successfully: UserID=%s, Phone=%s", createResp.UserID, req.Phone)
 
 	response := map[string]interface{}{
 		"success": true,
-		"message": "Registration successful",
+		"message": localizer(c).T(i18n.MsgRegistrationSuccessful),
 		"user_id": createResp.UserID,
 	}
 
 	return respondOK(c, response)
 }
+
+// OTPStatus возвращает статус доставки последнего OTP кода
+// GET /api/auth/otp/status?phone=+79991234567&purpose=login&nonce=...
+func (h *AuthHandler) OTPStatus(c *fiber.Ctx) error {
+	req, err := bindQuery[domain.OTPStatusRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	phoneNumber, err := phone.Normalize(req.Phone)
+	if err != nil {
+		return respondDomainError(c, err)
+	}
+	purpose := domain.OTPPurpose(req.Purpose)
+
+	info, err := h.otpStore.GetDeliveryStatus(c.Context(), service.OTPKey{Purpose: purpose, Phone: phoneNumber}, req.Nonce)
+	if err != nil {
+		return respondDomainError(c, err)
+	}
+
+	response := domain.OTPStatusResponse{
+		Success:   true,
+		Phone:     phoneNumber,
+		Purpose:   purpose,
+		Status:    info.Status,
+		Provider:  info.Provider,
+		UpdatedAt: info.UpdatedAt,
+		ExpiresAt: info.ExpiresAt,
+	}
+
+	return respondOK(c, response)
+}
+
+// releaseSend возвращает квоту отправок, если код не удалось создать или отправить
+func (h *AuthHandler) releaseSend(c *fiber.Ctx, reservation *service.OTPSendReservation) {
+	if err := h.otpStore.ReleaseSend(c.Context(), reservation); err != nil {
+		log.Printf("Failed to release OTP send reservation for %s: %v", reservation.Phone, err)
+	}
+}
+
+// respondReserveSendError - отправка кода запрещена политикой (429 с retry_after) или ошибка хранилища
+func respondReserveSendError(c *fiber.Ctx, phone string, err error) error {
+	var throttled *service.OTPThrottleError
+	if !errors.As(err, &throttled) {
+		log.Printf("Failed to check OTP send limits for %s: %v", phone, err)
+		return respondInternalError(c, i18n.MsgOTPLimitsCheckFailed, err)
+	}
+
+	log.Printf("OTP send throttled for %s: %v", phone, throttled)
+
+	return respondDomainError(c, throttled)
+}
+
+// retryAfterSeconds округляет ожидание вверх до целых секунд
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
@@ -0,0 +1,164 @@
+package delivery
+
+import (
+	"errors"
+	"log"
+	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
+	"sms-service/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Стабильные коды ошибок API - клиенты ветвятся по ним, а не по тексту.
+// Они же - ключи каталога i18n для текста ошибки
+const (
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeValidationFailed = "validation_failed"
+	CodeRateLimited      = "rate_limited"
+	CodeInternalError    = "internal_error"
+
+	CodeOTPInvalid          = "otp_invalid"
+	CodeOTPExpired          = "otp_expired"
+	CodeOTPAttemptsExceeded = "otp_attempts_exceeded"
+	CodeOTPNotFound         = "otp_not_found"
+	CodeOTPWrongPurpose     = "otp_wrong_purpose"
+	CodeOTPResendCooldown   = "otp_resend_cooldown"
+	CodeOTPSendLimit        = "otp_send_limit"
+
+	CodeUserNotFound  = "user_not_found"
+	CodeUserExists    = "user_exists"
+	CodeUserAmbiguous = "user_ambiguous"
+
+	CodePhoneRequired       = "phone_required"
+	CodePhoneInvalid        = "phone_invalid"
+	CodePhoneNotAllowed     = "phone_not_allowed"
+	CodePhoneTypeNotAllowed = "phone_type_not_allowed"
+	CodePhoneBlacklisted    = "phone_blacklisted"
+
+	CodeTokenMissing   = "token_missing"
+	CodeTokenMalformed = "token_malformed"
+	CodeTokenInvalid   = "token_invalid"
+	CodeTokenInactive  = "token_inactive"
+
+	CodeRefreshTokenInvalid = "refresh_token_invalid"
+	CodeRefreshTokenReused  = "refresh_token_reused"
+	CodeRefreshTokenRevoked = "refresh_token_revoked"
+
+	CodeSignatureMissing = "signature_missing"
+	CodeSignatureInvalid = "signature_invalid"
+	CodeSignatureExpired = "signature_expired"
+)
+
+// apiError - HTTP статус и код для доменной ошибки
+type apiError struct {
+	err    error
+	status int
+	code   string
+}
+
+// apiErrors сопоставляет доменные ошибки с ответами API; порядок важен только для вложенных ошибок
+var apiErrors = []apiError{
+	{domain.ErrInvalidOTP, fiber.StatusUnauthorized, CodeOTPInvalid},
+	{domain.ErrOTPExpired, fiber.StatusGone, CodeOTPExpired},
+	{domain.ErrOTPMaxAttempts, fiber.StatusTooManyRequests, CodeOTPAttemptsExceeded},
+	{domain.ErrOTPNotFound, fiber.StatusNotFound, CodeOTPNotFound},
+	{domain.ErrOTPWrongPurpose, fiber.StatusBadRequest, CodeOTPWrongPurpose},
+	{domain.ErrOTPResendCooldown, fiber.StatusTooManyRequests, CodeOTPResendCooldown},
+	{domain.ErrOTPSendLimit, fiber.StatusTooManyRequests, CodeOTPSendLimit},
+
+	{domain.ErrUserNotFound, fiber.StatusNotFound, CodeUserNotFound},
+	{domain.ErrUserAlreadyExists, fiber.StatusConflict, CodeUserExists},
+	{domain.ErrUserAmbiguous, fiber.StatusConflict, CodeUserAmbiguous},
+
+	{domain.ErrPhoneRequired, fiber.StatusBadRequest, CodePhoneRequired},
+	{domain.ErrPhoneNotFound, fiber.StatusBadRequest, CodePhoneRequired},
+	{domain.ErrInvalidPhone, fiber.StatusBadRequest, CodePhoneInvalid},
+	{domain.ErrPhoneNotAllowed, fiber.StatusForbidden, CodePhoneNotAllowed},
+	{domain.ErrPhoneTypeNotAllowed, fiber.StatusForbidden, CodePhoneTypeNotAllowed},
+	{domain.ErrPhoneBlacklisted, fiber.StatusForbidden, CodePhoneBlacklisted},
+
+	{domain.ErrRateLimited, fiber.StatusTooManyRequests, CodeRateLimited},
+
+	{domain.ErrTokenRequired, fiber.StatusUnauthorized, CodeTokenMissing},
+	{domain.ErrTokenMalformed, fiber.StatusUnauthorized, CodeTokenMalformed},
+	{domain.ErrTokenInactive, fiber.StatusUnauthorized, CodeTokenInactive},
+	{domain.ErrRefreshTokenInvalid, fiber.StatusUnauthorized, CodeRefreshTokenInvalid},
+	{domain.ErrRefreshTokenReused, fiber.StatusUnauthorized, CodeRefreshTokenReused},
+	{domain.ErrRefreshTokenRevoked, fiber.StatusUnauthorized, CodeRefreshTokenRevoked},
+
+	{domain.ErrSignatureMissing, fiber.StatusUnauthorized, CodeSignatureMissing},
+	{domain.ErrSignatureInvalid, fiber.StatusUnauthorized, CodeSignatureInvalid},
+	{domain.ErrSignatureExpired, fiber.StatusUnauthorized, CodeSignatureExpired},
+}
+
+// lookupAPIError находит статус и код доменной ошибки; ok=false - ошибка неизвестна
+func lookupAPIError(err error) (apiError, bool) {
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			return e, true
+		}
+	}
+	return apiError{}, false
+}
+
+// statusCode - общий код ошибки по HTTP статусу, если доменного нет
+func statusCode(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	default:
+		return CodeInternalError
+	}
+}
+
+// respondDomainError отвечает статусом и кодом доменной ошибки.
+// Для неверного OTP добавляет число оставшихся попыток, для ограничений отправки - время ожидания.
+// Неизвестные ошибки отдаются как 500 internal_error.
+func respondDomainError(c *fiber.Ctx, err error) error {
+	mapped, ok := lookupAPIError(err)
+	if !ok {
+		return respondInternalError(c, i18n.MsgInternalError, err)
+	}
+
+	resp := ErrorResponse{
+		Error: localizer(c).T(mapped.code),
+		Code:  mapped.code,
+	}
+	// Текст вложенной ошибки не переведен и может раскрывать внутренности - только в лог
+	if msg := err.Error(); msg != mapped.err.Error() {
+		log.Printf("%s %s: %s: %s", c.Method(), c.Path(), mapped.code, msg)
+	}
+
+	var attemptErr *service.OTPAttemptError
+	if errors.As(err, &attemptErr) {
+		remaining := attemptErr.Remaining
+		resp.AttemptsRemaining = &remaining
+	}
+	if errors.Is(err, domain.ErrOTPMaxAttempts) {
+		remaining := 0
+		resp.AttemptsRemaining = &remaining
+	}
+
+	var throttled *service.OTPThrottleError
+	if errors.As(err, &throttled) {
+		resp.RetryAfter = retryAfterSeconds(throttled.RetryAfter)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resp.RetryAfter))
+	}
+
+	return c.Status(mapped.status).JSON(resp)
+}
@@ -0,0 +1,116 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
+	"sms-service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRespondDomainError(t *testing.T) {
+	attempts := func(n int) *int { return &n }
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		attempts   *int
+		retryAfter string
+	}{
+		{"otp invalid", &service.OTPAttemptError{Remaining: 2}, fiber.StatusUnauthorized, CodeOTPInvalid, attempts(2), ""},
+		{"otp max attempts", domain.ErrOTPMaxAttempts, fiber.StatusTooManyRequests, CodeOTPAttemptsExceeded, attempts(0), ""},
+		{"otp expired", domain.ErrOTPExpired, fiber.StatusGone, CodeOTPExpired, nil, ""},
+		{"otp not found", domain.ErrOTPNotFound, fiber.StatusNotFound, CodeOTPNotFound, nil, ""},
+		{"otp wrong purpose", domain.ErrOTPWrongPurpose, fiber.StatusBadRequest, CodeOTPWrongPurpose, nil, ""},
+		{"resend cooldown", &service.OTPThrottleError{Err: domain.ErrOTPResendCooldown, RetryAfter: 1500 * time.Millisecond}, fiber.StatusTooManyRequests, CodeOTPResendCooldown, nil, "2"},
+		{"send limit", &service.OTPThrottleError{Err: domain.ErrOTPSendLimit, RetryAfter: time.Hour}, fiber.StatusTooManyRequests, CodeOTPSendLimit, nil, "3600"},
+		{"user exists", domain.ErrUserAlreadyExists, fiber.StatusConflict, CodeUserExists, nil, ""},
+		{"phone not found maps to required", domain.ErrPhoneNotFound, fiber.StatusBadRequest, CodePhoneRequired, nil, ""},
+		{"wrapped phone error", fmt.Errorf("%w: country +1", domain.ErrPhoneNotAllowed), fiber.StatusForbidden, CodePhoneNotAllowed, nil, ""},
+		{"refresh token reused", domain.ErrRefreshTokenReused, fiber.StatusUnauthorized, CodeRefreshTokenReused, nil, ""},
+		{"signature expired", domain.ErrSignatureExpired, fiber.StatusUnauthorized, CodeSignatureExpired, nil, ""},
+		{"unknown error", errors.New("connection refused"), fiber.StatusInternalServerError, CodeInternalError, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return respondDomainError(c, tt.err)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderRetryAfter); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+
+			var body ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("code = %s, want %s", body.Code, tt.code)
+			}
+			if body.Error != i18n.New().T(tt.code) {
+				t.Errorf("error = %q, want catalog text for %s", body.Error, tt.code)
+			}
+			if body.Details != "" {
+				t.Errorf("details = %q, want none", body.Details)
+			}
+			if (body.AttemptsRemaining == nil) != (tt.attempts == nil) ||
+				(tt.attempts != nil && *body.AttemptsRemaining != *tt.attempts) {
+				t.Errorf("attempts_remaining = %v, want %v", body.AttemptsRemaining, tt.attempts)
+			}
+		})
+	}
+}
+
+// TestAPIErrorCodesTranslated - текст ошибки берется из каталога по коду, поэтому код обязан в нем быть
+func TestAPIErrorCodesTranslated(t *testing.T) {
+	codes := []string{CodeBadRequest, CodeUnauthorized, CodeForbidden, CodeNotFound, CodeValidationFailed, CodeRateLimited, CodeInternalError}
+	for _, e := range apiErrors {
+		codes = append(codes, e.code)
+	}
+
+	for _, locale := range []string{i18n.English, i18n.Russian} {
+		loc := i18n.New(locale)
+		for _, code := range codes {
+			if loc.T(code) == code {
+				t.Errorf("%s: no catalog entry for error code %s", locale, code)
+			}
+		}
+	}
+}
+
+func TestLookupAPIErrorUnwraps(t *testing.T) {
+	for _, e := range apiErrors {
+		wrapped := fmt.Errorf("context: %w", e.err)
+		mapped, ok := lookupAPIError(wrapped)
+		if !ok {
+			t.Errorf("%v: not mapped", e.err)
+			continue
+		}
+		// Первое совпадение по errors.Is должно быть той же ошибкой, иначе порядок apiErrors перекрывает запись
+		if mapped.err != e.err && mapped.code != e.code {
+			t.Errorf("%v: mapped to %s, want %s", e.err, mapped.code, e.code)
+		}
+	}
+
+	if _, ok := lookupAPIError(errors.New("unknown")); ok {
+		t.Error("unknown error was mapped")
+	}
+}
@@ -0,0 +1,40 @@
+package delivery
+
+import (
+	"sms-service/internal/i18n"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// localizerKey - ключ Localizer'а запроса в c.Locals
+const localizerKey = "localizer"
+
+// localeRequest - запрос с полем locale, которое важнее заголовка Accept-Language
+type localeRequest interface {
+	RequestLocale() string
+}
+
+// localizer возвращает Localizer запроса; без явной локали язык берется из Accept-Language
+func localizer(c *fiber.Ctx) *i18n.Localizer {
+	if loc, ok := c.Locals(localizerKey).(*i18n.Localizer); ok {
+		return loc
+	}
+	return setLocale(c, i18n.ParseAcceptLanguage(c.Get(fiber.HeaderAcceptLanguage))...)
+}
+
+// useRequestLocale ставит локаль из поля запроса первой в цепочку, Accept-Language остается запасным
+func useRequestLocale(c *fiber.Ctx, req any) {
+	r, ok := req.(localeRequest)
+	if !ok || r.RequestLocale() == "" {
+		return
+	}
+	preferred := append([]string{r.RequestLocale()}, i18n.ParseAcceptLanguage(c.Get(fiber.HeaderAcceptLanguage))...)
+	setLocale(c, preferred...)
+}
+
+func setLocale(c *fiber.Ctx, preferred ...string) *i18n.Localizer {
+	loc := i18n.New(preferred...)
+	c.Locals(localizerKey, loc)
+	c.Set(fiber.HeaderContentLanguage, loc.Locale())
+	return loc
+}
@@ -0,0 +1,123 @@
+package delivery
+
+import (
+	"log"
+	"net"
+	"sms-service/internal/phone"
+	"sms-service/internal/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// invalidBucket - общий ключ для номеров и адресов, которые не удалось разобрать:
+// произвольные строки не должны создавать по ключу лимитера на каждую, а правило - пропускаться
+const invalidBucket = "invalid"
+
+// RateLimitMiddleware ограничивает частоту запросов к маршруту по IP, подсети и номеру телефона.
+// Правила проверяются по порядку, каждое расходует свой лимит; на первом превышенном
+// запрос отклоняется, и следующие правила его уже не учитывают.
+// Если лимитер недоступен, запрос пропускается (fail-open), чтобы сбой Redis не блокировал вход.
+func RateLimitMiddleware(limiter service.RateLimiter, route string, rules []service.RateLimitRule) fiber.Handler {
+	if len(rules) == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		var (
+			tightest *service.RateLimitResult
+			denied   *service.RateLimitResult
+		)
+
+		for _, rule := range rules {
+			value := rateLimitKeyValue(c, rule.Scope)
+			if value == "" {
+				continue
+			}
+
+			key := route + ":" + rule.Scope + ":" + value
+			result, err := limiter.Allow(c.Context(), key, rule)
+			if err != nil {
+				log.Printf("Rate limit check failed for %s: %v", key, err)
+				continue
+			}
+
+			if !result.Allowed {
+				log.Printf("Rate limit exceeded: route=%s %s=%s", route, rule.Scope, value)
+				denied = result
+				break
+			}
+
+			if tightest == nil || result.Remaining < tightest.Remaining {
+				tightest = result
+			}
+		}
+
+		if denied != nil {
+			c.Set("X-RateLimit-Limit", strconv.Itoa(denied.Limit))
+			c.Set("X-RateLimit-Remaining", "0")
+			seconds := retryAfterSeconds(denied.RetryAfter)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+			return c.Status(fiber.StatusTooManyRequests).JSON(ErrorResponse{
+				Error:      localizer(c).T(CodeRateLimited),
+				Code:       CodeRateLimited,
+				RetryAfter: seconds,
+			})
+		}
+
+		if tightest != nil {
+			c.Set("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+			c.Set("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		}
+
+		return c.Next()
+	}
+}
+
+// rateLimitKeyValue возвращает значение ключа для измерения scope, пустая строка - измерение неприменимо
+func rateLimitKeyValue(c *fiber.Ctx, scope string) string {
+	switch scope {
+	case service.RateLimitScopeIP:
+		ip := net.ParseIP(c.IP())
+		if ip == nil {
+			return invalidBucket
+		}
+		return ip.String()
+	case service.RateLimitScopeSubnet:
+		return clientSubnet(c.IP())
+	case service.RateLimitScopePhone:
+		var body struct {
+			Phone string `json:"phone"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Phone == "" {
+			body.Phone = c.Query("phone")
+		}
+		if body.Phone == "" {
+			return ""
+		}
+		// Разные записи одного номера должны попадать в один лимит
+		normalized, err := phone.Normalize(body.Phone)
+		if err != nil {
+			return invalidBucket
+		}
+		return normalized
+	default:
+		return ""
+	}
+}
+
+// clientSubnet - /24 для IPv4 и /64 для IPv6
+func clientSubnet(raw string) string {
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return invalidBucket
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
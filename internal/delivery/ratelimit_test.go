@@ -0,0 +1,119 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sms-service/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeRateLimiter отвечает заданным результатом по измерению правила и запоминает ключи
+type fakeRateLimiter struct {
+	results map[string]*service.RateLimitResult
+	err     error
+	keys    []string
+}
+
+func (l *fakeRateLimiter) Allow(ctx context.Context, key string, rule service.RateLimitRule) (*service.RateLimitResult, error) {
+	l.keys = append(l.keys, key)
+	if l.err != nil {
+		return nil, l.err
+	}
+	if result, ok := l.results[rule.Scope]; ok {
+		return result, nil
+	}
+	return &service.RateLimitResult{Allowed: true, Limit: rule.Limit, Remaining: rule.Limit - 1}, nil
+}
+
+var testRateLimitRules = []service.RateLimitRule{
+	{Scope: service.RateLimitScopeIP, Algorithm: service.RateLimitSlidingWindow, Limit: 20, Window: time.Minute},
+	{Scope: service.RateLimitScopePhone, Algorithm: service.RateLimitTokenBucket, Limit: 5, Window: 10 * time.Minute},
+	{Scope: service.RateLimitScopeSubnet, Algorithm: service.RateLimitSlidingWindow, Limit: 100, Window: time.Hour},
+}
+
+func newRateLimitedApp(limiter service.RateLimiter) *fiber.App {
+	app := fiber.New()
+	app.Post("/send-otp", RateLimitMiddleware(limiter, "send-otp", testRateLimitRules), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+func sendOTPRequest(t *testing.T, app *fiber.App, body string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(fiber.MethodPost, "/send-otp", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	return resp
+}
+
+func TestRateLimitMiddlewareDenies(t *testing.T) {
+	limiter := &fakeRateLimiter{results: map[string]*service.RateLimitResult{
+		service.RateLimitScopePhone: {Allowed: false, Limit: 5, RetryAfter: 1500 * time.Millisecond},
+	}}
+	resp := sendOTPRequest(t, newRateLimitedApp(limiter), `{"phone":"8 (999) 123-45-67"}`)
+
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if got := resp.Header.Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+
+	var body ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Code != CodeRateLimited || body.RetryAfter != 2 {
+		t.Errorf("body = %+v, want code %s, retry_after 2", body, CodeRateLimited)
+	}
+
+	// Номер нормализуется, а правило после превышенного не расходует лимит
+	want := []string{"send-otp:ip:0.0.0.0", "send-otp:phone:+79991234567"}
+	if strings.Join(limiter.keys, " ") != strings.Join(want, " ") {
+		t.Errorf("keys = %v, want %v", limiter.keys, want)
+	}
+}
+
+func TestRateLimitMiddlewareAllows(t *testing.T) {
+	limiter := &fakeRateLimiter{results: map[string]*service.RateLimitResult{
+		service.RateLimitScopePhone: {Allowed: true, Limit: 5, Remaining: 1},
+	}}
+	resp := sendOTPRequest(t, newRateLimitedApp(limiter), `{"phone":"not a phone"}`)
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-RateLimit-Limit") + "/" + resp.Header.Get("X-RateLimit-Remaining"); got != "5/1" {
+		t.Errorf("X-RateLimit limit/remaining = %s, want tightest 5/1", got)
+	}
+	if limiter.keys[1] != "send-otp:phone:"+invalidBucket {
+		t.Errorf("unparsable phone key = %s, want %s bucket", limiter.keys[1], invalidBucket)
+	}
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	limiter := &fakeRateLimiter{err: errors.New("redis unavailable")}
+	resp := sendOTPRequest(t, newRateLimitedApp(limiter), `{"phone":"+79991234567"}`)
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200 when limiter fails", resp.StatusCode)
+	}
+	if len(limiter.keys) != len(testRateLimitRules) {
+		t.Errorf("checked %d rules, want %d", len(limiter.keys), len(testRateLimitRules))
+	}
+}
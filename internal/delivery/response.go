@@ -1,44 +1,53 @@
 package delivery
 
 import (
+	"log"
+
 	"github.com/gofiber/fiber/v2"
 )
 
 // ErrorResponse - стандартный формат ошибки
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Details string `json:"details,omitempty"`
+	Error   string       `json:"error"`
+	Code    string       `json:"code"` // стабильный машиночитаемый код, см. errors.go
+	Details string       `json:"details,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"` // ошибки проверки по полям (422)
+
+	AttemptsRemaining *int `json:"attempts_remaining,omitempty"` // оставшиеся попытки ввода OTP
+	RetryAfter        int  `json:"retry_after,omitempty"`        // через сколько секунд можно повторить
 }
 
-// respondWithError - вспомогательная функция для отправки ошибок
-func respondWithError(c *fiber.Ctx, status int, message string, details ...string) error {
-	resp := ErrorResponse{
-		Error: message,
-	}
-	if len(details) > 0 {
-		resp.Details = details[0]
-	}
-	return c.Status(status).JSON(resp)
+// respondWithError - вспомогательная функция для отправки ошибок, код берется по статусу.
+// message - ключ каталога i18n, args - аргументы его шаблона; текст, которого нет в каталоге, отдается как есть
+func respondWithError(c *fiber.Ctx, status int, message string, args ...any) error {
+	return c.Status(status).JSON(ErrorResponse{
+		Error: localizer(c).T(message, args...),
+		Code:  statusCode(status),
+	})
 }
 
 // respondBadRequest - ошибка валидации (400)
-func respondBadRequest(c *fiber.Ctx, message string) error {
-	return respondWithError(c, fiber.StatusBadRequest, message)
+func respondBadRequest(c *fiber.Ctx, message string, args ...any) error {
+	return respondWithError(c, fiber.StatusBadRequest, message, args...)
 }
 
 // respondUnauthorized - ошибка авторизации (401)
-func respondUnauthorized(c *fiber.Ctx, message string) error {
-	return respondWithError(c, fiber.StatusUnauthorized, message)
+func respondUnauthorized(c *fiber.Ctx, message string, args ...any) error {
+	return respondWithError(c, fiber.StatusUnauthorized, message, args...)
 }
 
 // respondForbidden - доступ запрещен (403)
-func respondForbidden(c *fiber.Ctx, message string) error {
-	return respondWithError(c, fiber.StatusForbidden, message)
+func respondForbidden(c *fiber.Ctx, message string, args ...any) error {
+	return respondWithError(c, fiber.StatusForbidden, message, args...)
 }
 
-// respondInternalError - внутренняя ошибка (500)
-func respondInternalError(c *fiber.Ctx, message string, details string) error {
-	return respondWithError(c, fiber.StatusInternalServerError, message, details)
+// respondInternalError - внутренняя ошибка (500). Причина только логируется:
+// в ней бывают ответы Zitadel и детали инфраструктуры, клиенту отдается лишь код
+func respondInternalError(c *fiber.Ctx, message string, err error) error {
+	if err != nil {
+		log.Printf("%s %s: %s: %v", c.Method(), c.Path(), message, err)
+	}
+	return respondWithError(c, fiber.StatusInternalServerError, message)
 }
 
 // respondSuccess - успешный ответ с данными
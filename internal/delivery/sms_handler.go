@@ -0,0 +1,81 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"os"
+	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
+	"sms-service/internal/service"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SMSHandler struct {
+	smsRouter  *service.SMSRouter
+	dlrEnabled bool
+	dlrToken   string // общий секрет для DLR callback от провайдера
+}
+
+// NewSMSHandler настраивает DLR callback. Он включен, пока не задан SMS_DLR_CALLBACK=false,
+// и тогда требует SMS_DLR_TOKEN: без него любой мог бы подделывать отчеты о доставке
+func NewSMSHandler(smsRouter *service.SMSRouter) (*SMSHandler, error) {
+	handler := &SMSHandler{
+		smsRouter:  smsRouter,
+		dlrEnabled: os.Getenv("SMS_DLR_CALLBACK") != "false",
+		dlrToken:   os.Getenv("SMS_DLR_TOKEN"),
+	}
+
+	if handler.dlrEnabled && handler.dlrToken == "" {
+		return nil, errors.New("SMS_DLR_TOKEN is required for the DLR callback (set SMS_DLR_CALLBACK=false to disable it)")
+	}
+
+	return handler, nil
+}
+
+// DLREnabled - нужно ли регистрировать маршрут DLR callback
+func (h *SMSHandler) DLREnabled() bool {
+	return h.dlrEnabled
+}
+
+// DeliveryReceipt принимает отчет о доставке SMS от провайдера, указанного в пути:
+// message ID уникален только в пределах провайдера.
+// Токен принимается только из заголовка X-DLR-Token, чтобы не попадать в логи запросов
+// POST /api/sms/dlr/:provider
+func (h *SMSHandler) DeliveryReceipt(c *fiber.Ctx) error {
+	token := c.Get("X-DLR-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.dlrToken)) != 1 {
+		log.Printf("DLR callback rejected: invalid token")
+		return respondUnauthorized(c, i18n.MsgDLRTokenInvalid)
+	}
+
+	provider := c.Params("provider")
+	if !h.smsRouter.HasProvider(provider) {
+		return respondBadRequest(c, i18n.MsgUnknownSMSProvider, provider)
+	}
+
+	req, err := bindBody[domain.DeliveryReceiptRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	status, ok := domain.ParseDeliveryStatus(req.Status)
+	if !ok {
+		return respondBadRequest(c, i18n.MsgUnknownDeliveryStatus, req.Status)
+	}
+
+	log.Printf("DLR received: provider=%s message_id=%s status=%s error_code=%s", provider, req.MessageID, req.Status, req.ErrorCode)
+
+	h.smsRouter.HandleReceipt(service.DeliveryReceipt{
+		Provider:   provider,
+		MessageID:  req.MessageID,
+		Status:     status,
+		Stat:       req.Status,
+		ErrorCode:  req.ErrorCode,
+		ReceivedAt: time.Now(),
+	})
+
+	return respondOK(c, fiber.Map{"success": true})
+}
@@ -1,55 +1,98 @@
 package delivery
 
 import (
+	"errors"
 	"log"
+	"sms-service/internal/i18n"
 	"strings"
 
+	"sms-service/internal/domain"
 	"sms-service/internal/service"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type TokenHandler struct {
-	oidcService *service.OIDCService
+	oidcService   *service.OIDCService
+	refreshTokens *service.RefreshTokenRotator
+	logout        *service.LogoutService
 }
 
-func NewTokenHandler(oidcService *service.OIDCService) *TokenHandler {
+func NewTokenHandler(
+	oidcService *service.OIDCService,
+	refreshTokens *service.RefreshTokenRotator,
+	logout *service.LogoutService,
+) *TokenHandler {
 	return &TokenHandler{
-		oidcService: oidcService,
+		oidcService:   oidcService,
+		refreshTokens: refreshTokens,
+		logout:        logout,
 	}
 }
 
+// Refresh обменивает refresh token на новые токены с ротацией.
+// Повторное использование уже обмененного токена отзывает всю цепочку, нужен новый вход по OTP
+// POST /api/auth/refresh
+func (h *TokenHandler) Refresh(c *fiber.Ctx) error {
+	req, err := bindBody[domain.RefreshTokenRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	tokens, err := h.refreshTokens.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		log.Printf("Token refresh failed: %v", err)
+		return respondDomainError(c, err)
+	}
+
+	response := domain.RefreshTokenResponse{
+		Success:      true,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		TokenType:    tokens.TokenType,
+	}
+	if response.RefreshToken == "" {
+		// Без ротации клиент продолжает использовать прежний refresh token
+		response.RefreshToken = req.RefreshToken
+	}
+
+	return respondOK(c, response)
+}
+
 // VerifyToken проверяет валидность токена
 // POST /api/auth/verify-token
 func (h *TokenHandler) VerifyToken(c *fiber.Ctx) error {
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
+	token, ok := bearerToken(c)
+	if token == "" && ok {
 		log.Printf("Missing Authorization header")
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"valid": false,
-			"error": "Missing authorization token",
+			"error": localizer(c).T(CodeTokenMissing),
+			"code":  CodeTokenMissing,
 		})
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
+	if !ok {
 		log.Printf("Invalid Authorization header format")
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"valid": false,
-			"error": "Invalid authorization header format",
+			"error": localizer(c).T(CodeTokenMalformed),
+			"code":  CodeTokenMalformed,
 		})
 	}
 
-	token := parts[1]
 	log.Printf("Verifying token: %s", token[:20])
 
-	// Проверяем токен через introspection
-	introspection, err := h.oidcService.IntrospectToken(c.Context(), token)
+	// JWT проверяется локально по JWKS, opaque токены - через introspection
+	introspection, err := h.oidcService.VerifyToken(c.Context(), token)
 	if err != nil {
 		log.Printf("Token introspection failed: %v", err)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"valid": false,
-			"error": "Token validation failed",
+			"error": localizer(c).T(CodeTokenInvalid),
+			"code":  CodeTokenInvalid,
 		})
 	}
 
@@ -57,7 +100,8 @@ func (h *TokenHandler) VerifyToken(c *fiber.Ctx) error {
 		log.Printf("Token is not active")
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"valid": false,
-			"error": "Token is expired or invalid",
+			"error": localizer(c).T(CodeTokenInactive),
+			"code":  CodeTokenInactive,
 		})
 	}
 
@@ -69,3 +113,83 @@ func (h *TokenHandler) VerifyToken(c *fiber.Ctx) error {
 		"username": introspection.Username,
 	})
 }
+
+// Logout завершает текущую сессию: отзывает access token из Authorization и refresh token из тела,
+// удаляет сессию Zitadel. Нужен хотя бы один из токенов
+// POST /api/auth/logout
+func (h *TokenHandler) Logout(c *fiber.Ctx) error {
+	accessToken, ok := bearerToken(c)
+	if !ok {
+		return respondDomainError(c, domain.ErrTokenMalformed)
+	}
+
+	req, err := bindLogoutRequest(c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	if err := h.logout.Logout(c.Context(), accessToken, req.RefreshToken); err != nil {
+		log.Printf("Logout failed: %v", err)
+		if errors.Is(err, domain.ErrTokenRequired) {
+			return respondDomainError(c, err)
+		}
+		return respondInternalError(c, i18n.MsgLogoutFailed, err)
+	}
+
+	return respondOK(c, domain.LogoutResponse{
+		Success: true,
+		Message: localizer(c).T(i18n.MsgLoggedOut),
+	})
+}
+
+// LogoutEverywhere завершает все сессии владельца access token'а
+// POST /api/auth/logout/all
+func (h *TokenHandler) LogoutEverywhere(c *fiber.Ctx) error {
+	accessToken, ok := bearerToken(c)
+	if !ok {
+		return respondDomainError(c, domain.ErrTokenMalformed)
+	}
+
+	req, err := bindLogoutRequest(c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	terminated, err := h.logout.LogoutEverywhere(c.Context(), accessToken, req.RefreshToken)
+	if err != nil {
+		log.Printf("Logout everywhere failed: %v", err)
+		if errors.Is(err, domain.ErrTokenRequired) || errors.Is(err, domain.ErrTokenInactive) {
+			return respondDomainError(c, err)
+		}
+		return respondInternalError(c, i18n.MsgLogoutFailed, err)
+	}
+
+	return respondOK(c, domain.LogoutResponse{
+		Success:            true,
+		Message:            localizer(c).T(i18n.MsgLoggedOutEverywhere),
+		SessionsTerminated: terminated,
+	})
+}
+
+// bindLogoutRequest - тело выхода необязательно: достаточно заголовка Authorization
+func bindLogoutRequest(c *fiber.Ctx) (*domain.LogoutRequest, error) {
+	if len(c.Body()) == 0 {
+		return &domain.LogoutRequest{}, nil
+	}
+	return bindBody[domain.LogoutRequest](c)
+}
+
+// bearerToken извлекает токен из "Authorization: Bearer <token>".
+// Пустой токен с ok=true - заголовка нет, ok=false - заголовок в неверном формате
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	authHeader := c.Get(fiber.HeaderAuthorization)
+	if authHeader == "" {
+		return "", true
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
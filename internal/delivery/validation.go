@@ -0,0 +1,113 @@
+package delivery
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"sms-service/internal/i18n"
+	"sms-service/internal/phone"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// validate проверяет теги `validate` у моделей запросов.
+// Дополнительный тег phone принимает номер в любом формате, который разбирает пакет phone
+var validate = newValidator()
+
+// FieldError - ошибка проверки одного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// errInvalidBody - тело запроса не разбирается
+var errInvalidBody = errors.New("invalid request body")
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// В ошибках поле называется так же, как в JSON или query
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "query"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phone.IsValid(fl.Field().String())
+	})
+
+	return v
+}
+
+// bindBody разбирает тело запроса в T и проверяет теги validate
+func bindBody[T any](c *fiber.Ctx) (*T, error) {
+	req := new(T)
+	if err := c.BodyParser(req); err != nil {
+		log.Printf("Failed to parse request body for %s: %v", c.Path(), err)
+		return nil, errInvalidBody
+	}
+	useRequestLocale(c, req)
+	return req, validate.Struct(req)
+}
+
+// bindQuery разбирает query-параметры в T и проверяет теги validate
+func bindQuery[T any](c *fiber.Ctx) (*T, error) {
+	req := new(T)
+	if err := c.QueryParser(req); err != nil {
+		log.Printf("Failed to parse query for %s: %v", c.Path(), err)
+		return nil, errInvalidBody
+	}
+	useRequestLocale(c, req)
+	return req, validate.Struct(req)
+}
+
+// respondBindError - 400 для неразбираемого тела, 422 с ошибками по полям для невалидного запроса
+func respondBindError(c *fiber.Ctx, err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return respondBadRequest(c, i18n.MsgInvalidBody)
+	}
+
+	loc := localizer(c)
+	fields := make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		fields = append(fields, FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErrorMessage(loc, fieldErr),
+		})
+	}
+
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(ErrorResponse{
+		Error:  loc.T(i18n.MsgValidationFailed),
+		Code:   CodeValidationFailed,
+		Fields: fields,
+	})
+}
+
+func fieldErrorMessage(loc *i18n.Localizer, err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return loc.T(i18n.MsgFieldRequired, err.Field())
+	case "phone", "e164":
+		return loc.T(i18n.MsgFieldPhone, err.Field())
+	case "len":
+		return loc.T(i18n.MsgFieldLen, err.Field(), err.Param())
+	case "numeric":
+		return loc.T(i18n.MsgFieldNumeric, err.Field())
+	case "oneof":
+		return loc.T(i18n.MsgFieldOneOf, err.Field(), err.Param())
+	case "max":
+		return loc.T(i18n.MsgFieldMax, err.Field(), err.Param())
+	default:
+		return loc.T(i18n.MsgFieldInvalid, err.Field(), err.Tag())
+	}
+}
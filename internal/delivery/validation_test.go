@@ -0,0 +1,175 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sms-service/internal/domain"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newValidationApp отвечает 204 на запрос, прошедший bindBody/bindQuery
+func newValidationApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/verify", func(c *fiber.Ctx) error {
+		if _, err := bindBody[domain.LoginVerifyOTPRequest](c); err != nil {
+			return respondBindError(c, err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	app.Get("/status", func(c *fiber.Ctx) error {
+		if _, err := bindQuery[domain.OTPStatusRequest](c); err != nil {
+			return respondBindError(c, err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	return app
+}
+
+func doValidationRequest(t *testing.T, req *http.Request) (int, ErrorResponse) {
+	t.Helper()
+
+	resp, err := newValidationApp().Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+
+	var body ErrorResponse
+	if resp.StatusCode != fiber.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp.StatusCode, body
+}
+
+func TestBindBodyValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		status     int
+		fields     []FieldError
+		wantErrMsg string
+	}{
+		{
+			name:   "valid",
+			body:   `{"phone":"8 (999) 123-45-67","code":"123456"}`,
+			status: fiber.StatusNoContent,
+		},
+		{
+			name:       "malformed json",
+			body:       `{"phone":`,
+			status:     fiber.StatusBadRequest,
+			wantErrMsg: "Invalid request body",
+		},
+		{
+			name:   "missing fields",
+			body:   `{}`,
+			status: fiber.StatusUnprocessableEntity,
+			fields: []FieldError{
+				{Field: "phone", Rule: "required", Message: "phone is required"},
+				{Field: "code", Rule: "required", Message: "code is required"},
+			},
+			wantErrMsg: "Validation failed",
+		},
+		{
+			name:   "invalid values",
+			body:   `{"phone":"12345","code":"12a456"}`,
+			status: fiber.StatusUnprocessableEntity,
+			fields: []FieldError{
+				{Field: "phone", Rule: "phone", Message: "phone must be a valid phone number"},
+				{Field: "code", Rule: "numeric", Message: "code must contain only digits"},
+			},
+			wantErrMsg: "Validation failed",
+		},
+		{
+			name:   "wrong length",
+			body:   `{"phone":"+79991234567","code":"123"}`,
+			status: fiber.StatusUnprocessableEntity,
+			fields: []FieldError{
+				{Field: "code", Rule: "len", Message: "code must be exactly 6 characters long"},
+			},
+			wantErrMsg: "Validation failed",
+		},
+		{
+			name:   "locale from body",
+			body:   `{"phone":"+79991234567","code":"123","locale":"ru-RU"}`,
+			status: fiber.StatusUnprocessableEntity,
+			fields: []FieldError{
+				{Field: "code", Rule: "len", Message: "Поле code должно содержать ровно 6 символов"},
+			},
+			wantErrMsg: "Ошибка проверки запроса",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/verify", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			status, body := doValidationRequest(t, req)
+
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if body.Error != tt.wantErrMsg {
+				t.Errorf("error = %q, want %q", body.Error, tt.wantErrMsg)
+			}
+			if !reflect.DeepEqual(body.Fields, tt.fields) {
+				t.Errorf("fields = %+v, want %+v", body.Fields, tt.fields)
+			}
+			if tt.status == fiber.StatusUnprocessableEntity && body.Code != CodeValidationFailed {
+				t.Errorf("code = %s, want %s", body.Code, CodeValidationFailed)
+			}
+		})
+	}
+}
+
+func TestBindQueryValidation(t *testing.T) {
+	req := httptest.NewRequest(fiber.MethodGet, "/status?phone=%2B79991234567&nonce=n1&purpose=logout", nil)
+	req.Header.Set(fiber.HeaderAcceptLanguage, "ru;q=0.9, en;q=0.5")
+	status, body := doValidationRequest(t, req)
+
+	if status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", status)
+	}
+	want := []FieldError{{
+		Field:   "purpose",
+		Rule:    "oneof",
+		Message: "Поле purpose должно иметь одно из значений: register login phone_change",
+	}}
+	if !reflect.DeepEqual(body.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", body.Fields, want)
+	}
+
+	req = httptest.NewRequest(fiber.MethodGet, "/status?phone=89991234567&nonce=n1&purpose=login", nil)
+	if status, _ := doValidationRequest(t, req); status != fiber.StatusNoContent {
+		t.Errorf("valid query: status = %d, want 204", status)
+	}
+}
+
+func TestPhoneValidationTag(t *testing.T) {
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{"+79991234567", true},
+		{"89991234567", true},
+		{"8 (999) 123-45-67", true},
+		{"+375 29 123-45-67", true},
+		{"", false},
+		{"12345", false},
+		{"+7999123456789012", false},
+		{"not a phone", false},
+	}
+
+	for _, tt := range tests {
+		err := validate.Var(tt.phone, "phone")
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("phone %q: valid = %v, want %v (%v)", tt.phone, valid, tt.valid, err)
+		}
+	}
+}
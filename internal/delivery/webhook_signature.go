@@ -0,0 +1,27 @@
+package delivery
+
+import (
+	"log"
+	"sms-service/internal/service"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookSignatureMiddleware пропускает только вызовы, подписанные ключом target'а.
+// Ставится на группу webhook'ов: target - часть пути после prefix ("/api/zitadel/events" -> "events"),
+// поэтому новый маршрут группы без ключей в ZITADEL_SIGNING_KEYS будет отклонять вызовы
+func WebhookSignatureMiddleware(verifier *service.WebhookVerifier, prefix string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		target := strings.Trim(strings.TrimPrefix(c.Path(), prefix), "/")
+
+		header := c.Get(service.WebhookSignatureHeader)
+		if err := verifier.Verify(target, header, c.Body(), time.Now()); err != nil {
+			log.Printf("Zitadel webhook rejected for target %s: %v", target, err)
+			return respondDomainError(c, err)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,128 @@
+package delivery
+
+import (
+	"log"
+	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
+	"sms-service/internal/phone"
+	"sms-service/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ZitadelHandler обрабатывает вызовы Actions V2 от Zitadel
+type ZitadelHandler struct {
+	phonePolicy *service.PhonePolicy
+	dispatcher  *service.EventDispatcher
+	mutators    *service.ResponseMutatorRegistry
+}
+
+func NewZitadelHandler(
+	phonePolicy *service.PhonePolicy,
+	dispatcher *service.EventDispatcher,
+	mutators *service.ResponseMutatorRegistry,
+) *ZitadelHandler {
+	return &ZitadelHandler{
+		phonePolicy: phonePolicy,
+		dispatcher:  dispatcher,
+		mutators:    mutators,
+	}
+}
+
+// PreRegistration проверяет номер телефона до создания пользователя в Zitadel
+// POST /api/zitadel/pre-registration
+// Target: restWebhook (interruptOnError), Execution: request /zitadel.user.v2.UserService/CreateUser
+func (h *ZitadelHandler) PreRegistration(c *fiber.Ctx) error {
+	req, err := bindBody[domain.ZitadelWebhookRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	log.Printf("Received webhook from Zitadel: %s", req.FullMethod)
+
+	if req.FullMethod != domain.ZitadelMethodCreateUser || req.IsMachineUser() {
+		return respondOK(c, req.Request)
+	}
+
+	rawPhone, ok := req.ExtractPhoneNumber()
+	if !ok || rawPhone == "" {
+		log.Printf("Registration rejected: %v", domain.ErrPhoneNotFound)
+		return respondWebhookRejection(c, fiber.StatusBadRequest, domain.ErrPhoneNotFound)
+	}
+
+	phoneNumber, err := phone.Normalize(rawPhone)
+	if err != nil {
+		log.Printf("Registration rejected: %v", err)
+		return respondWebhookRejection(c, fiber.StatusBadRequest, err)
+	}
+
+	if err := h.phonePolicy.Check(phoneNumber); err != nil {
+		log.Printf("Registration rejected for %s: %v", phoneNumber, err)
+		return respondWebhookRejection(c, fiber.StatusForbidden, err)
+	}
+
+	log.Printf("Phone validation passed: %s", phoneNumber)
+
+	// Возвращаем запрос с номером в E.164 - для restCall target'а Zitadel подставит его вместо исходного
+	req.SetPhoneNumber(phoneNumber)
+	return respondOK(c, req.Request)
+}
+
+// PostCreateUser дополняет ответ CreateUser зарегистрированными мутаторами
+// POST /api/zitadel/post-create-user
+// Target: restCall, Execution: response /zitadel.user.v2.UserService/CreateUser
+// Тело ответа target'а Zitadel подставляет вместо исходного ответа метода
+func (h *ZitadelHandler) PostCreateUser(c *fiber.Ctx) error {
+	req, err := bindBody[domain.ZitadelWebhookRequest](c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	if req.Response == nil {
+		return respondBadRequest(c, i18n.MsgFieldRequired, "response")
+	}
+
+	log.Printf("Received response webhook from Zitadel: %s", req.FullMethod)
+
+	response, err := h.mutators.Apply(c.Context(), req)
+	if err != nil {
+		// Пользователь уже создан: ошибку не пробрасываем, отдаем исходный ответ
+		log.Printf("Failed to mutate %s response: %v", req.FullMethod, err)
+		return respondOK(c, req.Response)
+	}
+
+	return respondOK(c, response)
+}
+
+// Events принимает асинхронные события Zitadel
+// POST /api/zitadel/events
+// Target: restAsync, Execution: event user.human.added, user.human.phone.verified, session.added, ...
+func (h *ZitadelHandler) Events(c *fiber.Ctx) error {
+	event, err := bindBody[domain.ZitadelEvent](c)
+	if err != nil {
+		return respondBindError(c, err)
+	}
+
+	log.Printf("Received Zitadel event: %s (aggregate=%s, sequence=%d)", event.EventType, event.AggregateID, event.Sequence)
+
+	if err := h.dispatcher.Dispatch(c.Context(), event); err != nil {
+		log.Printf("Failed to process Zitadel event: %v", err)
+		return respondInternalError(c, i18n.MsgEventFailed, err)
+	}
+
+	return respondOK(c, fiber.Map{"success": true})
+}
+
+// respondWebhookRejection - отказ в формате, который Zitadel пробрасывает клиенту.
+// Zitadel не передает язык пользователя, поэтому текст - на локали по умолчанию
+func respondWebhookRejection(c *fiber.Ctx, status int, err error) error {
+	message := err.Error()
+	if mapped, ok := lookupAPIError(err); ok {
+		message = localizer(c).T(mapped.code)
+	}
+
+	return respondOK(c, domain.ZitadelWebhookRejection{
+		ForwardedStatusCode:   status,
+		ForwardedErrorMessage: message,
+	})
+}
@@ -2,13 +2,13 @@ package domain
 
 // SendOTPRequest - запрос на отправку OTP кода
 type SendOTPRequest struct {
-	Phone string `json:"phone" validate:"required,e164"`
+	Phone string `json:"phone" validate:"required,phone"`
 }
 
 // RegisterWithOTPRequest - запрос на регистрацию с OTP
 type RegisterWithOTPRequest struct {
-	Phone string `json:"phone" validate:"required,e164"`
-	Code  string `json:"code" validate:"required,len=6"`
+	Phone string `json:"phone" validate:"required,phone"`
+	Code  string `json:"code" validate:"required,len=6,numeric"`
 }
 
 // SendOTPResponse - ответ на отправку OTP
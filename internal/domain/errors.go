@@ -4,23 +4,45 @@ import "errors"
 
 var (
 	// Auth errors
-	ErrInvalidPhone   = errors.New("invalid phone number")
-	ErrInvalidOTP     = errors.New("invalid OTP code")
-	ErrOTPExpired     = errors.New("OTP code has expired")
-	ErrOTPMaxAttempts = errors.New("maximum OTP attempts exceeded")
-	ErrOTPNotFound    = errors.New("OTP code not found for this phone number")
+	ErrInvalidPhone      = errors.New("invalid phone number")
+	ErrInvalidOTP        = errors.New("invalid OTP code")
+	ErrOTPExpired        = errors.New("OTP code has expired")
+	ErrOTPMaxAttempts    = errors.New("maximum OTP attempts exceeded")
+	ErrOTPNotFound       = errors.New("OTP code not found for this phone number")
+	ErrOTPWrongPurpose   = errors.New("OTP code was issued for a different operation")
+	ErrOTPResendCooldown = errors.New("please wait before requesting a new OTP code")
+	ErrOTPSendLimit      = errors.New("too many OTP requests for this phone number")
+
+	// Token errors
+	ErrTokenRequired       = errors.New("access or refresh token is required")
+	ErrTokenMalformed      = errors.New("invalid authorization header format")
+	ErrTokenInactive       = errors.New("token is expired or invalid")
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrRefreshTokenRevoked = errors.New("refresh token has been revoked")
 
 	// User errors
 	ErrUserNotFound      = errors.New("user not found")
 	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrUserAmbiguous     = errors.New("several users match this phone number")
 
 	// Validation errors
 	ErrPhoneRequired  = errors.New("phone number is required")
 	ErrCodeRequired   = errors.New("verification code is required")
 	ErrUserIDRequired = errors.New("user ID is required")
+	ErrInvalidPurpose = errors.New("invalid OTP purpose")
+
+	// Rate limit errors
+	ErrRateLimited = errors.New("too many requests, please try again later")
 
 	// Webhook errors
-	ErrPhoneBlacklisted = errors.New("this phone number is not allowed")
-	ErrPhoneNotAllowed  = errors.New("only Russian phone numbers are allowed")
-	ErrPhoneNotFound    = errors.New("phone number not found in request")
+	ErrPhoneBlacklisted    = errors.New("this phone number is not allowed")
+	ErrPhoneNotAllowed     = errors.New("phone numbers from this region are not allowed")
+	ErrPhoneTypeNotAllowed = errors.New("this type of phone number is not supported")
+	ErrPhoneNotFound       = errors.New("phone number not found in request")
+
+	// Webhook signature errors
+	ErrSignatureMissing = errors.New("webhook signature is missing")
+	ErrSignatureInvalid = errors.New("webhook signature is invalid")
+	ErrSignatureExpired = errors.New("webhook signature timestamp is outside the allowed tolerance")
 )
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Типы событий Zitadel, на которые подписаны restAsync target'ы.
+// В execution условие задается как {"event": {"event": "<тип>"}}
+const (
+	EventUserHumanAdded         = "user.human.added"
+	EventUserHumanPhoneChanged  = "user.human.phone.changed"
+	EventUserHumanPhoneVerified = "user.human.phone.verified"
+	EventSessionAdded           = "session.added"
+)
+
+// ZitadelEvent - тело вызова restAsync target'а по событию
+type ZitadelEvent struct {
+	AggregateID   string       `json:"aggregateID" validate:"required"`
+	AggregateType string       `json:"aggregateType"`
+	ResourceOwner string       `json:"resourceOwner"`
+	InstanceID    string       `json:"instanceID"`
+	Version       string       `json:"version"`
+	Sequence      uint64       `json:"sequence"`
+	EventType     string       `json:"event_type" validate:"required"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UserID        string       `json:"userID"`
+	EventPayload  EventPayload `json:"event_payload"`
+}
+
+// IdempotencyKey - событие однозначно определяется инстансом, агрегатом и его sequence:
+// ID агрегатов и sequence уникальны только в пределах инстанса Zitadel
+func (e *ZitadelEvent) IdempotencyKey() string {
+	return fmt.Sprintf("%s:%s:%d", e.InstanceID, e.AggregateID, e.Sequence)
+}
+
+// DecodePayload разбирает event_payload в типизированную структуру
+func (e *ZitadelEvent) DecodePayload(target interface{}) error {
+	if len(e.EventPayload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(e.EventPayload, target); err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", e.EventType, err)
+	}
+	return nil
+}
+
+// EventPayload - JSON события. Zitadel отдает его либо объектом,
+// либо base64-строкой (сериализованный []byte), поддерживаем оба варианта
+type EventPayload json.RawMessage
+
+func (p *EventPayload) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			return err
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid base64 event payload: %w", err)
+		}
+		*p = decoded
+		return nil
+	}
+
+	if string(data) == "null" {
+		*p = nil
+		return nil
+	}
+
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// UserHumanAddedPayload - payload события user.human.added
+type UserHumanAddedPayload struct {
+	UserName          string `json:"userName"`
+	FirstName         string `json:"firstName"`
+	LastName          string `json:"lastName"`
+	DisplayName       string `json:"displayName"`
+	PreferredLanguage string `json:"preferredLanguage"`
+	Email             string `json:"email"`
+	Phone             string `json:"phone"`
+}
+
+// UserHumanPhoneChangedPayload - payload события user.human.phone.changed
+type UserHumanPhoneChangedPayload struct {
+	Phone string `json:"phone"`
+}
+
+// SessionAddedPayload - payload события session.added
+type SessionAddedPayload struct {
+	UserAgent *SessionUserAgent `json:"userAgent"`
+}
+
+// SessionUserAgent - данные клиента, создавшего сессию
+type SessionUserAgent struct {
+	FingerprintID string `json:"fingerprintID"`
+	IP            string `json:"IP"`
+	Description   string `json:"description"`
+}
@@ -2,22 +2,31 @@ package domain
 
 // LoginSendOTPRequest - запрос на отправку OTP для входа
 type LoginSendOTPRequest struct {
-	Phone string `json:"phone" validate:"required,e164"`
+	Phone  string `json:"phone" validate:"required,phone"`
+	Nonce  string `json:"nonce,omitempty" validate:"max=128"` // привязка кода к клиенту/сессии, должен совпасть при проверке
+	Locale string `json:"locale,omitempty" validate:"max=35"` // язык ответа и SMS, важнее Accept-Language
 }
 
+func (r *LoginSendOTPRequest) RequestLocale() string { return r.Locale }
+
 // LoginSendOTPResponse - ответ на отправку OTP для входа
 type LoginSendOTPResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"` // Только для dev/test
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	Code       string `json:"code,omitempty"` // Только для dev/test
+	RetryAfter int    `json:"retry_after"`    // секунд до следующей разрешенной отправки
 }
 
 // LoginVerifyOTPRequest - запрос на вход с OTP
 type LoginVerifyOTPRequest struct {
-	Phone string `json:"phone" validate:"required,e164"`
-	Code  string `json:"code" validate:"required,len=6"`
+	Phone  string `json:"phone" validate:"required,phone"`
+	Code   string `json:"code" validate:"required,len=6,numeric"`
+	Nonce  string `json:"nonce,omitempty" validate:"max=128"`
+	Locale string `json:"locale,omitempty" validate:"max=35"`
 }
 
+func (r *LoginVerifyOTPRequest) RequestLocale() string { return r.Locale }
+
 // LoginVerifyOTPResponse - ответ с токенами или authorization URL после успешного входа
 type LoginVerifyOTPResponse struct {
 	Success      bool   `json:"success"`
@@ -38,6 +47,18 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// LogoutRequest - тело запроса на выход; access token передается в заголовке Authorization
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// LogoutResponse - результат выхода
+type LogoutResponse struct {
+	Success            bool   `json:"success"`
+	Message            string `json:"message"`
+	SessionsTerminated int    `json:"sessions_terminated,omitempty"` // только для выхода на всех устройствах
+}
+
 // RefreshTokenResponse - ответ с новыми токенами
 type RefreshTokenResponse struct {
 	Success      bool   `json:"success"`
@@ -0,0 +1,28 @@
+package domain
+
+// OTPPurpose - назначение OTP кода: код, выданный для одного сценария,
+// не принимается в другом
+type OTPPurpose string
+
+const (
+	OTPPurposeRegister    OTPPurpose = "register"
+	OTPPurposeLogin       OTPPurpose = "login"
+	OTPPurposePhoneChange OTPPurpose = "phone_change"
+)
+
+// OTPPurposes - все известные назначения
+var OTPPurposes = []OTPPurpose{
+	OTPPurposeRegister,
+	OTPPurposeLogin,
+	OTPPurposePhoneChange,
+}
+
+// IsValid - назначение входит в OTPPurposes
+func (p OTPPurpose) IsValid() bool {
+	for _, purpose := range OTPPurposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// DeliveryStatus - статус доставки SMS с OTP кодом
+type DeliveryStatus string
+
+const (
+	DeliveryStatusQueued    DeliveryStatus = "queued"
+	DeliveryStatusSent      DeliveryStatus = "sent"
+	DeliveryStatusDelivered DeliveryStatus = "delivered"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+// IsFinal - статус больше не изменится
+func (s DeliveryStatus) IsFinal() bool {
+	return s == DeliveryStatusDelivered || s == DeliveryStatusFailed
+}
+
+// ParseDeliveryStatus - приводит статус провайдера к DeliveryStatus
+// Понимает как собственные значения, так и статусы SMPP (DELIVRD, UNDELIV, ...)
+func ParseDeliveryStatus(raw string) (DeliveryStatus, bool) {
+	switch strings.ToUpper(strings.TrimSpace(raw)) {
+	case "QUEUED":
+		return DeliveryStatusQueued, true
+	case "SENT", "ENROUTE", "ACCEPTD", "ACCEPTED":
+		return DeliveryStatusSent, true
+	case "DELIVERED", "DELIVRD":
+		return DeliveryStatusDelivered, true
+	case "FAILED", "UNDELIV", "UNDELIVERABLE", "EXPIRED", "DELETED", "REJECTD", "REJECTED", "UNKNOWN":
+		return DeliveryStatusFailed, true
+	}
+	return "", false
+}
+
+// DeliveryReceiptRequest - DLR callback от SMS провайдера
+type DeliveryReceiptRequest struct {
+	MessageID string `json:"message_id" validate:"required"`
+	Status    string `json:"status" validate:"required"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// OTPStatusRequest - query-параметры запроса статуса доставки OTP.
+// Статус отдается только клиенту, передавшему тот же nonce в send-otp
+type OTPStatusRequest struct {
+	Phone   string `query:"phone" validate:"required,phone"`
+	Nonce   string `query:"nonce" validate:"required,max=128"`
+	Purpose string `query:"purpose" validate:"required,oneof=register login phone_change"`
+	Locale  string `query:"locale" validate:"max=35"`
+}
+
+func (r *OTPStatusRequest) RequestLocale() string { return r.Locale }
+
+// OTPStatusResponse - статус доставки последнего OTP кода
+type OTPStatusResponse struct {
+	Success   bool           `json:"success"`
+	Phone     string         `json:"phone"`
+	Purpose   OTPPurpose     `json:"purpose"`
+	Status    DeliveryStatus `json:"status"`
+	Provider  string         `json:"provider,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
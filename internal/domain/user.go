@@ -2,7 +2,7 @@ package domain
 
 // CreateUserRequest - запрос на создание пользователя
 type CreateUserRequest struct {
-	Phone string `json:"phone" validate:"required,e164"`
+	Phone string `json:"phone" validate:"required,phone"`
 }
 
 // CreateUserResponse - ответ на создание пользователя
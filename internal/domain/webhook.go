@@ -2,11 +2,28 @@ package domain
 
 // ZitadelWebhookRequest - структура запроса от Zitadel Actions V2
 type ZitadelWebhookRequest struct {
-	FullMethod string                 `json:"fullMethod"`
+	FullMethod string                 `json:"fullMethod" validate:"required"`
+	InstanceID string                 `json:"instanceID"`
+	OrgID      string                 `json:"orgID"`
+	ProjectID  string                 `json:"projectID"`
+	UserID     string                 `json:"userID"`
 	Request    map[string]interface{} `json:"request"`
+	Response   map[string]interface{} `json:"response"` // только для execution с условием response
 	Context    map[string]interface{} `json:"context"`
 }
 
+// Методы API Zitadel, которые перехватывают наши webhook'и
+const (
+	ZitadelMethodCreateUser = "/zitadel.user.v2.UserService/CreateUser"
+)
+
+// ZitadelWebhookRejection - отказ, который Zitadel пробрасывает вызывающему клиенту как ошибку API.
+// Отдается с HTTP 200: Zitadel сам превращает forwardedStatusCode в код ответа
+type ZitadelWebhookRejection struct {
+	ForwardedStatusCode   int    `json:"forwardedStatusCode"`
+	ForwardedErrorMessage string `json:"forwardedErrorMessage"`
+}
+
 // ZitadelWebhookResponse - стандартный ответ на webhook
 type ZitadelWebhookResponse struct {
 	Success bool   `json:"success"`
@@ -26,6 +43,21 @@ func (w *ZitadelWebhookRequest) ExtractPhoneNumber() (string, bool) {
 	return "", false
 }
 
+// IsMachineUser - запрос создает сервисного (machine) пользователя, а не человека
+func (w *ZitadelWebhookRequest) IsMachineUser() bool {
+	_, ok := w.Request["machine"].(map[string]interface{})
+	return ok
+}
+
+// SetPhoneNumber - заменяет номер телефона в Request["human"]["phone"]["phone"], если он там есть
+func (w *ZitadelWebhookRequest) SetPhoneNumber(phone string) {
+	if human, ok := w.Request["human"].(map[string]interface{}); ok {
+		if phoneMap, ok := human["phone"].(map[string]interface{}); ok {
+			phoneMap["phone"] = phone
+		}
+	}
+}
+
 // ExtractUsername - извлекает username из webhook request
 func (w *ZitadelWebhookRequest) ExtractUsername() (string, bool) {
 	if username, ok := w.Request["username"].(string); ok {
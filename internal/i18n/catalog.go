@@ -0,0 +1,189 @@
+package i18n
+
+// Ключи сообщений, которые не являются кодами ошибок API.
+// Ошибки переводятся по своим стабильным кодам (см. delivery/errors.go)
+const (
+	MsgInvalidBody            = "invalid_body"
+	MsgValidationFailed       = "validation_failed"
+	MsgInternalError          = "internal_error"
+	MsgOTPGenerateFailed      = "otp_generate_failed"
+	MsgOTPSendFailed          = "otp_send_failed"
+	MsgOTPLimitsCheckFailed   = "otp_limits_check_failed"
+	MsgUserLookupFailed       = "user_lookup_failed"
+	MsgUserCreateFailed       = "user_create_failed"
+	MsgTokenExchangeFailed    = "token_exchange_failed"
+	MsgOTPSent                = "otp_sent"
+	MsgRegisterOTPSent        = "register_otp_sent"
+	MsgRegistrationSuccessful = "registration_successful"
+	MsgLoggedOut              = "logged_out"
+	MsgLoggedOutEverywhere    = "logged_out_everywhere"
+	MsgLogoutFailed           = "logout_failed"
+	MsgEventFailed            = "event_failed"
+	MsgDLRTokenInvalid        = "dlr_token_invalid"
+
+	// Аргумент - значение из запроса
+	MsgUnknownSMSProvider    = "unknown_sms_provider"
+	MsgUnknownDeliveryStatus = "unknown_delivery_status"
+
+	// Тексты SMS, аргумент - код
+	MsgSMSLoginCode    = "sms_login_code"
+	MsgSMSRegisterCode = "sms_register_code"
+
+	// Ошибки полей запроса, аргументы - имя поля и параметр правила
+	MsgFieldRequired = "field_required"
+	MsgFieldPhone    = "field_phone"
+	MsgFieldLen      = "field_len"
+	MsgFieldNumeric  = "field_numeric"
+	MsgFieldOneOf    = "field_oneof"
+	MsgFieldMax      = "field_max"
+	MsgFieldInvalid  = "field_invalid"
+)
+
+// catalogs - сообщения по локалям. В английском каталоге должны быть все ключи:
+// он последний в любой цепочке
+var catalogs = map[string]map[string]string{
+	English: english,
+	Russian: russian,
+}
+
+var english = map[string]string{
+	MsgInvalidBody:            "Invalid request body",
+	MsgValidationFailed:       "Validation failed",
+	MsgInternalError:          "Internal server error",
+	MsgOTPGenerateFailed:      "Failed to generate OTP code",
+	MsgOTPSendFailed:          "Failed to send OTP code",
+	MsgOTPLimitsCheckFailed:   "Failed to check OTP send limits",
+	MsgUserLookupFailed:       "Failed to look up user",
+	MsgUserCreateFailed:       "Failed to create user",
+	MsgTokenExchangeFailed:    "Failed to issue tokens",
+	MsgOTPSent:                "OTP code sent successfully",
+	MsgRegisterOTPSent:        "Registration OTP code sent successfully",
+	MsgRegistrationSuccessful: "Registration successful",
+	MsgLoggedOut:              "Logged out successfully",
+	MsgLoggedOutEverywhere:    "Logged out on all devices",
+	MsgLogoutFailed:           "Failed to complete logout",
+	MsgEventFailed:            "Failed to process event",
+	MsgDLRTokenInvalid:        "Invalid DLR token",
+
+	MsgUnknownSMSProvider:    "Unknown SMS provider: %s",
+	MsgUnknownDeliveryStatus: "Unknown delivery status: %s",
+
+	MsgSMSLoginCode:    "Your verification code: %s",
+	MsgSMSRegisterCode: "Your registration code: %s",
+
+	MsgFieldRequired: "%s is required",
+	MsgFieldPhone:    "%s must be a valid phone number",
+	MsgFieldLen:      "%s must be exactly %s characters long",
+	MsgFieldNumeric:  "%s must contain only digits",
+	MsgFieldOneOf:    "%s must be one of: %s",
+	MsgFieldMax:      "%s must be at most %s characters long",
+	MsgFieldInvalid:  "%s failed %s validation",
+
+	"bad_request":  "Bad request",
+	"unauthorized": "Unauthorized",
+	"forbidden":    "Forbidden",
+	"not_found":    "Not found",
+	"rate_limited": "Too many requests, please try again later",
+
+	"otp_invalid":           "Invalid OTP code",
+	"otp_expired":           "OTP code has expired",
+	"otp_attempts_exceeded": "Maximum OTP attempts exceeded",
+	"otp_not_found":         "OTP code not found for this phone number",
+	"otp_wrong_purpose":     "OTP code was issued for a different operation",
+	"otp_resend_cooldown":   "Please wait before requesting a new OTP code",
+	"otp_send_limit":        "Too many OTP requests for this phone number",
+
+	"user_not_found": "User not found",
+	"user_exists":    "User with this phone number already exists",
+	"user_ambiguous": "Several accounts use this phone number, please contact support",
+
+	"phone_required":         "Phone number is required",
+	"phone_invalid":          "Invalid phone number",
+	"phone_not_allowed":      "Phone numbers from this region are not allowed",
+	"phone_type_not_allowed": "This type of phone number is not supported",
+	"phone_blacklisted":      "This phone number is not allowed",
+
+	"token_missing":   "Missing authorization token",
+	"token_malformed": "Invalid authorization header format",
+	"token_invalid":   "Token validation failed",
+	"token_inactive":  "Token is expired or invalid",
+
+	"refresh_token_invalid": "Refresh token is invalid or expired",
+	"refresh_token_reused":  "Refresh token has already been used, please sign in again",
+	"refresh_token_revoked": "Session has been terminated, please sign in again",
+
+	"signature_missing": "Webhook signature is missing",
+	"signature_invalid": "Webhook signature is invalid",
+	"signature_expired": "Webhook signature timestamp is outside the allowed tolerance",
+}
+
+var russian = map[string]string{
+	MsgInvalidBody:            "Некорректное тело запроса",
+	MsgValidationFailed:       "Ошибка проверки запроса",
+	MsgInternalError:          "Внутренняя ошибка сервера",
+	MsgOTPGenerateFailed:      "Не удалось создать код подтверждения",
+	MsgOTPSendFailed:          "Не удалось отправить код подтверждения",
+	MsgOTPLimitsCheckFailed:   "Не удалось проверить ограничения на отправку кода",
+	MsgUserLookupFailed:       "Не удалось найти пользователя",
+	MsgUserCreateFailed:       "Не удалось создать пользователя",
+	MsgTokenExchangeFailed:    "Не удалось выдать токены",
+	MsgOTPSent:                "Код подтверждения отправлен",
+	MsgRegisterOTPSent:        "Код для регистрации отправлен",
+	MsgRegistrationSuccessful: "Регистрация завершена",
+	MsgLoggedOut:              "Вы вышли из аккаунта",
+	MsgLoggedOutEverywhere:    "Вы вышли из аккаунта на всех устройствах",
+	MsgLogoutFailed:           "Не удалось завершить выход",
+	MsgEventFailed:            "Не удалось обработать событие",
+	MsgDLRTokenInvalid:        "Неверный токен DLR",
+
+	MsgUnknownSMSProvider:    "Неизвестный SMS провайдер: %s",
+	MsgUnknownDeliveryStatus: "Неизвестный статус доставки: %s",
+
+	MsgSMSLoginCode:    "Ваш код для входа: %s",
+	MsgSMSRegisterCode: "Ваш код для регистрации: %s",
+
+	MsgFieldRequired: "Поле %s обязательно",
+	MsgFieldPhone:    "Поле %s должно содержать корректный номер телефона",
+	MsgFieldLen:      "Поле %s должно содержать ровно %s символов",
+	MsgFieldNumeric:  "Поле %s должно содержать только цифры",
+	MsgFieldOneOf:    "Поле %s должно иметь одно из значений: %s",
+	MsgFieldMax:      "Поле %s должно содержать не более %s символов",
+	MsgFieldInvalid:  "Поле %s не прошло проверку %s",
+
+	"bad_request":  "Некорректный запрос",
+	"unauthorized": "Требуется авторизация",
+	"forbidden":    "Доступ запрещен",
+	"not_found":    "Не найдено",
+	"rate_limited": "Слишком много запросов, повторите попытку позже",
+
+	"otp_invalid":           "Неверный код подтверждения",
+	"otp_expired":           "Срок действия кода истек",
+	"otp_attempts_exceeded": "Превышено число попыток ввода кода",
+	"otp_not_found":         "Код для этого номера не найден",
+	"otp_wrong_purpose":     "Код был выдан для другой операции",
+	"otp_resend_cooldown":   "Подождите перед повторным запросом кода",
+	"otp_send_limit":        "Слишком много запросов кода для этого номера",
+
+	"user_not_found": "Пользователь не найден",
+	"user_exists":    "Пользователь с этим номером уже зарегистрирован",
+	"user_ambiguous": "Этот номер указан в нескольких аккаунтах, обратитесь в поддержку",
+
+	"phone_required":         "Укажите номер телефона",
+	"phone_invalid":          "Некорректный номер телефона",
+	"phone_not_allowed":      "Номера из этого региона не поддерживаются",
+	"phone_type_not_allowed": "Этот тип номера не поддерживается",
+	"phone_blacklisted":      "Этот номер телефона запрещен",
+
+	"token_missing":   "Отсутствует токен авторизации",
+	"token_malformed": "Некорректный формат заголовка авторизации",
+	"token_invalid":   "Не удалось проверить токен",
+	"token_inactive":  "Токен истек или недействителен",
+
+	"refresh_token_invalid": "Refresh token недействителен или истек",
+	"refresh_token_reused":  "Refresh token уже использован, войдите заново",
+	"refresh_token_revoked": "Сессия завершена, войдите заново",
+
+	"signature_missing": "Отсутствует подпись webhook",
+	"signature_invalid": "Неверная подпись webhook",
+	"signature_expired": "Время подписи webhook вне допустимого интервала",
+}
@@ -0,0 +1,133 @@
+// Package i18n - каталог сообщений для пользователя (ответы API, ошибки, тексты SMS).
+// Язык выбирается по цепочке: явно запрошенные локали, локаль по умолчанию, английский
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Поставляемые локали
+const (
+	English = "en"
+	Russian = "ru"
+)
+
+// defaultLocale - локаль для клиентов, которые не передали свою или передали неподдерживаемую
+var defaultLocale = English
+
+// SetDefaultLocale задает локаль по умолчанию, например "ru"
+func SetDefaultLocale(locale string) error {
+	locale = normalize(locale)
+	if _, ok := catalogs[locale]; !ok {
+		return fmt.Errorf("unsupported locale %q", locale)
+	}
+	defaultLocale = locale
+	return nil
+}
+
+// Localizer переводит ключи каталога для одной цепочки локалей
+type Localizer struct {
+	chain []string
+}
+
+// New строит цепочку из предпочтений клиента (в порядке убывания),
+// локали по умолчанию и английского. Для "ru-RU" проверяется и "ru"
+func New(preferred ...string) *Localizer {
+	seen := make(map[string]bool)
+	chain := make([]string, 0, len(preferred)*2+2)
+	add := func(locale string) {
+		if _, ok := catalogs[locale]; ok && !seen[locale] {
+			seen[locale] = true
+			chain = append(chain, locale)
+		}
+	}
+
+	for _, tag := range preferred {
+		tag = normalize(tag)
+		if tag == "" {
+			continue
+		}
+		add(tag)
+		if base, _, ok := strings.Cut(tag, "-"); ok {
+			add(base)
+		}
+	}
+	add(defaultLocale)
+	add(English)
+
+	return &Localizer{chain: chain}
+}
+
+// Locale - первая локаль цепочки, на которой будут сообщения
+func (l *Localizer) Locale() string {
+	return l.chain[0]
+}
+
+// T возвращает сообщение по ключу первой локали цепочки, в которой оно есть.
+// Если args заданы, сообщение - шаблон fmt. Неизвестный ключ возвращается как есть,
+// поэтому готовый текст можно передавать вместо ключа
+func (l *Localizer) T(key string, args ...any) string {
+	for _, locale := range l.chain {
+		if msg, ok := catalogs[locale][key]; ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(msg, args...)
+			}
+			return msg
+		}
+	}
+	return key
+}
+
+// ParseAcceptLanguage возвращает языки из заголовка Accept-Language по убыванию веса q.
+// Языки с q=0 и "*" отбрасываются
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				parsed = 0
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// normalize приводит тег к виду "ru" / "ru-ru": нижний регистр, "-" вместо "_"
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
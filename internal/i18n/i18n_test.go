@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"ru", []string{"ru"}},
+		{"ru-RU,ru;q=0.9,en;q=0.8", []string{"ru-RU", "ru", "en"}},
+		{"en;q=0.5, ru", []string{"ru", "en"}},
+		{"de;q=0.7, fr;q=0.7, en", []string{"en", "de", "fr"}},
+		{"*, en;q=0, ru;q=0.1", []string{"ru"}},
+		{"fr;q=abc, en", []string{"en"}},
+		{" , ;q=1", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+// withDefaultLocale задает локаль по умолчанию на время теста
+func withDefaultLocale(t *testing.T, locale string) {
+	t.Helper()
+
+	previous := defaultLocale
+	if err := SetDefaultLocale(locale); err != nil {
+		t.Fatalf("SetDefaultLocale(%q): %v", locale, err)
+	}
+	t.Cleanup(func() { defaultLocale = previous })
+}
+
+func TestNegotiation(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultLocale string
+		preferred     []string
+		want          string
+	}{
+		{"no preference", English, nil, English},
+		{"exact", English, []string{"ru"}, Russian},
+		{"region falls back to base", English, []string{"ru-RU"}, Russian},
+		{"case and underscore", English, []string{" RU_ru "}, Russian},
+		{"first supported wins", English, []string{"de", "", "ru", "en"}, Russian},
+		{"unsupported uses default", Russian, []string{"de-DE"}, Russian},
+		{"preference beats default", Russian, []string{"en-US"}, English},
+		{"no preference uses default", Russian, nil, Russian},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withDefaultLocale(t, tt.defaultLocale)
+			if got := New(tt.preferred...).Locale(); got != tt.want {
+				t.Errorf("New(%q).Locale() = %s, want %s", tt.preferred, got, tt.want)
+			}
+		})
+	}
+
+	if err := SetDefaultLocale("de"); err == nil {
+		t.Error("SetDefaultLocale(de): expected error")
+	}
+	if defaultLocale != English {
+		t.Errorf("defaultLocale = %s after failed SetDefaultLocale", defaultLocale)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	ru := New(Russian)
+
+	if got := ru.T(MsgSMSLoginCode, "123456"); got != "Ваш код для входа: 123456" {
+		t.Errorf("T with args = %q", got)
+	}
+	if got := ru.T("Already rendered text"); got != "Already rendered text" {
+		t.Errorf("unknown key = %q, want it unchanged", got)
+	}
+
+	// Ключ без перевода берется из следующей локали цепочки
+	const key = "test_only_english"
+	english[key] = "English only"
+	t.Cleanup(func() { delete(english, key) })
+	if got := ru.T(key); got != "English only" {
+		t.Errorf("fallback to English = %q", got)
+	}
+}
+
+// TestCatalogsComplete проверяет, что все локали переводят одни и те же ключи
+// с тем же числом аргументов шаблона
+func TestCatalogsComplete(t *testing.T) {
+	for locale, catalog := range catalogs {
+		if locale == English {
+			continue
+		}
+
+		for key, msg := range english {
+			translated, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s: missing key %q", locale, key)
+				continue
+			}
+			if want, got := strings.Count(msg, "%s"), strings.Count(translated, "%s"); got != want {
+				t.Errorf("%s: key %q has %d template args, English has %d", locale, key, got, want)
+			}
+		}
+
+		var extra []string
+		for key := range catalog {
+			if _, ok := english[key]; !ok {
+				extra = append(extra, key)
+			}
+		}
+		sort.Strings(extra)
+		if len(extra) > 0 {
+			t.Errorf("%s: keys missing from English: %q", locale, extra)
+		}
+	}
+}
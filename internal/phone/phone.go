@@ -0,0 +1,232 @@
+// Package phone разбирает номера телефонов в свободном формате
+// ("8 (912) 345-67-89", "+7 912 345 67 89", "0037529...") и приводит их к E.164
+package phone
+
+import (
+	"fmt"
+	"sms-service/internal/domain"
+	"strings"
+)
+
+// defaultCountry - код страны для номеров в национальном формате (без "+" и без "00")
+var defaultCountry = "7"
+
+// SetDefaultCountry задает код страны для национальных номеров, например "7" или "375"
+func SetDefaultCountry(code string) error {
+	code = strings.TrimPrefix(code, "+")
+	if _, ok := countries[code]; !ok {
+		return fmt.Errorf("unsupported default country code +%s", code)
+	}
+	defaultCountry = code
+	return nil
+}
+
+// country - правила нумерации страны
+type country struct {
+	code      string
+	trunk     string // национальный префикс выхода на междугороднюю связь
+	minLength int    // длина национального номера без кода страны
+	maxLength int
+	leading   string // допустимые первые цифры национального номера, пусто - любые
+}
+
+// countries - страны с известными правилами нумерации.
+// Для остальных проверяется только общая длина E.164
+var countries = map[string]country{
+	"7":   {code: "7", trunk: "8", minLength: 10, maxLength: 10, leading: "3456789"},  // Россия, Казахстан
+	"375": {code: "375", trunk: "80", minLength: 9, maxLength: 9},                     // Беларусь
+	"380": {code: "380", trunk: "0", minLength: 9, maxLength: 9},                      // Украина
+	"374": {code: "374", trunk: "0", minLength: 8, maxLength: 8},                      // Армения
+	"994": {code: "994", trunk: "0", minLength: 9, maxLength: 9},                      // Азербайджан
+	"995": {code: "995", trunk: "0", minLength: 9, maxLength: 9},                      // Грузия
+	"996": {code: "996", trunk: "0", minLength: 9, maxLength: 9},                      // Киргизия
+	"992": {code: "992", trunk: "8", minLength: 9, maxLength: 9},                      // Таджикистан
+	"998": {code: "998", trunk: "8", minLength: 9, maxLength: 9},                      // Узбекистан
+	"373": {code: "373", trunk: "0", minLength: 8, maxLength: 8},                      // Молдова
+	"1":   {code: "1", trunk: "1", minLength: 10, maxLength: 10, leading: "23456789"}, // NANP
+	"44":  {code: "44", trunk: "0", minLength: 9, maxLength: 10},                      // Великобритания
+	"49":  {code: "49", trunk: "0", minLength: 6, maxLength: 13},                      // Германия
+	"86":  {code: "86", trunk: "0", minLength: 10, maxLength: 11},                     // Китай
+	"90":  {code: "90", trunk: "0", minLength: 10, maxLength: 10},                     // Турция
+	"971": {code: "971", trunk: "0", minLength: 8, maxLength: 9},                      // ОАЭ
+}
+
+// twoDigitCodes - двузначные коды стран по плану нумерации ITU-T E.164.
+// Коды префиксные: 1 и 7 однозначные, перечисленные - двузначные, остальные - трехзначные
+var twoDigitCodes = map[string]bool{
+	"20": true, "27": true,
+	"30": true, "31": true, "32": true, "33": true, "34": true, "36": true, "39": true,
+	"40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true, "48": true, "49": true,
+	"51": true, "52": true, "53": true, "54": true, "55": true, "56": true, "57": true, "58": true,
+	"60": true, "61": true, "62": true, "63": true, "64": true, "65": true, "66": true,
+	"81": true, "82": true, "84": true, "86": true,
+	"90": true, "91": true, "92": true, "93": true, "94": true, "95": true, "98": true,
+}
+
+// Ограничения E.164: до 15 цифр вместе с кодом страны
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+// Number - разобранный номер телефона
+type Number struct {
+	CountryCode string // без "+"
+	National    string // национальный номер без кода страны и префикса
+}
+
+// E164 - канонический формат "+<код страны><номер>"
+func (n Number) E164() string {
+	return "+" + n.CountryCode + n.National
+}
+
+func (n Number) String() string {
+	return n.E164()
+}
+
+// Variants - записи номера, под которыми он мог сохраниться до нормализации:
+// E.164 и без "+", а для страны по умолчанию еще с национальным префиксом и без кода страны.
+// Национальные записи других стран не добавляются: "91234567" из +852 совпал бы
+// с номером другой страны. Первым идет E.164
+func (n Number) Variants() []string {
+	variants := []string{n.E164(), n.CountryCode + n.National}
+	if n.CountryCode == defaultCountry {
+		if rules := countries[n.CountryCode]; rules.trunk != "" {
+			variants = append(variants, rules.trunk+n.National)
+		}
+		variants = append(variants, n.National)
+	}
+
+	seen := make(map[string]bool, len(variants))
+	unique := variants[:0]
+	for _, variant := range variants {
+		if !seen[variant] {
+			seen[variant] = true
+			unique = append(unique, variant)
+		}
+	}
+	return unique
+}
+
+// Normalize приводит номер к E.164, национальные номера разбираются для страны по умолчанию
+func Normalize(raw string) (string, error) {
+	number, err := ParseDefault(raw)
+	if err != nil {
+		return "", err
+	}
+	return number.E164(), nil
+}
+
+// IsValid - номер разбирается и проходит проверку длины
+func IsValid(raw string) bool {
+	_, err := Normalize(raw)
+	return err == nil
+}
+
+// ParseDefault разбирает номер, национальные номера - для страны по умолчанию
+func ParseDefault(raw string) (Number, error) {
+	return Parse(raw, defaultCountry)
+}
+
+// Parse разбирает номер. defaultCountry - код страны для номеров в национальном формате
+func Parse(raw, defaultCountry string) (Number, error) {
+	digits, international, err := cleanup(raw)
+	if err != nil {
+		return Number{}, err
+	}
+
+	if !international {
+		digits, err = nationalToInternational(digits, defaultCountry)
+		if err != nil {
+			return Number{}, fmt.Errorf("%w: %s: %v", domain.ErrInvalidPhone, raw, err)
+		}
+	}
+
+	if len(digits) < minE164Digits || len(digits) > maxE164Digits {
+		return Number{}, fmt.Errorf("%w: %s: expected %d-%d digits", domain.ErrInvalidPhone, raw, minE164Digits, maxE164Digits)
+	}
+
+	code := countryCode(digits)
+	national := digits[len(code):]
+
+	rules, known := countries[code]
+	if !known {
+		// Правил нумерации страны нет, проверяется только общая длина E.164
+		return Number{CountryCode: code, National: national}, nil
+	}
+
+	if len(national) < rules.minLength || len(national) > rules.maxLength {
+		return Number{}, fmt.Errorf("%w: %s: national number for +%s must have %d-%d digits",
+			domain.ErrInvalidPhone, raw, code, rules.minLength, rules.maxLength)
+	}
+	if rules.leading != "" && !strings.ContainsRune(rules.leading, rune(national[0])) {
+		return Number{}, fmt.Errorf("%w: %s: national number for +%s cannot start with %c",
+			domain.ErrInvalidPhone, raw, code, national[0])
+	}
+
+	return Number{CountryCode: code, National: national}, nil
+}
+
+// cleanup убирает разделители и определяет, указан ли номер в международном формате ("+" или "00")
+func cleanup(raw string) (string, bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false, domain.ErrPhoneRequired
+	}
+
+	var digits strings.Builder
+	international := false
+
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			international = true
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.' || r == '\u00a0':
+		default:
+			return "", false, fmt.Errorf("%w: %s: unexpected character %q", domain.ErrInvalidPhone, raw, r)
+		}
+	}
+
+	result := digits.String()
+	if !international && strings.HasPrefix(result, "00") {
+		return result[2:], true, nil
+	}
+
+	return result, international, nil
+}
+
+// nationalToInternational добавляет код страны к номеру без "+":
+// "89123456789" -> "79123456789", "9123456789" -> "79123456789", "79123456789" остается как есть
+func nationalToInternational(digits, defaultCountry string) (string, error) {
+	rules, ok := countries[defaultCountry]
+	if !ok {
+		return "", fmt.Errorf("unknown default country +%s", defaultCountry)
+	}
+
+	switch {
+	case len(digits) >= rules.minLength && len(digits) <= rules.maxLength:
+		return rules.code + digits, nil
+	case rules.trunk != "" && strings.HasPrefix(digits, rules.trunk) &&
+		len(digits)-len(rules.trunk) >= rules.minLength && len(digits)-len(rules.trunk) <= rules.maxLength:
+		return rules.code + digits[len(rules.trunk):], nil
+	case strings.HasPrefix(digits, rules.code) &&
+		len(digits)-len(rules.code) >= rules.minLength && len(digits)-len(rules.code) <= rules.maxLength:
+		return digits, nil
+	default:
+		return "", fmt.Errorf("not a valid national number for +%s", defaultCountry)
+	}
+}
+
+// countryCode отделяет код страны (1-3 цифры) по длине кодов ITU
+func countryCode(digits string) string {
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1]
+	case twoDigitCodes[digits[:2]]:
+		return digits[:2]
+	default:
+		return digits[:3]
+	}
+}
@@ -0,0 +1,81 @@
+package phone
+
+import (
+	"errors"
+	"reflect"
+	"sms-service/internal/domain"
+	"testing"
+)
+
+func TestParseSplitsCountryCodeByITULength(t *testing.T) {
+	tests := []struct {
+		raw      string
+		code     string
+		national string
+	}{
+		{"+79123456789", "7", "9123456789"},
+		{"+12025550123", "1", "2025550123"},
+		{"+447911123456", "44", "7911123456"},
+		{"+33612345678", "33", "612345678"},
+		{"+5511912345678", "55", "11912345678"},
+		{"+375291234567", "375", "291234567"},
+		{"+35312345678", "353", "12345678"},
+		{"+8613800138000", "86", "13800138000"},
+		{"+85291234567", "852", "91234567"},
+		{"+9647701234567", "964", "7701234567"},
+	}
+
+	for _, tt := range tests {
+		number, err := Parse(tt.raw, "7")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.raw, err)
+			continue
+		}
+		if number.CountryCode != tt.code || number.National != tt.national {
+			t.Errorf("Parse(%q) = +%s %s, want +%s %s", tt.raw, number.CountryCode, number.National, tt.code, tt.national)
+		}
+		if number.E164() != tt.raw {
+			t.Errorf("Parse(%q).E164() = %s", tt.raw, number.E164())
+		}
+	}
+}
+
+func TestParseNationalNumbers(t *testing.T) {
+	for _, raw := range []string{"8 (912) 345-67-89", "+7 912 345 67 89", "9123456789", "79123456789", "0079123456789"} {
+		number, err := Parse(raw, "7")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", raw, err)
+			continue
+		}
+		if number.E164() != "+79123456789" {
+			t.Errorf("Parse(%q) = %s, want +79123456789", raw, number.E164())
+		}
+	}
+
+	for _, raw := range []string{"+7 112 345 67 89", "+375 29 123", "8 912 345 67 8x"} {
+		if _, err := Parse(raw, "7"); !errors.Is(err, domain.ErrInvalidPhone) {
+			t.Errorf("Parse(%q): got %v, want %v", raw, err, domain.ErrInvalidPhone)
+		}
+	}
+}
+
+func TestNumberVariants(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"+79123456789", []string{"+79123456789", "79123456789", "89123456789", "9123456789"}},
+		{"+12025550123", []string{"+12025550123", "12025550123"}},
+		{"+85291234567", []string{"+85291234567", "85291234567"}},
+	}
+
+	for _, tt := range tests {
+		number, err := Parse(tt.raw, "7")
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.raw, err)
+		}
+		if got := number.Variants(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Variants(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Типы target'ов Actions V2 в конфигурации
+const (
+	ActionTargetWebhook = "webhook" // restWebhook: ответ игнорируется, ошибка может прервать вызов
+	ActionTargetCall    = "call"    // restCall: ответ подменяет запрос/ответ метода
+	ActionTargetAsync   = "async"   // restAsync: fire-and-forget
+)
+
+// ActionsConfig - желаемое состояние Actions V2 в Zitadel.
+// Target'ы и execution'ы, которых нет в конфигурации, удаляются только при prune: true
+// и только управляемые: target'ы с именем на managedPrefix и execution'ы, все target'ы
+// которых управляемые. Так provisioner не трогает actions других сервисов инстанса
+type ActionsConfig struct {
+	Prune         bool                    `yaml:"prune"`
+	ManagedPrefix string                  `yaml:"managedPrefix"`
+	Targets       []ActionTargetConfig    `yaml:"targets"`
+	Executions    []ActionExecutionConfig `yaml:"executions"`
+}
+
+// manages - target создан по этой конфигурации, и его можно удалить при prune
+func (c *ActionsConfig) manages(targetName string) bool {
+	return strings.HasPrefix(targetName, c.ManagedPrefix)
+}
+
+// ActionTargetConfig - target, имя служит ключом для сравнения с Zitadel
+type ActionTargetConfig struct {
+	Name             string        `yaml:"name"`
+	Type             string        `yaml:"type"`
+	Endpoint         string        `yaml:"endpoint"`
+	Timeout          time.Duration `yaml:"timeout"`
+	InterruptOnError bool          `yaml:"interruptOnError"`
+}
+
+// ActionExecutionConfig - execution, ссылается на target'ы по имени
+type ActionExecutionConfig struct {
+	Condition ActionConditionConfig `yaml:"condition"`
+	Targets   []string              `yaml:"targets"`
+}
+
+// ActionConditionConfig - задается ровно одно условие
+type ActionConditionConfig struct {
+	Request  *ActionMethodCondition `yaml:"request,omitempty"`
+	Response *ActionMethodCondition `yaml:"response,omitempty"`
+	Event    *ActionEventCondition  `yaml:"event,omitempty"`
+	Function string                 `yaml:"function,omitempty"`
+}
+
+// ActionMethodCondition - условие по gRPC-методу, сервису или всем вызовам
+type ActionMethodCondition struct {
+	Method  string `yaml:"method,omitempty"`
+	Service string `yaml:"service,omitempty"`
+	All     bool   `yaml:"all,omitempty"`
+}
+
+// ActionEventCondition - условие по типу события, группе событий или всем событиям
+type ActionEventCondition struct {
+	Event string `yaml:"event,omitempty"`
+	Group string `yaml:"group,omitempty"`
+	All   bool   `yaml:"all,omitempty"`
+}
+
+// LoadActionsConfig читает и проверяет YAML с target'ами и execution'ами
+func LoadActionsConfig(path string) (*ActionsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read actions config: %w", err)
+	}
+
+	var config ActionsConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse actions config: %w", err)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid actions config %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+func (c *ActionsConfig) validate() error {
+	names := make(map[string]bool)
+
+	for _, target := range c.Targets {
+		if target.Name == "" || target.Endpoint == "" {
+			return fmt.Errorf("target requires name and endpoint")
+		}
+		if names[target.Name] {
+			return fmt.Errorf("duplicate target %s", target.Name)
+		}
+		names[target.Name] = true
+
+		if !c.manages(target.Name) {
+			return fmt.Errorf("target %s: name must start with managedPrefix %q", target.Name, c.ManagedPrefix)
+		}
+
+		switch target.Type {
+		case ActionTargetWebhook, ActionTargetCall, ActionTargetAsync:
+		default:
+			return fmt.Errorf("target %s: unknown type %q (webhook, call, async)", target.Name, target.Type)
+		}
+
+		if target.Timeout <= 0 {
+			return fmt.Errorf("target %s: timeout is required", target.Name)
+		}
+	}
+
+	conditions := make(map[string]bool)
+
+	for _, execution := range c.Executions {
+		key, err := execution.Condition.key()
+		if err != nil {
+			return err
+		}
+		if conditions[key] {
+			return fmt.Errorf("duplicate execution for condition %s", key)
+		}
+		conditions[key] = true
+
+		if len(execution.Targets) == 0 {
+			return fmt.Errorf("execution %s: at least one target is required", key)
+		}
+		for _, name := range execution.Targets {
+			if !names[name] {
+				return fmt.Errorf("execution %s: unknown target %s", key, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// key - каноническое представление условия, по нему execution'ы сравниваются с Zitadel
+func (c ActionConditionConfig) key() (string, error) {
+	var keys []string
+
+	if c.Request != nil {
+		keys = append(keys, methodConditionKey("request", c.Request.Method, c.Request.Service, c.Request.All))
+	}
+	if c.Response != nil {
+		keys = append(keys, methodConditionKey("response", c.Response.Method, c.Response.Service, c.Response.All))
+	}
+	if c.Event != nil {
+		keys = append(keys, eventConditionKey(c.Event.Event, c.Event.Group, c.Event.All))
+	}
+	if c.Function != "" {
+		keys = append(keys, "function:"+c.Function)
+	}
+
+	if len(keys) != 1 {
+		return "", fmt.Errorf("execution condition must set exactly one of request, response, event, function")
+	}
+	if strings.HasSuffix(keys[0], ":") {
+		return "", fmt.Errorf("execution condition %s must set one of method, service, event, group, all", keys[0])
+	}
+	return keys[0], nil
+}
+
+func methodConditionKey(kind, method, service string, all bool) string {
+	switch {
+	case method != "":
+		return kind + ":method:" + method
+	case service != "":
+		return kind + ":service:" + service
+	case all:
+		return kind + ":all"
+	default:
+		return kind + ":"
+	}
+}
+
+func eventConditionKey(event, group string, all bool) string {
+	switch {
+	case event != "":
+		return "event:event:" + event
+	case group != "":
+		return "event:group:" + group
+	case all:
+		return "event:all"
+	default:
+		return "event:"
+	}
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	action "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/action/v2"
+	filter "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/filter/v2"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const actionsPageSize = 100
+
+// ActionsProvisioner приводит target'ы и execution'ы Zitadel к ActionsConfig
+type ActionsProvisioner struct {
+	client action.ActionServiceClient
+}
+
+// NewActionsProvisioner использует клиент и аутентификацию ZitadelService
+func NewActionsProvisioner(zitadelService *ZitadelService) *ActionsProvisioner {
+	return &ActionsProvisioner{
+		client: zitadelService.client.ActionServiceV2(),
+	}
+}
+
+// ActionsChange - одно изменение плана
+type ActionsChange struct {
+	Operation string // create, update, delete
+	Kind      string // target, execution
+	Name      string // имя target'а или ключ условия execution
+	Details   string
+}
+
+func (c ActionsChange) String() string {
+	sign := map[string]string{"create": "+", "update": "~", "delete": "-"}[c.Operation]
+	line := fmt.Sprintf("%s %s %s", sign, c.Kind, c.Name)
+	if c.Details != "" {
+		line += " (" + c.Details + ")"
+	}
+	return line
+}
+
+// ActionsPlan - изменения, которые нужно применить; порядок важен:
+// сначала target'ы, затем execution'ы, в конце удаление лишних управляемых target'ов
+type ActionsPlan struct {
+	Changes []ActionsChange
+
+	config  *ActionsConfig
+	targets map[string]*action.Target // текущие target'ы по имени
+}
+
+// Empty - Zitadel уже в желаемом состоянии
+func (p *ActionsPlan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Plan сравнивает конфигурацию с текущим состоянием Zitadel
+func (p *ActionsProvisioner) Plan(ctx context.Context, config *ActionsConfig) (*ActionsPlan, error) {
+	current, err := p.listTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	executions, err := p.listExecutions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return planActions(config, current, executions), nil
+}
+
+// planActions строит план по конфигурации и текущим target'ам и execution'ам
+func planActions(config *ActionsConfig, current []*action.Target, executions []*action.Execution) *ActionsPlan {
+	plan := &ActionsPlan{
+		config:  config,
+		targets: make(map[string]*action.Target, len(current)),
+	}
+
+	targetNames := make(map[string]string, len(current)) // id -> name
+	for _, target := range current {
+		plan.targets[target.GetName()] = target
+		targetNames[target.GetId()] = target.GetName()
+	}
+
+	desiredTargets := make(map[string]bool, len(config.Targets))
+	for _, desired := range config.Targets {
+		desiredTargets[desired.Name] = true
+
+		existing, exists := plan.targets[desired.Name]
+		if !exists {
+			plan.Changes = append(plan.Changes, ActionsChange{
+				Operation: "create", Kind: "target", Name: desired.Name, Details: describeTarget(desired),
+			})
+			continue
+		}
+
+		if diff := diffTarget(desired, existing); diff != "" {
+			plan.Changes = append(plan.Changes, ActionsChange{
+				Operation: "update", Kind: "target", Name: desired.Name, Details: diff,
+			})
+		}
+	}
+
+	currentExecutions := make(map[string][]string, len(executions)) // ключ условия -> имена target'ов
+	managedExecutions := make(map[string]bool, len(executions))
+	for _, execution := range executions {
+		key := conditionKey(execution.GetCondition())
+		names := make([]string, 0, len(execution.GetTargets()))
+		managed := len(execution.GetTargets()) > 0
+		for _, id := range execution.GetTargets() {
+			name, ok := targetNames[id]
+			if !ok {
+				name = id
+			}
+			names = append(names, name)
+			managed = managed && ok && config.manages(name)
+		}
+		currentExecutions[key] = names
+		managedExecutions[key] = managed
+	}
+
+	desiredExecutions := make(map[string]bool, len(config.Executions))
+	for _, desired := range config.Executions {
+		key, _ := desired.Condition.key()
+		desiredExecutions[key] = true
+
+		existing, exists := currentExecutions[key]
+		switch {
+		case !exists:
+			plan.Changes = append(plan.Changes, ActionsChange{
+				Operation: "create", Kind: "execution", Name: key, Details: "targets: " + strings.Join(desired.Targets, ", "),
+			})
+		case !slices.Equal(existing, desired.Targets):
+			plan.Changes = append(plan.Changes, ActionsChange{
+				Operation: "update", Kind: "execution", Name: key,
+				Details: fmt.Sprintf("targets: %s -> %s", strings.Join(existing, ", "), strings.Join(desired.Targets, ", ")),
+			})
+		}
+	}
+
+	if !config.Prune {
+		return plan
+	}
+
+	for _, execution := range executions {
+		key := conditionKey(execution.GetCondition())
+		if !desiredExecutions[key] && managedExecutions[key] {
+			plan.Changes = append(plan.Changes, ActionsChange{Operation: "delete", Kind: "execution", Name: key})
+		}
+	}
+
+	for _, target := range current {
+		if !desiredTargets[target.GetName()] && config.manages(target.GetName()) {
+			plan.Changes = append(plan.Changes, ActionsChange{Operation: "delete", Kind: "target", Name: target.GetName()})
+		}
+	}
+
+	return plan
+}
+
+// Apply выполняет план. Для созданных target'ов возвращает ключи подписи (имя -> ключ),
+// которые нужно добавить в ZITADEL_SIGNING_KEYS
+func (p *ActionsProvisioner) Apply(ctx context.Context, plan *ActionsPlan) (map[string]string, error) {
+	signingKeys := make(map[string]string)
+	targetIDs := make(map[string]string, len(plan.targets))
+	for name, target := range plan.targets {
+		targetIDs[name] = target.GetId()
+	}
+
+	desiredTargets := make(map[string]ActionTargetConfig, len(plan.config.Targets))
+	for _, target := range plan.config.Targets {
+		desiredTargets[target.Name] = target
+	}
+
+	desiredExecutions := make(map[string]ActionExecutionConfig, len(plan.config.Executions))
+	for _, execution := range plan.config.Executions {
+		key, _ := execution.Condition.key()
+		desiredExecutions[key] = execution
+	}
+
+	currentConditions := make(map[string]*action.Condition)
+	if slices.ContainsFunc(plan.Changes, func(c ActionsChange) bool { return c.Kind == "execution" && c.Operation == "delete" }) {
+		executions, err := p.listExecutions(ctx)
+		if err != nil {
+			return signingKeys, err
+		}
+		for _, execution := range executions {
+			currentConditions[conditionKey(execution.GetCondition())] = execution.GetCondition()
+		}
+	}
+
+	for _, change := range plan.Changes {
+		var err error
+
+		switch {
+		case change.Kind == "target" && change.Operation == "create":
+			var resp *action.CreateTargetResponse
+			resp, err = p.client.CreateTarget(ctx, newCreateTargetRequest(desiredTargets[change.Name]))
+			if err == nil {
+				targetIDs[change.Name] = resp.GetId()
+				signingKeys[change.Name] = resp.GetSigningKey()
+			}
+
+		case change.Kind == "target" && change.Operation == "update":
+			_, err = p.client.UpdateTarget(ctx, newUpdateTargetRequest(targetIDs[change.Name], desiredTargets[change.Name]))
+
+		case change.Kind == "target" && change.Operation == "delete":
+			_, err = p.client.DeleteTarget(ctx, &action.DeleteTargetRequest{Id: targetIDs[change.Name]})
+
+		case change.Kind == "execution" && change.Operation == "delete":
+			// Execution без target'ов Zitadel удаляет
+			_, err = p.client.SetExecution(ctx, &action.SetExecutionRequest{Condition: currentConditions[change.Name]})
+
+		case change.Kind == "execution":
+			desired := desiredExecutions[change.Name]
+			ids := make([]string, 0, len(desired.Targets))
+			for _, name := range desired.Targets {
+				ids = append(ids, targetIDs[name])
+			}
+			_, err = p.client.SetExecution(ctx, &action.SetExecutionRequest{
+				Condition: newCondition(desired.Condition),
+				Targets:   ids,
+			})
+		}
+
+		if err != nil {
+			return signingKeys, fmt.Errorf("failed to %s %s %s: %w", change.Operation, change.Kind, change.Name, err)
+		}
+	}
+
+	return signingKeys, nil
+}
+
+func (p *ActionsProvisioner) listTargets(ctx context.Context) ([]*action.Target, error) {
+	var targets []*action.Target
+
+	for offset := uint64(0); ; offset += actionsPageSize {
+		resp, err := p.client.ListTargets(ctx, &action.ListTargetsRequest{
+			Pagination: &filter.PaginationRequest{Offset: offset, Limit: actionsPageSize, Asc: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list targets: %w", err)
+		}
+
+		targets = append(targets, resp.GetTargets()...)
+		if len(resp.GetTargets()) < actionsPageSize {
+			return targets, nil
+		}
+	}
+}
+
+func (p *ActionsProvisioner) listExecutions(ctx context.Context) ([]*action.Execution, error) {
+	var executions []*action.Execution
+
+	for offset := uint64(0); ; offset += actionsPageSize {
+		resp, err := p.client.ListExecutions(ctx, &action.ListExecutionsRequest{
+			Pagination: &filter.PaginationRequest{Offset: offset, Limit: actionsPageSize, Asc: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list executions: %w", err)
+		}
+
+		executions = append(executions, resp.GetExecutions()...)
+		if len(resp.GetExecutions()) < actionsPageSize {
+			return executions, nil
+		}
+	}
+}
+
+func describeTarget(target ActionTargetConfig) string {
+	return fmt.Sprintf("%s %s, timeout %s, interruptOnError=%t",
+		target.Type, target.Endpoint, target.Timeout, target.InterruptOnError)
+}
+
+// diffTarget описывает расхождения target'а с конфигурацией, пустая строка - совпадает
+func diffTarget(desired ActionTargetConfig, existing *action.Target) string {
+	var diffs []string
+
+	existingType, interrupt := targetType(existing)
+	if existingType != desired.Type {
+		diffs = append(diffs, fmt.Sprintf("type: %s -> %s", existingType, desired.Type))
+	}
+	if interrupt != desired.InterruptOnError {
+		diffs = append(diffs, fmt.Sprintf("interruptOnError: %t -> %t", interrupt, desired.InterruptOnError))
+	}
+	if existing.GetEndpoint() != desired.Endpoint {
+		diffs = append(diffs, fmt.Sprintf("endpoint: %s -> %s", existing.GetEndpoint(), desired.Endpoint))
+	}
+	if timeout := existing.GetTimeout().AsDuration(); timeout != desired.Timeout {
+		diffs = append(diffs, fmt.Sprintf("timeout: %s -> %s", timeout, desired.Timeout))
+	}
+
+	return strings.Join(diffs, "; ")
+}
+
+func targetType(target *action.Target) (string, bool) {
+	switch t := target.GetTargetType().(type) {
+	case *action.Target_RestWebhook:
+		return ActionTargetWebhook, t.RestWebhook.GetInterruptOnError()
+	case *action.Target_RestCall:
+		return ActionTargetCall, t.RestCall.GetInterruptOnError()
+	case *action.Target_RestAsync:
+		return ActionTargetAsync, false
+	default:
+		return "", false
+	}
+}
+
+func newCreateTargetRequest(target ActionTargetConfig) *action.CreateTargetRequest {
+	req := &action.CreateTargetRequest{
+		Name:     target.Name,
+		Endpoint: target.Endpoint,
+		Timeout:  durationpb.New(target.Timeout),
+	}
+
+	switch target.Type {
+	case ActionTargetWebhook:
+		req.TargetType = &action.CreateTargetRequest_RestWebhook{
+			RestWebhook: &action.RESTWebhook{InterruptOnError: target.InterruptOnError},
+		}
+	case ActionTargetCall:
+		req.TargetType = &action.CreateTargetRequest_RestCall{
+			RestCall: &action.RESTCall{InterruptOnError: target.InterruptOnError},
+		}
+	case ActionTargetAsync:
+		req.TargetType = &action.CreateTargetRequest_RestAsync{RestAsync: &action.RESTAsync{}}
+	}
+
+	return req
+}
+
+func newUpdateTargetRequest(id string, target ActionTargetConfig) *action.UpdateTargetRequest {
+	req := &action.UpdateTargetRequest{
+		Id:       id,
+		Endpoint: &target.Endpoint,
+		Timeout:  durationpb.New(target.Timeout),
+	}
+
+	switch target.Type {
+	case ActionTargetWebhook:
+		req.TargetType = &action.UpdateTargetRequest_RestWebhook{
+			RestWebhook: &action.RESTWebhook{InterruptOnError: target.InterruptOnError},
+		}
+	case ActionTargetCall:
+		req.TargetType = &action.UpdateTargetRequest_RestCall{
+			RestCall: &action.RESTCall{InterruptOnError: target.InterruptOnError},
+		}
+	case ActionTargetAsync:
+		req.TargetType = &action.UpdateTargetRequest_RestAsync{RestAsync: &action.RESTAsync{}}
+	}
+
+	return req
+}
+
+func newCondition(condition ActionConditionConfig) *action.Condition {
+	switch {
+	case condition.Request != nil:
+		req := &action.RequestExecution{}
+		switch {
+		case condition.Request.Method != "":
+			req.Condition = &action.RequestExecution_Method{Method: condition.Request.Method}
+		case condition.Request.Service != "":
+			req.Condition = &action.RequestExecution_Service{Service: condition.Request.Service}
+		default:
+			req.Condition = &action.RequestExecution_All{All: true}
+		}
+		return &action.Condition{ConditionType: &action.Condition_Request{Request: req}}
+
+	case condition.Response != nil:
+		resp := &action.ResponseExecution{}
+		switch {
+		case condition.Response.Method != "":
+			resp.Condition = &action.ResponseExecution_Method{Method: condition.Response.Method}
+		case condition.Response.Service != "":
+			resp.Condition = &action.ResponseExecution_Service{Service: condition.Response.Service}
+		default:
+			resp.Condition = &action.ResponseExecution_All{All: true}
+		}
+		return &action.Condition{ConditionType: &action.Condition_Response{Response: resp}}
+
+	case condition.Event != nil:
+		event := &action.EventExecution{}
+		switch {
+		case condition.Event.Event != "":
+			event.Condition = &action.EventExecution_Event{Event: condition.Event.Event}
+		case condition.Event.Group != "":
+			event.Condition = &action.EventExecution_Group{Group: condition.Event.Group}
+		default:
+			event.Condition = &action.EventExecution_All{All: true}
+		}
+		return &action.Condition{ConditionType: &action.Condition_Event{Event: event}}
+
+	default:
+		return &action.Condition{ConditionType: &action.Condition_Function{
+			Function: &action.FunctionExecution{Name: condition.Function},
+		}}
+	}
+}
+
+// conditionKey - ключ условия execution из Zitadel в формате ActionConditionConfig.key
+func conditionKey(condition *action.Condition) string {
+	switch c := condition.GetConditionType().(type) {
+	case *action.Condition_Request:
+		return methodConditionKey("request", c.Request.GetMethod(), c.Request.GetService(), c.Request.GetAll())
+	case *action.Condition_Response:
+		return methodConditionKey("response", c.Response.GetMethod(), c.Response.GetService(), c.Response.GetAll())
+	case *action.Condition_Event:
+		return eventConditionKey(c.Event.GetEvent(), c.Event.GetGroup(), c.Event.GetAll())
+	case *action.Condition_Function:
+		return "function:" + c.Function.GetName()
+	default:
+		return "unknown"
+	}
+}
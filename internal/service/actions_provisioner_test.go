@@ -0,0 +1,162 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	action "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/action/v2"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func webhookTarget(id, name, endpoint string, timeout time.Duration) *action.Target {
+	return &action.Target{
+		Id:       id,
+		Name:     name,
+		Endpoint: endpoint,
+		Timeout:  durationpb.New(timeout),
+		TargetType: &action.Target_RestWebhook{
+			RestWebhook: &action.RESTWebhook{InterruptOnError: true},
+		},
+	}
+}
+
+func methodExecution(method string, targetIDs ...string) *action.Execution {
+	return &action.Execution{
+		Condition: newCondition(ActionConditionConfig{Request: &ActionMethodCondition{Method: method}}),
+		Targets:   targetIDs,
+	}
+}
+
+func TestDiffTarget(t *testing.T) {
+	desired := ActionTargetConfig{
+		Name:             "sms-pre-registration",
+		Type:             ActionTargetWebhook,
+		Endpoint:         "http://sms/api/zitadel/pre-registration",
+		Timeout:          5 * time.Second,
+		InterruptOnError: true,
+	}
+
+	if diff := diffTarget(desired, webhookTarget("1", desired.Name, desired.Endpoint, desired.Timeout)); diff != "" {
+		t.Errorf("identical target: got diff %q", diff)
+	}
+
+	existing := &action.Target{
+		Name:       desired.Name,
+		Endpoint:   "http://old/api/zitadel/pre-registration",
+		Timeout:    durationpb.New(10 * time.Second),
+		TargetType: &action.Target_RestCall{RestCall: &action.RESTCall{InterruptOnError: false}},
+	}
+	want := "type: call -> webhook; interruptOnError: false -> true; " +
+		"endpoint: http://old/api/zitadel/pre-registration -> http://sms/api/zitadel/pre-registration; timeout: 10s -> 5s"
+	if diff := diffTarget(desired, existing); diff != want {
+		t.Errorf("diffTarget:\n got %q\nwant %q", diff, want)
+	}
+}
+
+func TestConditionKeyMatchesConfig(t *testing.T) {
+	conditions := []ActionConditionConfig{
+		{Request: &ActionMethodCondition{Method: "/zitadel.user.v2.UserService/CreateUser"}},
+		{Request: &ActionMethodCondition{Service: "zitadel.user.v2.UserService"}},
+		{Request: &ActionMethodCondition{All: true}},
+		{Response: &ActionMethodCondition{Method: "/zitadel.user.v2.UserService/CreateUser"}},
+		{Response: &ActionMethodCondition{All: true}},
+		{Event: &ActionEventCondition{Event: "user.human.added"}},
+		{Event: &ActionEventCondition{Group: "user.human"}},
+		{Event: &ActionEventCondition{All: true}},
+		{Function: "preuserinfo"},
+	}
+
+	seen := make(map[string]bool)
+	for _, condition := range conditions {
+		want, err := condition.key()
+		if err != nil {
+			t.Fatalf("key(%+v): %v", condition, err)
+		}
+		if got := conditionKey(newCondition(condition)); got != want {
+			t.Errorf("conditionKey = %q, want %q", got, want)
+		}
+		if seen[want] {
+			t.Errorf("duplicate condition key %q", want)
+		}
+		seen[want] = true
+	}
+
+	if got := conditionKey(&action.Condition{}); got != "unknown" {
+		t.Errorf("empty condition: got %q", got)
+	}
+}
+
+func TestPlanActionsOrdering(t *testing.T) {
+	config := &ActionsConfig{
+		Prune:         true,
+		ManagedPrefix: "sms-",
+		Targets: []ActionTargetConfig{
+			{Name: "sms-new", Type: ActionTargetAsync, Endpoint: "http://sms/events", Timeout: time.Second},
+			{Name: "sms-changed", Type: ActionTargetWebhook, Endpoint: "http://sms/new", Timeout: time.Second, InterruptOnError: true},
+		},
+		Executions: []ActionExecutionConfig{
+			{Condition: ActionConditionConfig{Event: &ActionEventCondition{Event: "user.human.added"}}, Targets: []string{"sms-new"}},
+			{Condition: ActionConditionConfig{Request: &ActionMethodCondition{Method: "/m/Changed"}}, Targets: []string{"sms-new", "sms-changed"}},
+		},
+	}
+
+	current := []*action.Target{
+		webhookTarget("t1", "sms-changed", "http://sms/old", time.Second),
+		webhookTarget("t2", "sms-stale", "http://sms/stale", time.Second),
+		webhookTarget("t3", "billing-hook", "http://billing/hook", time.Second),
+	}
+	executions := []*action.Execution{
+		methodExecution("/m/Changed", "t1"),
+		methodExecution("/m/Stale", "t2"),
+		methodExecution("/m/Billing", "t3"),
+		methodExecution("/m/Shared", "t2", "t3"),
+	}
+
+	plan := planActions(config, current, executions)
+
+	var got []string
+	for _, change := range plan.Changes {
+		got = append(got, change.Operation+" "+change.Kind+" "+change.Name)
+	}
+	want := []string{
+		"create target sms-new",
+		"update target sms-changed",
+		"create execution event:event:user.human.added",
+		"update execution request:method:/m/Changed",
+		"delete execution request:method:/m/Stale",
+		"delete target sms-stale",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("plan:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestPlanActionsWithoutPruneKeepsExtras(t *testing.T) {
+	config := &ActionsConfig{
+		Targets: []ActionTargetConfig{
+			{Name: "pre-registration", Type: ActionTargetWebhook, Endpoint: "http://sms/pre", Timeout: time.Second, InterruptOnError: true},
+		},
+	}
+	current := []*action.Target{
+		webhookTarget("t1", "pre-registration", "http://sms/pre", time.Second),
+		webhookTarget("t2", "billing-hook", "http://billing/hook", time.Second),
+	}
+	executions := []*action.Execution{methodExecution("/m/Billing", "t2")}
+
+	if plan := planActions(config, current, executions); !plan.Empty() {
+		t.Errorf("expected empty plan, got %v", plan.Changes)
+	}
+}
+
+func TestActionsConfigRequiresManagedPrefix(t *testing.T) {
+	config := &ActionsConfig{
+		ManagedPrefix: "sms-",
+		Targets: []ActionTargetConfig{
+			{Name: "pre-registration", Type: ActionTargetWebhook, Endpoint: "http://sms/pre", Timeout: time.Second},
+		},
+	}
+	if err := config.validate(); err == nil {
+		t.Error("expected error for target outside managedPrefix")
+	}
+}
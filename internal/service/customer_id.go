@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sms-service/internal/domain"
+)
+
+// CustomerIDMetadataKey - ключ metadata пользователя Zitadel с нашим внутренним ID клиента
+const CustomerIDMetadataKey = "customer_id"
+
+// CustomerIDSource выдает внутренний ID клиента для созданного пользователя
+type CustomerIDSource func(ctx context.Context, userID string) (string, error)
+
+// NewCustomerIDMutator привязывает к пользователю, созданному через CreateUser, внутренний ID клиента.
+// В CreateUserResponse нет поля под произвольные данные, поэтому ID сохраняется в metadata
+// пользователя, а ответ возвращается без изменений. Zitadel повторяет вызов target'а при сбоях,
+// поэтому уже сохраненный ID не перезаписывается. source == nil - ID выводится из user ID (cus_<hex>)
+func NewCustomerIDMutator(zitadelService *ZitadelService, source CustomerIDSource) ResponseMutator {
+	if source == nil {
+		source = deriveCustomerID
+	}
+
+	return func(ctx context.Context, call *domain.ZitadelWebhookRequest, response map[string]interface{}) error {
+		userID, ok := response["id"].(string)
+		if !ok || userID == "" {
+			return fmt.Errorf("created user ID not found in response")
+		}
+
+		existing, found, err := zitadelService.GetUserMetadata(ctx, userID, CustomerIDMetadataKey)
+		if err != nil {
+			return err
+		}
+		if found {
+			log.Printf("Customer ID %s already attached to user %s", existing, userID)
+			return nil
+		}
+
+		customerID, err := source(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get customer ID for user %s: %w", userID, err)
+		}
+
+		if err := zitadelService.SetUserMetadata(ctx, userID, CustomerIDMetadataKey, customerID); err != nil {
+			return err
+		}
+
+		log.Printf("Customer ID %s attached to user %s", customerID, userID)
+		return nil
+	}
+}
+
+// deriveCustomerID выводит ID из user ID, поэтому повторы и параллельные вызовы дают одно значение
+func deriveCustomerID(ctx context.Context, userID string) (string, error) {
+	sum := sha256.Sum256([]byte("customer_id:" + userID))
+	return "cus_" + hex.EncodeToString(sum[:8]), nil
+}
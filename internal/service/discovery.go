@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discoveryTimeout - срок одной загрузки discovery документа в фоне
+const discoveryTimeout = 10 * time.Second
+
+// ProviderMetadata - endpoints issuer'а из /.well-known/openid-configuration
+type ProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCDiscovery загружает и периодически обновляет discovery документ issuer'а.
+// Если issuer недоступен, продолжают использоваться последние загруженные endpoints
+type OIDCDiscovery struct {
+	issuer     string
+	httpClient *http.Client
+	interval   time.Duration
+
+	mu       sync.RWMutex
+	metadata *ProviderMetadata
+
+	fetchMu sync.Mutex // одна загрузка за раз
+}
+
+// NewOIDCDiscovery пытается сразу загрузить документ и запускает обновление раз в interval.
+// Неудачная первая загрузка не ошибка: документ будет загружен при первом обращении
+func NewOIDCDiscovery(issuer string, httpClient *http.Client, interval time.Duration) *OIDCDiscovery {
+	discovery := &OIDCDiscovery{
+		issuer:     strings.TrimSuffix(issuer, "/"),
+		httpClient: httpClient,
+		interval:   interval,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	if err := discovery.refresh(ctx); err != nil {
+		log.Printf("OIDC discovery failed, will retry on first use: %v", err)
+	}
+
+	go discovery.refreshLoop()
+
+	return discovery
+}
+
+// Metadata возвращает endpoints issuer'а, загружая документ, если он еще не загружен.
+// Загрузка ограничена discoveryTimeout независимо от срока запроса
+func (d *OIDCDiscovery) Metadata(ctx context.Context) (*ProviderMetadata, error) {
+	if metadata := d.cached(); metadata != nil {
+		return metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	d.fetchMu.Lock()
+	defer d.fetchMu.Unlock()
+
+	// Пока ждали блокировку, документ мог загрузить другой запрос
+	if metadata := d.cached(); metadata != nil {
+		return metadata, nil
+	}
+
+	if err := d.fetch(ctx); err != nil {
+		return nil, err
+	}
+	return d.cached(), nil
+}
+
+func (d *OIDCDiscovery) cached() *ProviderMetadata {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.metadata
+}
+
+func (d *OIDCDiscovery) refreshLoop() {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+		if err := d.refresh(ctx); err != nil {
+			log.Printf("Failed to refresh OIDC discovery, keeping previous endpoints: %v", err)
+		}
+		cancel()
+	}
+}
+
+// refresh загружает документ заново, даже если он уже загружен
+func (d *OIDCDiscovery) refresh(ctx context.Context) error {
+	d.fetchMu.Lock()
+	defer d.fetchMu.Unlock()
+
+	return d.fetch(ctx)
+}
+
+// fetch загружает документ; вызывается под fetchMu
+func (d *OIDCDiscovery) fetch(ctx context.Context) error {
+	discoveryURL := d.issuer + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", discoveryURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery request: %w", err)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch OIDC discovery: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OIDC discovery failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var metadata ProviderMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return fmt.Errorf("failed to parse OIDC discovery: %w", err)
+	}
+
+	// Документ чужого issuer'а означает ошибку конфигурации или подмену (OpenID Connect Discovery, 4.3)
+	if strings.TrimSuffix(metadata.Issuer, "/") != d.issuer {
+		return fmt.Errorf("OIDC discovery issuer mismatch: expected %s, got %s", d.issuer, metadata.Issuer)
+	}
+	if metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return fmt.Errorf("OIDC discovery for %s has no token_endpoint or jwks_uri", d.issuer)
+	}
+
+	d.mu.Lock()
+	d.metadata = &metadata
+	d.mu.Unlock()
+
+	log.Printf("OIDC discovery loaded from %s", discoveryURL)
+	return nil
+}
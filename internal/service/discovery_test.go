@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDiscovery отдает discovery документ, который тест может менять на ходу
+type fakeDiscovery struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	body     func(issuer string) any
+	requests int
+}
+
+func newFakeDiscovery(t *testing.T) *fakeDiscovery {
+	t.Helper()
+
+	d := &fakeDiscovery{status: http.StatusOK, body: discoveryDocument("/oauth/v2/token")}
+	d.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.requests++
+		w.WriteHeader(d.status)
+		json.NewEncoder(w).Encode(d.body(d.server.URL))
+	}))
+	t.Cleanup(d.server.Close)
+	return d
+}
+
+// discoveryDocument - документ issuer'а с заданным token endpoint
+func discoveryDocument(tokenPath string) func(issuer string) any {
+	return func(issuer string) any {
+		return ProviderMetadata{
+			Issuer:        issuer,
+			TokenEndpoint: issuer + tokenPath,
+			JWKSURI:       issuer + "/oauth/v2/keys",
+		}
+	}
+}
+
+func (d *fakeDiscovery) serve(status int, body func(issuer string) any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = status
+	d.body = body
+}
+
+func (d *fakeDiscovery) requestCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.requests
+}
+
+func TestOIDCDiscoveryRefresh(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeDiscovery(t)
+	discovery := NewOIDCDiscovery(fake.server.URL+"/", fake.server.Client(), time.Hour)
+
+	metadata, err := discovery.Metadata(ctx)
+	if err != nil {
+		t.Fatalf("Metadata: %v", err)
+	}
+	if metadata.TokenEndpoint != fake.server.URL+"/oauth/v2/token" {
+		t.Errorf("TokenEndpoint = %s", metadata.TokenEndpoint)
+	}
+	if got := fake.requestCount(); got != 1 {
+		t.Errorf("requests = %d, want 1: Metadata must use the document loaded at startup", got)
+	}
+
+	fake.serve(http.StatusOK, discoveryDocument("/oauth/v3/token"))
+	if err := discovery.refresh(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if metadata, _ := discovery.Metadata(ctx); metadata.TokenEndpoint != fake.server.URL+"/oauth/v3/token" {
+		t.Errorf("TokenEndpoint after refresh = %s", metadata.TokenEndpoint)
+	}
+}
+
+func TestOIDCDiscoveryFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   func(issuer string) any
+	}{
+		{"server error", http.StatusServiceUnavailable, discoveryDocument("/oauth/v2/token")},
+		{"not json", http.StatusOK, func(issuer string) any { return "not a document" }},
+		{"issuer mismatch", http.StatusOK, func(issuer string) any {
+			return ProviderMetadata{Issuer: "https://evil.example.com", TokenEndpoint: issuer + "/token", JWKSURI: issuer + "/keys"}
+		}},
+		{"no token endpoint", http.StatusOK, func(issuer string) any {
+			return ProviderMetadata{Issuer: issuer, JWKSURI: issuer + "/keys"}
+		}},
+		{"no jwks_uri", http.StatusOK, func(issuer string) any {
+			return ProviderMetadata{Issuer: issuer, TokenEndpoint: issuer + "/token"}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newFakeDiscovery(t)
+
+			// Неудачная загрузка при старте не ошибка: документ загружается при первом обращении
+			fake.serve(tt.status, tt.body)
+			discovery := NewOIDCDiscovery(fake.server.URL, fake.server.Client(), time.Hour)
+			if _, err := discovery.Metadata(ctx); err == nil {
+				t.Fatal("Metadata: expected error")
+			}
+
+			fake.serve(http.StatusOK, discoveryDocument("/oauth/v2/token"))
+			if _, err := discovery.Metadata(ctx); err != nil {
+				t.Fatalf("Metadata after issuer recovered: %v", err)
+			}
+
+			// Неудачное обновление оставляет прежние endpoints
+			fake.serve(tt.status, tt.body)
+			if err := discovery.refresh(ctx); err == nil {
+				t.Error("refresh: expected error")
+			}
+			metadata, err := discovery.Metadata(ctx)
+			if err != nil {
+				t.Fatalf("Metadata after failed refresh: %v", err)
+			}
+			if metadata.Issuer != fake.server.URL || metadata.TokenEndpoint != fake.server.URL+"/oauth/v2/token" {
+				t.Errorf("metadata after failed refresh = %+v", metadata)
+			}
+		})
+	}
+}
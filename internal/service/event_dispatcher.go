@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sms-service/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EventHandlerFunc обрабатывает одно событие Zitadel
+type EventHandlerFunc func(ctx context.Context, event *domain.ZitadelEvent) error
+
+// EventDeduplicator помнит уже выполненные обработчики событий, чтобы повторная доставка не срабатывала дважды
+type EventDeduplicator interface {
+	// Claim резервирует ключ; false - обработчик уже выполнен или выполняется
+	Claim(ctx context.Context, key string) (bool, error)
+	// Release снимает резерв, если обработка не удалась, чтобы повтор мог ее выполнить
+	Release(ctx context.Context, key string) error
+}
+
+// EventDispatcher направляет события обработчикам по типу события
+type EventDispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string][]namedEventHandler
+	dedup    EventDeduplicator
+}
+
+type namedEventHandler struct {
+	name    string
+	handler EventHandlerFunc
+}
+
+func NewEventDispatcher(dedup EventDeduplicator) *EventDispatcher {
+	return &EventDispatcher{
+		handlers: make(map[string][]namedEventHandler),
+		dedup:    dedup,
+	}
+}
+
+// Register добавляет обработчик для типа события; обработчики вызываются в порядке регистрации.
+// По имени помнится, что обработчик уже выполнен, поэтому оно не должно меняться между версиями
+func (d *EventDispatcher) Register(eventType, name string, handler EventHandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers[eventType] = append(d.handlers[eventType], namedEventHandler{name: name, handler: handler})
+}
+
+// Dispatch вызывает обработчики события. Каждый обработчик резервируется отдельно: при повторной
+// доставке уже выполненные пропускаются, а повторяются только те, что завершились ошибкой
+func (d *EventDispatcher) Dispatch(ctx context.Context, event *domain.ZitadelEvent) error {
+	d.mu.RLock()
+	handlers := d.handlers[event.EventType]
+	d.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		log.Printf("No handlers for Zitadel event %s (aggregate=%s)", event.EventType, event.AggregateID)
+		return nil
+	}
+
+	eventKey := event.IdempotencyKey()
+
+	var errs []error
+	for _, h := range handlers {
+		key := eventKey + ":" + h.name
+
+		claimed, err := d.dedup.Claim(ctx, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to claim event %s: %w", key, err))
+			continue
+		}
+		if !claimed {
+			log.Printf("Skipping duplicate Zitadel event %s (%s)", key, event.EventType)
+			continue
+		}
+
+		if err := h.handler(ctx, event); err != nil {
+			if releaseErr := d.dedup.Release(ctx, key); releaseErr != nil {
+				log.Printf("Failed to release event %s: %v", key, releaseErr)
+			}
+			errs = append(errs, fmt.Errorf("failed to handle %s event %s: %w", event.EventType, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewEventDeduplicator создает хранилище обработанных событий согласно EVENT_DEDUP_STORE:
+// memory (по умолчанию), redis. Ключи живут EVENT_DEDUP_TTL (по умолчанию 24h)
+func NewEventDeduplicator() (EventDeduplicator, error) {
+	ttl, err := durationFromEnv("EVENT_DEDUP_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := os.Getenv("EVENT_DEDUP_STORE")
+
+	switch backend {
+	case "", "memory":
+		log.Printf("Using in-memory event deduplication")
+		return NewMemoryEventDeduplicator(ttl), nil
+	case "redis":
+		client, err := NewRedisClient()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using Redis event deduplication")
+		return NewRedisEventDeduplicator(client, ttl), nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_DEDUP_STORE: %s", backend)
+	}
+}
+
+// MemoryEventDeduplicator хранит ключи событий в памяти процесса
+type MemoryEventDeduplicator struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time
+}
+
+func NewMemoryEventDeduplicator(ttl time.Duration) *MemoryEventDeduplicator {
+	dedup := &MemoryEventDeduplicator{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+	}
+
+	go dedup.cleanup()
+
+	return dedup
+}
+
+func (d *MemoryEventDeduplicator) Claim(ctx context.Context, key string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if expiresAt, exists := d.seen[key]; exists && time.Now().Before(expiresAt) {
+		return false, nil
+	}
+
+	d.seen[key] = time.Now().Add(d.ttl)
+	return true, nil
+}
+
+func (d *MemoryEventDeduplicator) Release(ctx context.Context, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.seen, key)
+	return nil
+}
+
+func (d *MemoryEventDeduplicator) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.mu.Lock()
+		now := time.Now()
+		for key, expiresAt := range d.seen {
+			if now.After(expiresAt) {
+				delete(d.seen, key)
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// RedisEventDeduplicator хранит ключи событий в Redis: zitadel:event:<instanceID>:<aggregateID>:<sequence>:<обработчик>
+type RedisEventDeduplicator struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisEventDeduplicator(client *redis.Client, ttl time.Duration) *RedisEventDeduplicator {
+	return &RedisEventDeduplicator{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (d *RedisEventDeduplicator) Claim(ctx context.Context, key string) (bool, error) {
+	claimed, err := d.client.SetNX(ctx, "zitadel:event:"+key, time.Now().Unix(), d.ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event in redis: %w", err)
+	}
+	return claimed, nil
+}
+
+func (d *RedisEventDeduplicator) Release(ctx context.Context, key string) error {
+	if err := d.client.Del(ctx, "zitadel:event:"+key).Err(); err != nil {
+		return fmt.Errorf("failed to release event in redis: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sms-service/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestDispatchRetriesOnlyFailedHandlers(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := NewEventDispatcher(NewMemoryEventDeduplicator(time.Hour))
+
+	calls := map[string]int{}
+	fail := true
+	dispatcher.Register(domain.EventUserHumanAdded, "first", func(ctx context.Context, event *domain.ZitadelEvent) error {
+		calls["first"]++
+		return nil
+	})
+	dispatcher.Register(domain.EventUserHumanAdded, "second", func(ctx context.Context, event *domain.ZitadelEvent) error {
+		calls["second"]++
+		if fail {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	event := &domain.ZitadelEvent{InstanceID: "instance-1", AggregateID: "user-1", Sequence: 7, EventType: domain.EventUserHumanAdded}
+
+	if err := dispatcher.Dispatch(ctx, event); err == nil {
+		t.Fatal("first delivery: expected handler error")
+	}
+
+	fail = false
+	if err := dispatcher.Dispatch(ctx, event); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if err := dispatcher.Dispatch(ctx, event); err != nil {
+		t.Fatalf("duplicate delivery: %v", err)
+	}
+
+	if calls["first"] != 1 {
+		t.Errorf("first handler ran %d times, want 1", calls["first"])
+	}
+	if calls["second"] != 2 {
+		t.Errorf("second handler ran %d times, want 2", calls["second"])
+	}
+}
+
+func TestDispatchSeparatesInstances(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := NewEventDispatcher(NewMemoryEventDeduplicator(time.Hour))
+
+	calls := 0
+	dispatcher.Register(domain.EventUserHumanAdded, "count", func(ctx context.Context, event *domain.ZitadelEvent) error {
+		calls++
+		return nil
+	})
+
+	for _, instanceID := range []string{"instance-1", "instance-2"} {
+		event := &domain.ZitadelEvent{InstanceID: instanceID, AggregateID: "user-1", Sequence: 1, EventType: domain.EventUserHumanAdded}
+		if err := dispatcher.Dispatch(ctx, event); err != nil {
+			t.Fatalf("Dispatch: %v", err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
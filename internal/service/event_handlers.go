@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sms-service/internal/domain"
+)
+
+// RegisterDefaultEventHandlers подключает базовые обработчики событий регистрации и входа
+func RegisterDefaultEventHandlers(d *EventDispatcher) {
+	d.Register(domain.EventUserHumanAdded, "log-user-added", handleUserHumanAdded)
+	d.Register(domain.EventUserHumanPhoneChanged, "log-phone-changed", handleUserHumanPhoneChanged)
+	d.Register(domain.EventUserHumanPhoneVerified, "log-phone-verified", handleUserHumanPhoneVerified)
+	d.Register(domain.EventSessionAdded, "log-session-added", handleSessionAdded)
+}
+
+func handleUserHumanAdded(ctx context.Context, event *domain.ZitadelEvent) error {
+	var payload domain.UserHumanAddedPayload
+	if err := event.DecodePayload(&payload); err != nil {
+		return err
+	}
+
+	log.Printf("User registered: user_id=%s username=%s phone=%s org=%s",
+		event.AggregateID, payload.UserName, payload.Phone, event.ResourceOwner)
+	return nil
+}
+
+func handleUserHumanPhoneChanged(ctx context.Context, event *domain.ZitadelEvent) error {
+	var payload domain.UserHumanPhoneChangedPayload
+	if err := event.DecodePayload(&payload); err != nil {
+		return err
+	}
+
+	log.Printf("User phone changed: user_id=%s phone=%s", event.AggregateID, payload.Phone)
+	return nil
+}
+
+func handleUserHumanPhoneVerified(ctx context.Context, event *domain.ZitadelEvent) error {
+	log.Printf("User phone verified: user_id=%s", event.AggregateID)
+	return nil
+}
+
+func handleSessionAdded(ctx context.Context, event *domain.ZitadelEvent) error {
+	var payload domain.SessionAddedPayload
+	if err := event.DecodePayload(&payload); err != nil {
+		return err
+	}
+
+	ip := ""
+	if payload.UserAgent != nil {
+		ip = payload.UserAgent.IP
+	}
+
+	log.Printf("Session created: session_id=%s user_id=%s ip=%s", event.AggregateID, event.UserID, ip)
+	return nil
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IntrospectionCache хранит результаты introspection по хешу токена
+type IntrospectionCache interface {
+	// Get возвращает сохраненный результат; nil - в кеше нет
+	Get(ctx context.Context, tokenHash string) (*IntrospectionResponse, error)
+	Set(ctx context.Context, tokenHash string, resp *IntrospectionResponse, ttl time.Duration) error
+	// Delete удаляет результат для одного токена
+	Delete(ctx context.Context, tokenHash string) error
+	// DeleteSubject удаляет результаты всех токенов пользователя
+	DeleteSubject(ctx context.Context, subject string) error
+}
+
+// IntrospectionCachePolicy - сроки хранения результатов
+type IntrospectionCachePolicy struct {
+	TTL         time.Duration // для активных токенов, но не дольше exp
+	NegativeTTL time.Duration // для неактивных токенов
+}
+
+// ttlFor возвращает срок хранения результата; 0 - не кешировать
+func (p IntrospectionCachePolicy) ttlFor(resp *IntrospectionResponse, now time.Time) time.Duration {
+	if !resp.Active {
+		return p.NegativeTTL
+	}
+
+	ttl := p.TTL
+	if resp.ExpiresAt > 0 {
+		if untilExpiry := time.Unix(resp.ExpiresAt, 0).Sub(now); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+	return max(ttl, 0)
+}
+
+// NewIntrospectionCache создает кеш согласно INTROSPECTION_CACHE: memory, redis, off.
+// По умолчанию redis, если задан REDIS_ADDR, иначе memory. Кеш в памяти сбрасывается при
+// выходе только на своей реплике, остальные отдают отозванный токен как активный до истечения
+// записи; при нескольких репликах нужен redis (или общий TOKEN_REVOCATION_STORE=redis,
+// который VerifyToken проверяет поверх кеша).
+// INTROSPECTION_CACHE_TTL (по умолчанию 5m) - срок для активных токенов,
+// INTROSPECTION_NEGATIVE_TTL (по умолчанию 30s) - для неактивных
+func NewIntrospectionCache() (IntrospectionCache, IntrospectionCachePolicy, error) {
+	var policy IntrospectionCachePolicy
+
+	ttl, err := durationFromEnv("INTROSPECTION_CACHE_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, policy, err
+	}
+	negativeTTL, err := durationFromEnv("INTROSPECTION_NEGATIVE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, policy, err
+	}
+	policy = IntrospectionCachePolicy{TTL: ttl, NegativeTTL: negativeTTL}
+
+	backend := os.Getenv("INTROSPECTION_CACHE")
+	if backend == "" && os.Getenv("REDIS_ADDR") != "" {
+		backend = "redis"
+	}
+
+	switch backend {
+	case "", "memory":
+		log.Printf("Using in-memory introspection cache (ttl=%s, negative=%s), invalidation is local to this replica", ttl, negativeTTL)
+		return NewMemoryIntrospectionCache(), policy, nil
+	case "redis":
+		client, err := NewRedisClient()
+		if err != nil {
+			return nil, policy, err
+		}
+		log.Printf("Using Redis introspection cache (ttl=%s, negative=%s)", ttl, negativeTTL)
+		return NewRedisIntrospectionCache(client, ttl), policy, nil
+	case "off":
+		log.Printf("Introspection cache disabled")
+		return nil, policy, nil
+	default:
+		return nil, policy, fmt.Errorf("unknown INTROSPECTION_CACHE: %s", backend)
+	}
+}
+
+// MemoryIntrospectionCache хранит результаты в памяти процесса
+type MemoryIntrospectionCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryIntrospection
+}
+
+type memoryIntrospection struct {
+	resp      IntrospectionResponse
+	expiresAt time.Time
+}
+
+func NewMemoryIntrospectionCache() *MemoryIntrospectionCache {
+	cache := &MemoryIntrospectionCache{
+		entries: make(map[string]memoryIntrospection),
+	}
+
+	go cache.cleanup()
+
+	return cache
+}
+
+func (c *MemoryIntrospectionCache) Get(ctx context.Context, tokenHash string) (*IntrospectionResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[tokenHash]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+
+	resp := entry.resp
+	return &resp, nil
+}
+
+func (c *MemoryIntrospectionCache) Set(ctx context.Context, tokenHash string, resp *IntrospectionResponse, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[tokenHash] = memoryIntrospection{resp: *resp, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (c *MemoryIntrospectionCache) Delete(ctx context.Context, tokenHash string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, tokenHash)
+	return nil
+}
+
+func (c *MemoryIntrospectionCache) DeleteSubject(ctx context.Context, subject string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for hash, entry := range c.entries {
+		if entry.resp.Subject == subject {
+			delete(c.entries, hash)
+		}
+	}
+	return nil
+}
+
+func (c *MemoryIntrospectionCache) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.mu.Lock()
+		now := time.Now()
+		for hash, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, hash)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// RedisIntrospectionCache хранит результаты в Redis, общий для всех реплик.
+// Ключи: introspect:<hash> - JSON результата, introspect:sub:<subject> - хеши токенов пользователя
+type RedisIntrospectionCache struct {
+	client   *redis.Client
+	indexTTL time.Duration // срок индекса пользователя - не меньше максимального TTL активной записи
+}
+
+func NewRedisIntrospectionCache(client *redis.Client, indexTTL time.Duration) *RedisIntrospectionCache {
+	return &RedisIntrospectionCache{
+		client:   client,
+		indexTTL: indexTTL,
+	}
+}
+
+func introspectionKey(tokenHash string) string {
+	return "introspect:" + tokenHash
+}
+
+func introspectionSubjectKey(subject string) string {
+	return "introspect:sub:" + subject
+}
+
+func (c *RedisIntrospectionCache) Get(ctx context.Context, tokenHash string) (*IntrospectionResponse, error) {
+	raw, err := c.client.Get(ctx, introspectionKey(tokenHash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read introspection from redis: %w", err)
+	}
+
+	var resp IntrospectionResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode cached introspection: %w", err)
+	}
+	return &resp, nil
+}
+
+func (c *RedisIntrospectionCache) Set(ctx context.Context, tokenHash string, resp *IntrospectionResponse, ttl time.Duration) error {
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode introspection: %w", err)
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, introspectionKey(tokenHash), raw, ttl)
+	if resp.Active && resp.Subject != "" {
+		subjectKey := introspectionSubjectKey(resp.Subject)
+		pipe.SAdd(ctx, subjectKey, tokenHash)
+		pipe.Expire(ctx, subjectKey, c.indexTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store introspection in redis: %w", err)
+	}
+	return nil
+}
+
+func (c *RedisIntrospectionCache) Delete(ctx context.Context, tokenHash string) error {
+	if err := c.client.Del(ctx, introspectionKey(tokenHash)).Err(); err != nil {
+		return fmt.Errorf("failed to delete introspection from redis: %w", err)
+	}
+	return nil
+}
+
+func (c *RedisIntrospectionCache) DeleteSubject(ctx context.Context, subject string) error {
+	subjectKey := introspectionSubjectKey(subject)
+
+	hashes, err := c.client.SMembers(ctx, subjectKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read introspection index from redis: %w", err)
+	}
+
+	keys := make([]string, 0, len(hashes)+1)
+	for _, hash := range hashes {
+		keys = append(keys, introspectionKey(hash))
+	}
+	keys = append(keys, subjectKey)
+
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete introspections from redis: %w", err)
+	}
+	return nil
+}
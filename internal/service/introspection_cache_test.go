@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIntrospectionCachePolicyTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	policy := IntrospectionCachePolicy{TTL: 5 * time.Minute, NegativeTTL: 30 * time.Second}
+
+	tests := []struct {
+		name string
+		resp IntrospectionResponse
+		want time.Duration
+	}{
+		{"inactive", IntrospectionResponse{Active: false}, 30 * time.Second},
+		{"active without exp", IntrospectionResponse{Active: true}, 5 * time.Minute},
+		{"exp after ttl", IntrospectionResponse{Active: true, ExpiresAt: now.Add(time.Hour).Unix()}, 5 * time.Minute},
+		{"exp before ttl", IntrospectionResponse{Active: true, ExpiresAt: now.Add(time.Minute).Unix()}, time.Minute},
+		{"already expired", IntrospectionResponse{Active: true, ExpiresAt: now.Add(-time.Minute).Unix()}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.ttlFor(&tt.resp, now); got != tt.want {
+				t.Errorf("ttlFor = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// introspectionCacheHarness - кеш под контрактным тестом и способ сдвинуть его время
+type introspectionCacheHarness struct {
+	cache IntrospectionCache
+	// wait переводит время кеша на d вперед
+	wait func(d time.Duration)
+}
+
+func runIntrospectionCacheContract(t *testing.T, newHarness func(t *testing.T) introspectionCacheHarness) {
+	active := func(subject string) *IntrospectionResponse {
+		return &IntrospectionResponse{Active: true, Subject: subject}
+	}
+
+	t.Run("set, get, expire", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		if err := h.cache.Set(ctx, "h1", active("user-1"), time.Second); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if err := h.cache.Set(ctx, "h2", &IntrospectionResponse{Active: false}, 3*time.Second); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+
+		expectCachedIntrospection(t, h.cache, "h1", true)
+		expectCachedIntrospection(t, h.cache, "h2", false)
+
+		h.wait(2 * time.Second)
+		expectNoCachedIntrospection(t, h.cache, "h1")
+		expectCachedIntrospection(t, h.cache, "h2", false)
+
+		if err := h.cache.Delete(ctx, "h2"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		expectNoCachedIntrospection(t, h.cache, "h2")
+	})
+
+	t.Run("delete subject", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		for hash, subject := range map[string]string{"h1": "user-1", "h2": "user-1", "h3": "user-2"} {
+			if err := h.cache.Set(ctx, hash, active(subject), time.Minute); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+		}
+
+		if err := h.cache.DeleteSubject(ctx, "user-1"); err != nil {
+			t.Fatalf("DeleteSubject: %v", err)
+		}
+		expectNoCachedIntrospection(t, h.cache, "h1")
+		expectNoCachedIntrospection(t, h.cache, "h2")
+		expectCachedIntrospection(t, h.cache, "h3", true)
+
+		// Токены, закешированные после сброса, снова попадают в индекс пользователя
+		if err := h.cache.Set(ctx, "h4", active("user-1"), time.Minute); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if err := h.cache.DeleteSubject(ctx, "user-1"); err != nil {
+			t.Fatalf("DeleteSubject: %v", err)
+		}
+		expectNoCachedIntrospection(t, h.cache, "h4")
+
+		if err := h.cache.DeleteSubject(ctx, "nobody"); err != nil {
+			t.Errorf("DeleteSubject(nobody): %v", err)
+		}
+	})
+}
+
+func expectCachedIntrospection(t *testing.T, cache IntrospectionCache, hash string, active bool) {
+	t.Helper()
+
+	resp, err := cache.Get(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", hash, err)
+	}
+	if resp == nil || resp.Active != active {
+		t.Errorf("Get(%s) = %+v, want active=%v", hash, resp, active)
+	}
+}
+
+func expectNoCachedIntrospection(t *testing.T, cache IntrospectionCache, hash string) {
+	t.Helper()
+
+	resp, err := cache.Get(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", hash, err)
+	}
+	if resp != nil {
+		t.Errorf("Get(%s) = %+v, want nil", hash, resp)
+	}
+}
+
+func TestMemoryIntrospectionCacheContract(t *testing.T) {
+	runIntrospectionCacheContract(t, func(t *testing.T) introspectionCacheHarness {
+		cache := NewMemoryIntrospectionCache()
+		return introspectionCacheHarness{
+			cache: cache,
+			wait: func(d time.Duration) {
+				cache.mu.Lock()
+				defer cache.mu.Unlock()
+				for hash, entry := range cache.entries {
+					entry.expiresAt = entry.expiresAt.Add(-d)
+					cache.entries[hash] = entry
+				}
+			},
+		}
+	})
+}
+
+func TestRedisIntrospectionCacheContract(t *testing.T) {
+	runIntrospectionCacheContract(t, func(t *testing.T) introspectionCacheHarness {
+		server, client := newTestRedis(t)
+		return introspectionCacheHarness{
+			cache: NewRedisIntrospectionCache(client, time.Hour),
+			wait:  server.FastForward,
+		}
+	})
+}
+
+func TestRedisIntrospectionCacheSubjectIndex(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestRedis(t)
+	cache := NewRedisIntrospectionCache(client, time.Hour)
+
+	if err := cache.Set(ctx, "h1", &IntrospectionResponse{Active: true, Subject: "user-1"}, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cache.Set(ctx, "h2", &IntrospectionResponse{Active: false, Subject: "user-1"}, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Неактивные результаты не индексируются: отзывать в них нечего
+	members, err := server.Members(introspectionSubjectKey("user-1"))
+	if err != nil {
+		t.Fatalf("Members: %v", err)
+	}
+	if len(members) != 1 || members[0] != "h1" {
+		t.Errorf("subject index = %v, want [h1]", members)
+	}
+	if ttl := server.TTL(introspectionSubjectKey("user-1")); ttl != time.Hour {
+		t.Errorf("subject index TTL = %s, want %s", ttl, time.Hour)
+	}
+
+	if err := cache.DeleteSubject(ctx, "user-1"); err != nil {
+		t.Fatalf("DeleteSubject: %v", err)
+	}
+	if server.Exists(introspectionSubjectKey("user-1")) {
+		t.Error("subject index survived DeleteSubject")
+	}
+}
+
+func TestIntrospectTokenCaching(t *testing.T) {
+	ctx := context.Background()
+	z := newFakeZitadel(t)
+	oidc := newTestOIDCService(t, z)
+	oidc.SetIntrospectionCache(NewMemoryIntrospectionCache(), IntrospectionCachePolicy{TTL: time.Minute, NegativeTTL: time.Minute})
+
+	// Неизвестный issuer'у токен кешируется как неактивный
+	const opaque = "opaque-token"
+	if resp, err := oidc.IntrospectToken(ctx, opaque); err != nil || resp.Active {
+		t.Fatalf("unknown token: active=%v, err=%v", resp != nil && resp.Active, err)
+	}
+	z.mu.Lock()
+	z.subject[opaque] = "user-1"
+	z.mu.Unlock()
+	if resp, _ := oidc.IntrospectToken(ctx, opaque); resp.Active {
+		t.Error("negative result was not cached")
+	}
+
+	if err := oidc.InvalidateToken(ctx, opaque); err != nil {
+		t.Fatalf("InvalidateToken: %v", err)
+	}
+	if resp, _ := oidc.IntrospectToken(ctx, opaque); !resp.Active {
+		t.Fatal("token still inactive after InvalidateToken")
+	}
+
+	// Отзыв в Zitadel не виден, пока запись в кеше, и виден после сброса по пользователю
+	if err := oidc.RevokeToken(ctx, opaque, TokenTypeHintAccessToken); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if resp, _ := oidc.IntrospectToken(ctx, opaque); !resp.Active {
+		t.Error("active result was not cached")
+	}
+	if err := oidc.InvalidateSubject(ctx, "user-1"); err != nil {
+		t.Fatalf("InvalidateSubject: %v", err)
+	}
+	if resp, _ := oidc.IntrospectToken(ctx, opaque); resp.Active {
+		t.Error("revoked token still active after InvalidateSubject")
+	}
+}
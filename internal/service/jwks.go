@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// errUnknownSigningKey - ключа с таким kid нет даже после повторной загрузки JWKS
+var errUnknownSigningKey = errors.New("unknown token signing key")
+
+// errSigningKeyNotRefetched - ключа с таким kid нет в кеше, а повторная загрузка JWKS
+// отложена из-за minRefetch: ключ мог появиться при ротации, отвергать токен рано
+var errSigningKeyNotRefetched = errors.New("token signing key not in cache, JWKS refetch throttled")
+
+// JWKSCache хранит публичные ключи подписи issuer'а.
+// Ключи перечитываются раз в ttl, а при неизвестном kid (ротация ключей в Zitadel) - сразу,
+// но не чаще minRefetch, чтобы токены с мусорным kid не заваливали issuer запросами.
+// Адрес набора ключей берется при каждой загрузке: он может смениться в discovery документе
+type JWKSCache struct {
+	url        func(ctx context.Context) (string, error)
+	httpClient *http.Client
+	ttl        time.Duration
+	minRefetch time.Duration
+
+	mu        sync.RWMutex
+	keys      jose.JSONWebKeySet
+	fetchedAt time.Time
+
+	fetchMu sync.Mutex // одна загрузка за раз
+}
+
+func NewJWKSCache(url func(ctx context.Context) (string, error), httpClient *http.Client, ttl, minRefetch time.Duration) *JWKSCache {
+	return &JWKSCache{
+		url:        url,
+		httpClient: httpClient,
+		ttl:        ttl,
+		minRefetch: minRefetch,
+	}
+}
+
+// Key возвращает ключ подписи по kid. Пустой kid подходит, только если ключ в наборе один.
+// Неизвестный kid дает errUnknownSigningKey, только если набор загружен после промаха;
+// если загрузку не пустил minRefetch - errSigningKeyNotRefetched
+func (c *JWKSCache) Key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	key, fetchedAt := c.lookup(kid)
+	if key != nil && c.fresh(fetchedAt) {
+		return key, nil
+	}
+
+	fetched, err := c.refresh(ctx, key == nil, fetchedAt)
+	if err != nil {
+		if key != nil {
+			// Устаревший, но известный ключ лучше отказа, пока issuer недоступен
+			log.Printf("Failed to refresh JWKS, using cached keys: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	if key, _ = c.lookup(kid); key == nil {
+		if !fetched {
+			return nil, fmt.Errorf("%w: kid=%q", errSigningKeyNotRefetched, kid)
+		}
+		return nil, fmt.Errorf("%w: kid=%q", errUnknownSigningKey, kid)
+	}
+	return key, nil
+}
+
+// lookup ищет ключ в кеше и возвращает время загрузки набора, в котором искали
+func (c *JWKSCache) lookup(kid string) (*jose.JSONWebKey, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if kid == "" {
+		if len(c.keys.Keys) == 1 {
+			return &c.keys.Keys[0], c.fetchedAt
+		}
+		return nil, c.fetchedAt
+	}
+
+	keys := c.keys.Key(kid)
+	if len(keys) == 0 {
+		return nil, c.fetchedAt
+	}
+	return &keys[0], c.fetchedAt
+}
+
+// fresh - набор, загруженный в fetchedAt, моложе ttl
+func (c *JWKSCache) fresh(fetchedAt time.Time) bool {
+	return !fetchedAt.IsZero() && time.Since(fetchedAt) < c.ttl
+}
+
+// refresh загружает JWKS. unknownKid - загрузка из-за неизвестного kid, ограничивается minRefetch.
+// seen - время загрузки набора, в котором искал вызывающий. Возвращает true, если после него
+// набор загружен заново (этим или параллельным запросом)
+func (c *JWKSCache) refresh(ctx context.Context, unknownKid bool, seen time.Time) (bool, error) {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	c.mu.RLock()
+	fetchedAt := c.fetchedAt
+	c.mu.RUnlock()
+
+	// Пока ждали блокировку, ключи мог загрузить другой запрос
+	if fetchedAt.After(seen) {
+		return true, nil
+	}
+	since := time.Since(fetchedAt)
+	if since < c.minRefetch || (!unknownKid && since < c.ttl) {
+		return false, nil
+	}
+
+	jwksURL, err := c.url(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", jwksURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("JWKS fetch failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return false, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	log.Printf("JWKS loaded from %s: %d keys", jwksURL, len(keys.Keys))
+	return true, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// fakeJWKS отдает текущий набор ключей и считает загрузки
+type fakeJWKS struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	keys    []jose.JSONWebKey
+	fetches int
+	fail    bool
+}
+
+func newFakeJWKS(t *testing.T, kids ...string) *fakeJWKS {
+	t.Helper()
+
+	j := &fakeJWKS{}
+	j.rotate(t, kids...)
+	j.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		j.fetches++
+		if j.fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: j.keys})
+	}))
+	t.Cleanup(j.server.Close)
+	return j
+}
+
+// rotate заменяет набор ключами с указанными kid
+func (j *fakeJWKS) rotate(t *testing.T, kids ...string) {
+	t.Helper()
+
+	keys := make([]jose.JSONWebKey, 0, len(kids))
+	for _, kid := range kids {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		keys = append(keys, jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"})
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+}
+
+func (j *fakeJWKS) fetchCount() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.fetches
+}
+
+func (j *fakeJWKS) setFailing(fail bool) {
+	j.mu.Lock()
+	j.fail = fail
+	j.mu.Unlock()
+}
+
+func newTestJWKSCache(j *fakeJWKS) *JWKSCache {
+	url := func(ctx context.Context) (string, error) { return j.server.URL, nil }
+	return NewJWKSCache(url, j.server.Client(), time.Hour, time.Minute)
+}
+
+// age состаривает загруженный набор, не дожидаясь minRefetch и ttl
+func (c *JWKSCache) age(d time.Duration) {
+	c.mu.Lock()
+	c.fetchedAt = c.fetchedAt.Add(-d)
+	c.mu.Unlock()
+}
+
+func expectJWKSKey(t *testing.T, cache *JWKSCache, kid string) {
+	t.Helper()
+
+	key, err := cache.Key(context.Background(), kid)
+	if err != nil {
+		t.Fatalf("Key(%q): %v", kid, err)
+	}
+	if kid != "" && key.KeyID != kid {
+		t.Errorf("Key(%q) returned kid %q", kid, key.KeyID)
+	}
+}
+
+func expectJWKSError(t *testing.T, cache *JWKSCache, kid string, want error) {
+	t.Helper()
+
+	if _, err := cache.Key(context.Background(), kid); !errors.Is(err, want) {
+		t.Errorf("Key(%q): got %v, want %v", kid, err, want)
+	}
+}
+
+func TestJWKSCacheRotation(t *testing.T) {
+	j := newFakeJWKS(t, "k1")
+	cache := newTestJWKSCache(j)
+
+	expectJWKSKey(t, cache, "k1")
+	expectJWKSKey(t, cache, "")
+	if got := j.fetchCount(); got != 1 {
+		t.Fatalf("fetches = %d, want 1", got)
+	}
+
+	// Новый kid сразу после загрузки: повторная загрузка отложена, токен не отвергается окончательно
+	j.rotate(t, "k1", "k2")
+	expectJWKSError(t, cache, "k2", errSigningKeyNotRefetched)
+	if got := j.fetchCount(); got != 1 {
+		t.Errorf("fetches within minRefetch = %d, want 1", got)
+	}
+
+	// После minRefetch неизвестный kid загружает набор заново
+	cache.age(2 * time.Minute)
+	expectJWKSKey(t, cache, "k2")
+	if got := j.fetchCount(); got != 2 {
+		t.Errorf("fetches after rotation = %d, want 2", got)
+	}
+	expectJWKSError(t, cache, "", errSigningKeyNotRefetched)
+
+	// Отказ только после настоящей повторной загрузки
+	cache.age(2 * time.Minute)
+	expectJWKSError(t, cache, "bogus", errUnknownSigningKey)
+	if got := j.fetchCount(); got != 3 {
+		t.Errorf("fetches for unknown kid = %d, want 3", got)
+	}
+}
+
+func TestJWKSCacheExpiry(t *testing.T) {
+	j := newFakeJWKS(t, "k1")
+	cache := newTestJWKSCache(j)
+
+	expectJWKSKey(t, cache, "k1")
+
+	// Известный ключ из свежего набора не загружается заново
+	cache.age(30 * time.Minute)
+	expectJWKSKey(t, cache, "k1")
+	if got := j.fetchCount(); got != 1 {
+		t.Errorf("fetches within ttl = %d, want 1", got)
+	}
+
+	// Набор старше ttl перечитывается, а при недоступном issuer остается прежний ключ
+	cache.age(time.Hour)
+	j.setFailing(true)
+	expectJWKSKey(t, cache, "k1")
+	if got := j.fetchCount(); got != 2 {
+		t.Errorf("fetches after ttl = %d, want 2", got)
+	}
+
+	// Неизвестный kid при недоступном issuer - ошибка загрузки, а не отказ по ключу
+	cache.age(2 * time.Minute)
+	_, err := cache.Key(context.Background(), "k2")
+	if err == nil || errors.Is(err, errUnknownSigningKey) || errors.Is(err, errSigningKeyNotRefetched) {
+		t.Errorf("Key with failing issuer: got %v, want fetch error", err)
+	}
+}
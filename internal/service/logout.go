@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sms-service/internal/domain"
+)
+
+// sessionTerminator завершает сессии Zitadel (ZitadelService)
+type sessionTerminator interface {
+	DeleteSession(ctx context.Context, sessionID string) error
+	DeleteUserSessions(ctx context.Context, userID string) (int, error)
+}
+
+// LogoutService завершает сессии пользователя: отзывает токены в Zitadel,
+// закрывает цепочки refresh token'ов для /api/auth/refresh и удаляет сессии Zitadel.
+// Вход по OTP выполняется через token exchange без сессии Zitadel, поэтому выданные токены
+// отзываются не удалением сессий, а учетом сервиса: семейства refresh token'ов в RefreshTokenStore
+// (их последние токены отзываются и в Zitadel) и локальный список отзыва access token'ов в VerifyToken
+type LogoutService struct {
+	oidc          *OIDCService
+	zitadel       sessionTerminator
+	refreshTokens *RefreshTokenRotator
+}
+
+func NewLogoutService(oidc *OIDCService, zitadel sessionTerminator, refreshTokens *RefreshTokenRotator) *LogoutService {
+	return &LogoutService{
+		oidc:          oidc,
+		zitadel:       zitadel,
+		refreshTokens: refreshTokens,
+	}
+}
+
+// Logout завершает одну сессию. Нужен хотя бы один из токенов.
+// Сессия Zitadel берется из sid активного access token'а; у токенов из token exchange его нет.
+// Ошибки отдельных шагов не прерывают остальные и возвращаются вместе
+func (s *LogoutService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	if accessToken == "" && refreshToken == "" {
+		return domain.ErrTokenRequired
+	}
+
+	var errs []error
+
+	// Introspection до отзыва: после него токен уже неактивен
+	var sessionID string
+	var expiresAt int64
+	if accessToken != "" {
+		introspection, err := s.oidc.IntrospectToken(ctx, accessToken)
+		if err != nil {
+			errs = append(errs, err)
+		} else if introspection.Active {
+			sessionID = introspection.SessionID
+			expiresAt = introspection.ExpiresAt
+		}
+	}
+
+	if refreshToken != "" {
+		if _, err := s.refreshTokens.Revoke(ctx, refreshToken); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.oidc.RevokeToken(ctx, refreshToken, TokenTypeHintRefreshToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if accessToken != "" {
+		if err := s.oidc.RevokeToken(ctx, accessToken, TokenTypeHintAccessToken); err != nil {
+			errs = append(errs, err)
+		}
+		// Локально проверенный JWT не видит отзыв в Zitadel
+		if err := s.oidc.MarkTokenRevoked(ctx, accessToken, expiresAt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if sessionID != "" {
+		if err := s.zitadel.DeleteSession(ctx, sessionID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Иначе отозванный токен еще будет активен из кеша introspection
+	for _, token := range []string{accessToken, refreshToken} {
+		if token == "" {
+			continue
+		}
+		if err := s.oidc.InvalidateToken(ctx, token); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogoutEverywhere завершает все сессии владельца access token'а: закрывает все отслеживаемые
+// цепочки refresh token'ов (и отзывает их последние токены в Zitadel), заносит все уже выданные
+// access token'ы пользователя в локальный список отзыва, удаляет его сессии в Zitadel
+// и отзывает переданные токены.
+// Возвращает число удаленных сессий Zitadel
+func (s *LogoutService) LogoutEverywhere(ctx context.Context, accessToken, refreshToken string) (int, error) {
+	if accessToken == "" {
+		return 0, domain.ErrTokenRequired
+	}
+
+	introspection, err := s.oidc.IntrospectToken(ctx, accessToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to identify user: %w", err)
+	}
+	if !introspection.Active || introspection.Subject == "" {
+		return 0, domain.ErrTokenInactive
+	}
+	userID := introspection.Subject
+
+	log.Printf("Logging out user %s everywhere", userID)
+
+	var errs []error
+
+	if err := s.oidc.MarkSubjectRevoked(ctx, userID); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := s.refreshTokens.RevokeUser(ctx, userID); err != nil {
+		errs = append(errs, err)
+	}
+
+	deleted, err := s.zitadel.DeleteUserSessions(ctx, userID)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if refreshToken != "" {
+		if err := s.oidc.RevokeToken(ctx, refreshToken, TokenTypeHintRefreshToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := s.oidc.RevokeToken(ctx, accessToken, TokenTypeHintAccessToken); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := s.oidc.InvalidateSubject(ctx, userID); err != nil {
+		errs = append(errs, err)
+	}
+
+	return deleted, errors.Join(errs...)
+}
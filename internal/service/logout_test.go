@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sms-service/internal/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// fakeZitadel - issuer с discovery, JWKS, introspection и revocation endpoints.
+// Как и настоящий Zitadel для токенов из token exchange, он не знает о выходе на других
+// устройствах: access token'ы остаются активными до exp, если их не отозвали явно
+type fakeZitadel struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu      sync.Mutex
+	subject map[string]string // access token -> sub
+	revoked map[string]bool
+}
+
+func newFakeZitadel(t *testing.T) *fakeZitadel {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	z := &fakeZitadel{
+		key:     key,
+		subject: make(map[string]string),
+		revoked: make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ProviderMetadata{
+			Issuer:                z.server.URL,
+			TokenEndpoint:         z.server.URL + "/oauth/v2/token",
+			IntrospectionEndpoint: z.server.URL + "/oauth/v2/introspect",
+			RevocationEndpoint:    z.server.URL + "/oauth/v2/revoke",
+			JWKSURI:               z.server.URL + "/oauth/v2/keys",
+		})
+	})
+	mux.HandleFunc("/oauth/v2/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/oauth/v2/introspect", func(w http.ResponseWriter, r *http.Request) {
+		token := r.PostFormValue("token")
+
+		z.mu.Lock()
+		sub, known := z.subject[token]
+		active := known && !z.revoked[token]
+		z.mu.Unlock()
+
+		resp := IntrospectionResponse{Active: active}
+		if active {
+			resp.Subject = sub
+			resp.ExpiresAt = time.Now().Add(time.Hour).Unix()
+			resp.IssuedAt = time.Now().Add(-time.Minute).Unix()
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc("/oauth/v2/revoke", func(w http.ResponseWriter, r *http.Request) {
+		z.mu.Lock()
+		z.revoked[r.PostFormValue("token")] = true
+		z.mu.Unlock()
+	})
+	mux.HandleFunc("/oauth/v2/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+	})
+
+	z.server = httptest.NewServer(mux)
+	t.Cleanup(z.server.Close)
+
+	return z
+}
+
+// accessToken выпускает подписанный JWT пользователя, выданный минуту назад
+func (z *fakeZitadel) accessToken(t *testing.T, subject string) string {
+	t.Helper()
+
+	now := time.Now()
+	return z.signedToken(t, jwt.Claims{
+		Issuer:   z.server.URL,
+		Subject:  subject,
+		Audience: jwt.Audience{"test-client"},
+		IssuedAt: jwt.NewNumericDate(now.Add(-time.Minute)),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		ID:       newTokenFamily(),
+	})
+}
+
+// signedToken подписывает произвольные claims ключом issuer'а
+func (z *fakeZitadel) signedToken(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: z.key},
+		(&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), "test"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("failed to sign access token: %v", err)
+	}
+
+	z.mu.Lock()
+	z.subject[token] = claims.Subject
+	z.mu.Unlock()
+
+	return token
+}
+
+func (z *fakeZitadel) isRevoked(token string) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.revoked[token]
+}
+
+// noSessions - у пользователя, вошедшего по token exchange, нет сессий Zitadel
+type noSessions struct{}
+
+func (noSessions) DeleteSession(ctx context.Context, sessionID string) error { return nil }
+
+func (noSessions) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	return 0, nil
+}
+
+func newTestOIDCService(t *testing.T, z *fakeZitadel) *OIDCService {
+	t.Helper()
+
+	t.Setenv("ZITADEL_ISSUER", z.server.URL)
+	t.Setenv("ZITADEL_CLIENT_ID", "test-client")
+	t.Setenv("ZITADEL_CLIENT_SECRET", "test-secret")
+	t.Setenv("JWT_VERIFY_LOCAL", "true")
+
+	oidc, err := NewOIDCService()
+	if err != nil {
+		t.Fatalf("NewOIDCService: %v", err)
+	}
+	oidc.SetTokenRevocations(NewMemoryTokenRevocations(time.Hour))
+	return oidc
+}
+
+func TestLogoutEverywhereLogsOutOtherDevices(t *testing.T) {
+	ctx := context.Background()
+	z := newFakeZitadel(t)
+	oidc := newTestOIDCService(t, z)
+
+	sealer, err := NewRefreshTokenSealer([]byte("test"))
+	if err != nil {
+		t.Fatalf("NewRefreshTokenSealer: %v", err)
+	}
+	rotator := NewRefreshTokenRotator(oidc, NewMemoryRefreshTokenStore(sealer, time.Hour))
+	logout := NewLogoutService(oidc, noSessions{}, rotator)
+
+	phone := &TokenResponse{AccessToken: z.accessToken(t, "user-1"), RefreshToken: "refresh-phone"}
+	laptop := &TokenResponse{AccessToken: z.accessToken(t, "user-1"), RefreshToken: "refresh-laptop"}
+	other := &TokenResponse{AccessToken: z.accessToken(t, "user-2"), RefreshToken: "refresh-other"}
+	for userID, tokens := range map[string]*TokenResponse{"user-1": phone, "user-2": other} {
+		if err := rotator.Track(ctx, userID, tokens); err != nil {
+			t.Fatalf("Track: %v", err)
+		}
+	}
+	if err := rotator.Track(ctx, "user-1", laptop); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+
+	if resp, err := oidc.VerifyToken(ctx, laptop.AccessToken); err != nil || !resp.Active {
+		t.Fatalf("laptop token before logout: active=%v, err=%v", resp != nil && resp.Active, err)
+	}
+
+	if _, err := logout.LogoutEverywhere(ctx, phone.AccessToken, phone.RefreshToken); err != nil {
+		t.Fatalf("LogoutEverywhere: %v", err)
+	}
+
+	resp, err := oidc.VerifyToken(ctx, laptop.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if resp.Active {
+		t.Error("laptop access token is still active after logout everywhere")
+	}
+
+	if _, err := rotator.Refresh(ctx, laptop.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenRevoked) {
+		t.Errorf("laptop refresh: got %v, want %v", err, domain.ErrRefreshTokenRevoked)
+	}
+	if !z.isRevoked(laptop.RefreshToken) {
+		t.Error("laptop refresh token was not revoked at the issuer")
+	}
+
+	resp, err = oidc.VerifyToken(ctx, other.AccessToken)
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if !resp.Active {
+		t.Error("another user's access token was revoked")
+	}
+}
+
+func TestLogoutRevokesAccessTokenLocally(t *testing.T) {
+	ctx := context.Background()
+	z := newFakeZitadel(t)
+	oidc := newTestOIDCService(t, z)
+
+	sealer, err := NewRefreshTokenSealer([]byte("test"))
+	if err != nil {
+		t.Fatalf("NewRefreshTokenSealer: %v", err)
+	}
+	rotator := NewRefreshTokenRotator(oidc, NewMemoryRefreshTokenStore(sealer, time.Hour))
+	logout := NewLogoutService(oidc, noSessions{}, rotator)
+
+	phone := z.accessToken(t, "user-1")
+	laptop := z.accessToken(t, "user-1")
+
+	if err := logout.Logout(ctx, phone, ""); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	if resp, err := oidc.VerifyToken(ctx, phone); err != nil || resp.Active {
+		t.Errorf("logged out token: active=%v, err=%v", resp != nil && resp.Active, err)
+	}
+	if resp, err := oidc.VerifyToken(ctx, laptop); err != nil || !resp.Active {
+		t.Errorf("other device token: active=%v, err=%v", resp != nil && resp.Active, err)
+	}
+}
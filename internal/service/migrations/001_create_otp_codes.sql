@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS otp_codes (
+    phone             TEXT PRIMARY KEY,
+    code              TEXT        NOT NULL,
+    attempts          INTEGER     NOT NULL DEFAULT 0,
+    expires_at        TIMESTAMPTZ NOT NULL,
+    message_id        TEXT,
+    provider          TEXT        NOT NULL DEFAULT '',
+    status            TEXT        NOT NULL,
+    status_updated_at TIMESTAMPTZ NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS otp_codes_message_id_idx ON otp_codes (message_id);
+CREATE INDEX IF NOT EXISTS otp_codes_expires_at_idx ON otp_codes (expires_at);
+
+-- DLR, пришедшие раньше, чем провайдер ответил на отправку
+CREATE TABLE IF NOT EXISTS otp_early_receipts (
+    message_id  TEXT PRIMARY KEY,
+    status      TEXT        NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS otp_audit (
+    id         BIGSERIAL PRIMARY KEY,
+    phone      TEXT        NOT NULL,
+    event      TEXT        NOT NULL,
+    details    TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS otp_audit_phone_created_at_idx ON otp_audit (phone, created_at);
+CREATE INDEX IF NOT EXISTS otp_audit_created_at_idx ON otp_audit (created_at);
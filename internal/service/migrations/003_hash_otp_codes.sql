@@ -0,0 +1,4 @@
+-- Коды хранятся как HMAC (OTPHasher); действующие plaintext коды сбрасываются
+DELETE FROM otp_codes;
+
+ALTER TABLE otp_codes RENAME COLUMN code TO code_hash;
@@ -0,0 +1,8 @@
+-- Коды привязаны к назначению (register, login, phone_change); ключ - (phone, purpose)
+DELETE FROM otp_codes;
+
+ALTER TABLE otp_codes ADD COLUMN purpose TEXT NOT NULL;
+ALTER TABLE otp_codes DROP CONSTRAINT otp_codes_pkey;
+ALTER TABLE otp_codes ADD PRIMARY KEY (phone, purpose);
+
+ALTER TABLE otp_audit ADD COLUMN purpose TEXT NOT NULL DEFAULT '';
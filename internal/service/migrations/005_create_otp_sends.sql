@@ -0,0 +1,8 @@
+-- История отправок OTP для квот и паузы между отправками (OTPSendPolicy)
+CREATE TABLE IF NOT EXISTS otp_sends (
+    id      BIGSERIAL PRIMARY KEY,
+    phone   TEXT        NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS otp_sends_phone_sent_at_idx ON otp_sends (phone, sent_at);
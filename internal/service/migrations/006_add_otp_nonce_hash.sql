@@ -0,0 +1,2 @@
+-- HMAC nonce клиента: статус доставки отдается только знающему nonce из send-otp
+ALTER TABLE otp_codes ADD COLUMN nonce_hash TEXT NOT NULL DEFAULT '';
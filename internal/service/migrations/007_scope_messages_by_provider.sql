@@ -0,0 +1,7 @@
+-- Message ID уникален только в пределах провайдера: индексы и ранние DLR - по паре (provider, message_id)
+DROP INDEX IF EXISTS otp_codes_message_id_idx;
+CREATE INDEX IF NOT EXISTS otp_codes_provider_message_id_idx ON otp_codes (provider, message_id);
+
+ALTER TABLE otp_early_receipts ADD COLUMN provider TEXT NOT NULL DEFAULT '';
+ALTER TABLE otp_early_receipts DROP CONSTRAINT otp_early_receipts_pkey;
+ALTER TABLE otp_early_receipts ADD PRIMARY KEY (provider, message_id);
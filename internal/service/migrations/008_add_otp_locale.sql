@@ -0,0 +1,2 @@
+-- Язык SMS: по нему текст собирается заново при повторной отправке через другого провайдера
+ALTER TABLE otp_codes ADD COLUMN locale TEXT NOT NULL DEFAULT '';
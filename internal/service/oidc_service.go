@@ -3,13 +3,19 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"sms-service/internal/domain"
 	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 )
 
 type OIDCService struct {
@@ -18,8 +24,20 @@ type OIDCService struct {
 	introspectionClientID     string // Client ID для API application (introspection)
 	introspectionClientSecret string // Client Secret для API application (introspection)
 	issuer                    string
-	tokenURL                  string
+	discovery                 *OIDCDiscovery
 	httpClient                *http.Client
+
+	// Локальная проверка JWT access token'ов
+	verifyLocally bool
+	audiences     []string // допустимые aud, достаточно совпадения одного
+	jwks          *JWKSCache
+
+	// Кеш introspection, nil - выключен
+	introspectionCache  IntrospectionCache
+	introspectionPolicy IntrospectionCachePolicy
+
+	// Локальный список отзыва для VerifyToken, nil - выключен
+	revocations TokenRevocations
 }
 
 type TokenResponse struct {
@@ -31,8 +49,9 @@ type TokenResponse struct {
 	Scope        string `json:"scope"`
 }
 
+// NewOIDCService настраивает клиента issuer'а ZITADEL_ISSUER (например https://auth.example.com).
+// Endpoints берутся из discovery документа и перечитываются раз в OIDC_DISCOVERY_REFRESH_INTERVAL (по умолчанию 1h)
 func NewOIDCService() (*OIDCService, error) {
-	zitadelDomain := os.Getenv("ZITADEL_DOMAIN")
 	clientID := os.Getenv("ZITADEL_CLIENT_ID")
 	clientSecret := os.Getenv("ZITADEL_CLIENT_SECRET")
 
@@ -44,9 +63,59 @@ func NewOIDCService() (*OIDCService, error) {
 		return nil, fmt.Errorf("ZITADEL_CLIENT_ID is required")
 	}
 
-	// Формируем issuer URL
-	issuer := fmt.Sprintf("http://%s:8080", zitadelDomain)
-	tokenURL := fmt.Sprintf("%s/oauth/v2/token", issuer)
+	issuer := strings.TrimSuffix(os.Getenv("ZITADEL_ISSUER"), "/")
+	if issuer == "" {
+		// Прежняя схема адреса: Zitadel на ZITADEL_DOMAIN, порт 8080, без TLS
+		zitadelDomain := os.Getenv("ZITADEL_DOMAIN")
+		if zitadelDomain == "" {
+			return nil, fmt.Errorf("ZITADEL_ISSUER is required")
+		}
+		issuer = fmt.Sprintf("http://%s:8080", zitadelDomain)
+		log.Printf("ZITADEL_ISSUER is not set, using %s", issuer)
+	}
+
+	discoveryInterval, err := durationFromEnv("OIDC_DISCOVERY_REFRESH_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	// Ключи подписи кешируются на JWKS_CACHE_TTL, но отзыв от этого не зависит: локально проверенный JWT
+	// действует до exp. Отзыв через этот сервис закрывается локальным списком (SetTokenRevocations),
+	// а токен, отозванный напрямую в Zitadel (консоль, API, удаление пользователя), принимается до exp -
+	// окно отзыва равно сроку access token'а в настройках приложения Zitadel. Если оно недопустимо,
+	// JWT_VERIFY_LOCAL=false включает introspection на каждую проверку (с учетом INTROSPECTION_CACHE_TTL)
+	jwksTTL, err := durationFromEnv("JWKS_CACHE_TTL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	jwksMinRefetch, err := durationFromEnv("JWKS_MIN_REFETCH_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	httpTimeout, err := durationFromEnv("OIDC_HTTP_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// По умолчанию токены выпускаются для web application, его client ID и есть aud
+	audiences := []string{clientID}
+	if raw := os.Getenv("ZITADEL_TOKEN_AUDIENCES"); raw != "" {
+		audiences = strings.Split(raw, ",")
+		for i := range audiences {
+			audiences[i] = strings.TrimSpace(audiences[i])
+		}
+	}
+
+	// Запросы к issuer'у идут из обработчиков, зависший Zitadel не должен держать их дольше OIDC_HTTP_TIMEOUT
+	httpClient := &http.Client{Timeout: httpTimeout}
+	discovery := NewOIDCDiscovery(issuer, httpClient, discoveryInterval)
+
+	jwksURL := func(ctx context.Context) (string, error) {
+		metadata, err := discovery.Metadata(ctx)
+		if err != nil {
+			return "", err
+		}
+		return metadata.JWKSURI, nil
+	}
 
 	return &OIDCService{
 		clientID:                  clientID,
@@ -54,8 +123,11 @@ func NewOIDCService() (*OIDCService, error) {
 		introspectionClientID:     introspectionClientID,
 		introspectionClientSecret: introspectionClientSecret,
 		issuer:                    issuer,
-		tokenURL:                  tokenURL,
-		httpClient:                &http.Client{},
+		discovery:                 discovery,
+		httpClient:                httpClient,
+		verifyLocally:             os.Getenv("JWT_VERIFY_LOCAL") != "false",
+		audiences:                 audiences,
+		jwks:                      NewJWKSCache(jwksURL, httpClient, jwksTTL, jwksMinRefetch),
 	}, nil
 }
 
@@ -79,7 +151,12 @@ func (s *OIDCService) ExchangeUserIDForTokens(ctx context.Context, userID, actor
 	// Запрашиваем JWT токен
 	data.Set("requested_token_type", "urn:ietf:params:oauth:token-type:jwt")
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.tokenURL, strings.NewReader(data.Encode()))
+	metadata, err := s.discovery.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", metadata.TokenEndpoint, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token request: %w", err)
 	}
@@ -114,14 +191,216 @@ func (s *OIDCService) ExchangeUserIDForTokens(ctx context.Context, userID, actor
 	return &tokenResp, nil
 }
 
-// IntrospectToken проверяет валидность токена через introspection endpoint
+// oauthError - тело ошибки token endpoint (RFC 6749, 5.2)
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// RefreshTokens обменивает refresh token на новые токены (grant_type=refresh_token).
+// Если в Zitadel включена ротация, в ответе будет новый refresh token, а старый перестанет действовать.
+// Недействительный токен - domain.ErrRefreshTokenInvalid
+func (s *OIDCService) RefreshTokens(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+
+	metadata, err := s.discovery.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", metadata.TokenEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh request: %w", err)
+	}
+
+	req.SetBasicAuth(s.clientID, s.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr oauthError
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error == "invalid_grant" {
+			log.Printf("Refresh token rejected by issuer: %s", oauthErr.ErrorDescription)
+			return nil, domain.ErrRefreshTokenInvalid
+		}
+		log.Printf("Token refresh failed: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+
+	log.Printf("Tokens refreshed successfully, expires_in: %d", tokenResp.ExpiresIn)
+
+	return &tokenResp, nil
+}
+
+// Подсказки типа токена для RevokeToken (RFC 7009)
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// RevokeToken отзывает access или refresh token через revocation endpoint.
+// Отзыв refresh token'а в Zitadel делает недействительными и выпущенные по нему access token'ы.
+// Неизвестный или уже отозванный токен ошибкой не считается (RFC 7009, 2.2)
+func (s *OIDCService) RevokeToken(ctx context.Context, token, tokenTypeHint string) error {
+	metadata, err := s.discovery.Metadata(ctx)
+	if err != nil {
+		return err
+	}
+	if metadata.RevocationEndpoint == "" {
+		return fmt.Errorf("issuer %s does not advertise revocation_endpoint", s.issuer)
+	}
+
+	data := url.Values{}
+	data.Set("token", token)
+	if tokenTypeHint != "" {
+		data.Set("token_type_hint", tokenTypeHint)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", metadata.RevocationEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create revoke request: %w", err)
+	}
+
+	// Токены выпущены для web application, им же и отзываются
+	req.SetBasicAuth(s.clientID, s.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Token revocation failed: status=%d, body=%s", resp.StatusCode, string(body))
+		return fmt.Errorf("token revocation failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	log.Printf("Token revoked (%s)", tokenTypeHint)
+	return nil
+}
+
+// SetIntrospectionCache включает кеширование результатов IntrospectToken
+func (s *OIDCService) SetIntrospectionCache(cache IntrospectionCache, policy IntrospectionCachePolicy) {
+	s.introspectionCache = cache
+	s.introspectionPolicy = policy
+}
+
+// SetTokenRevocations включает проверку локального списка отзыва в VerifyToken
+func (s *OIDCService) SetTokenRevocations(revocations TokenRevocations) {
+	s.revocations = revocations
+}
+
+// MarkTokenRevoked заносит токен в локальный список отзыва до expiresAt (unix, 0 - неизвестен)
+func (s *OIDCService) MarkTokenRevoked(ctx context.Context, token string, expiresAt int64) error {
+	if s.revocations == nil {
+		return nil
+	}
+
+	var until time.Time
+	if expiresAt > 0 {
+		until = time.Unix(expiresAt, 0)
+	}
+	if err := s.revocations.RevokeToken(ctx, hashToken(token), until); err != nil {
+		return fmt.Errorf("failed to mark token revoked: %w", err)
+	}
+	return nil
+}
+
+// MarkSubjectRevoked заносит в локальный список отзыва все уже выданные токены пользователя
+func (s *OIDCService) MarkSubjectRevoked(ctx context.Context, subject string) error {
+	if s.revocations == nil {
+		return nil
+	}
+	if err := s.revocations.RevokeSubject(ctx, subject, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark tokens of %s revoked: %w", subject, err)
+	}
+	return nil
+}
+
+// InvalidateToken убирает токен из кеша introspection, например после отзыва
+func (s *OIDCService) InvalidateToken(ctx context.Context, token string) error {
+	if s.introspectionCache == nil {
+		return nil
+	}
+	if err := s.introspectionCache.Delete(ctx, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to invalidate cached introspection: %w", err)
+	}
+	return nil
+}
+
+// InvalidateSubject убирает из кеша introspection все токены пользователя
+func (s *OIDCService) InvalidateSubject(ctx context.Context, subject string) error {
+	if s.introspectionCache == nil {
+		return nil
+	}
+	if err := s.introspectionCache.DeleteSubject(ctx, subject); err != nil {
+		return fmt.Errorf("failed to invalidate cached introspections of %s: %w", subject, err)
+	}
+	return nil
+}
+
+// IntrospectToken проверяет валидность токена через introspection endpoint.
+// Результат кешируется по хешу токена (см. SetIntrospectionCache); сбой кеша не мешает проверке
 func (s *OIDCService) IntrospectToken(ctx context.Context, token string) (*IntrospectionResponse, error) {
-	introspectURL := fmt.Sprintf("%s/oauth/v2/introspect", s.issuer)
+	if s.introspectionCache == nil {
+		return s.introspect(ctx, token)
+	}
+
+	tokenHash := hashToken(token)
+
+	cached, err := s.introspectionCache.Get(ctx, tokenHash)
+	if err != nil {
+		log.Printf("Introspection cache read failed: %v", err)
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	resp, err := s.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl := s.introspectionPolicy.ttlFor(resp, time.Now()); ttl > 0 {
+		if err := s.introspectionCache.Set(ctx, tokenHash, resp, ttl); err != nil {
+			log.Printf("Introspection cache write failed: %v", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// introspect вызывает introspection endpoint
+func (s *OIDCService) introspect(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	metadata, err := s.discovery.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.IntrospectionEndpoint == "" {
+		return nil, fmt.Errorf("issuer %s does not advertise introspection_endpoint", s.issuer)
+	}
 
 	data := url.Values{}
 	data.Set("token", token)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", introspectURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", metadata.IntrospectionEndpoint, strings.NewReader(data.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create introspect request: %w", err)
 	}
@@ -155,3 +434,115 @@ func (s *OIDCService) IntrospectToken(ctx context.Context, token string) (*Intro
 
 	return &introspectResp, nil
 }
+
+// jwtSignatureAlgorithms - алгоритмы подписи, которые принимаются от issuer'а
+var jwtSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// accessTokenClaims - claims JWT access token'а Zitadel
+type accessTokenClaims struct {
+	jwt.Claims
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope"`
+	SessionID string `json:"sid"`
+	Username  string `json:"preferred_username"`
+}
+
+// VerifyToken проверяет access token. JWT проверяется локально по JWKS issuer'а
+// (подпись, iss, aud, exp, nbf), opaque токены и JWT при недоступном JWKS - через introspection.
+// Локальная проверка не видит отзыв токена в Zitadel: отозванный там JWT действителен до exp.
+// Отзыв через этот сервис учитывается в обоих случаях по локальному списку (SetTokenRevocations).
+// Локальная проверка отключается JWT_VERIFY_LOCAL=false
+func (s *OIDCService) VerifyToken(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	resp, err := s.verifyToken(ctx, token)
+	if err != nil || !resp.Active || s.revocations == nil {
+		return resp, err
+	}
+
+	revoked, err := s.revocations.IsRevoked(ctx, hashToken(token), resp.Subject, time.Unix(resp.IssuedAt, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		log.Printf("Token rejected: revoked locally, sub=%s", resp.Subject)
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	return resp, nil
+}
+
+// verifyToken - проверка подписи и claims без учета локального списка отзыва
+func (s *OIDCService) verifyToken(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	if !s.verifyLocally || strings.Count(token, ".") != 2 {
+		return s.IntrospectToken(ctx, token)
+	}
+
+	parsed, err := jwt.ParseSigned(token, jwtSignatureAlgorithms)
+	if err != nil {
+		// Три сегмента, но не JWS - пусть решает issuer
+		log.Printf("Token is not a signed JWT, falling back to introspection: %v", err)
+		return s.IntrospectToken(ctx, token)
+	}
+
+	kid := ""
+	if len(parsed.Headers) > 0 {
+		kid = parsed.Headers[0].KeyID
+	}
+
+	key, err := s.jwks.Key(ctx, kid)
+	if errors.Is(err, errUnknownSigningKey) {
+		log.Printf("Token rejected: %v", err)
+		return &IntrospectionResponse{Active: false}, nil
+	}
+	if errors.Is(err, errSigningKeyNotRefetched) {
+		log.Printf("Falling back to introspection: %v", err)
+		return s.IntrospectToken(ctx, token)
+	}
+	if err != nil {
+		log.Printf("JWKS unavailable, falling back to introspection: %v", err)
+		return s.IntrospectToken(ctx, token)
+	}
+
+	var claims accessTokenClaims
+	if err := parsed.Claims(key, &claims); err != nil {
+		log.Printf("Token rejected: invalid signature: %v", err)
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	// Validate пропускает токен без exp, а бессрочный токен не истекает никогда.
+	// Пустой iss отвергается и сравнением с issuer'ом, но проверка не должна от него зависеть
+	if claims.Expiry == nil || claims.Issuer == "" {
+		log.Printf("Token rejected: missing exp or iss claim")
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      s.issuer,
+		AnyAudience: s.audiences,
+		Time:        time.Now(),
+	}, jwt.DefaultLeeway)
+	if err != nil {
+		log.Printf("Token rejected: %v", err)
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	resp := &IntrospectionResponse{
+		Active:    true,
+		Subject:   claims.Subject,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		ExpiresAt: claims.Expiry.Time().Unix(),
+		ClientID:  claims.ClientID,
+		Scope:     claims.Scope,
+		SessionID: claims.SessionID,
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Time().Unix()
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+func TestVerifyTokenRequiresExpiryAndIssuer(t *testing.T) {
+	ctx := context.Background()
+	z := newFakeZitadel(t)
+	oidc := newTestOIDCService(t, z)
+
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   z.server.URL,
+		Subject:  "user-1",
+		Audience: jwt.Audience{"test-client"},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	noExpiry := valid
+	noExpiry.Expiry = nil
+
+	noIssuer := valid
+	noIssuer.Issuer = ""
+
+	tests := []struct {
+		name   string
+		claims jwt.Claims
+		active bool
+	}{
+		{"valid", valid, true},
+		{"no exp", noExpiry, false},
+		{"no iss", noIssuer, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := oidc.VerifyToken(ctx, z.signedToken(t, tt.claims))
+			if err != nil {
+				t.Fatalf("VerifyToken: %v", err)
+			}
+			if resp.Active != tt.active {
+				t.Errorf("active = %v, want %v", resp.Active, tt.active)
+			}
+		})
+	}
+}
+
+// signToken подписывает claims произвольным ключом и регистрирует токен у issuer'а,
+// чтобы introspection считала его активным
+func (z *fakeZitadel) signToken(t *testing.T, alg jose.SignatureAlgorithm, key any, kid string, claims jwt.Claims) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: alg, Key: key},
+		(&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), kid),
+	)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	z.mu.Lock()
+	z.subject[token] = claims.Subject
+	z.mu.Unlock()
+
+	return token
+}
+
+func TestVerifyTokenValidatesClaimsAndAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	z := newFakeZitadel(t)
+	oidc := newTestOIDCService(t, z)
+
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   z.server.URL,
+		Subject:  "user-1",
+		Audience: jwt.Audience{"test-client"},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	notYetValid := valid
+	notYetValid.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Minute))
+
+	otherAudience := valid
+	otherAudience.Audience = jwt.Audience{"other-client"}
+
+	expired := valid
+	expired.Expiry = jwt.NewNumericDate(now.Add(-10 * time.Minute))
+
+	otherIssuer := valid
+	otherIssuer.Issuer = "https://evil.example.com"
+
+	tests := []struct {
+		name   string
+		claims jwt.Claims
+	}{
+		{"nbf in the future", notYetValid},
+		{"aud mismatch", otherAudience},
+		{"expired", expired},
+		{"iss mismatch", otherIssuer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := oidc.VerifyToken(ctx, z.signedToken(t, tt.claims))
+			if err != nil {
+				t.Fatalf("VerifyToken: %v", err)
+			}
+			if resp.Active {
+				t.Error("token accepted")
+			}
+		})
+	}
+
+	// HS256 не входит в список алгоритмов: такой токен локально не проверяется,
+	// а introspection отвергает его, потому что issuer его не выпускал
+	t.Run("alg not allowed", func(t *testing.T) {
+		token := z.signToken(t, jose.HS256, []byte("test-secret-used-as-an-hmac-key!"), "test", valid)
+		z.mu.Lock()
+		delete(z.subject, token)
+		z.mu.Unlock()
+
+		resp, err := oidc.VerifyToken(ctx, token)
+		if err != nil {
+			t.Fatalf("VerifyToken: %v", err)
+		}
+		if resp.Active {
+			t.Error("HS256 token accepted")
+		}
+	})
+}
+
+func TestVerifyTokenUnknownKid(t *testing.T) {
+	ctx := context.Background()
+	z := newFakeZitadel(t)
+	oidc := newTestOIDCService(t, z)
+
+	// Загружаем JWKS обычным токеном
+	if resp, err := oidc.VerifyToken(ctx, z.accessToken(t, "user-1")); err != nil || !resp.Active {
+		t.Fatalf("VerifyToken: active=%v, err=%v", resp != nil && resp.Active, err)
+	}
+
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	now := time.Now()
+	token := z.signToken(t, jose.RS256, rotated, "rotated", jwt.Claims{
+		Issuer:   z.server.URL,
+		Subject:  "user-1",
+		Audience: jwt.Audience{"test-client"},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	})
+
+	// JWKS только что загружен, повторная загрузка отложена: решает introspection
+	resp, err := oidc.VerifyToken(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if !resp.Active || resp.Subject != "user-1" {
+		t.Errorf("unknown kid within minRefetch: got %+v, want introspection result", resp)
+	}
+
+	// После повторной загрузки ключа все еще нет - токен отвергается без introspection
+	oidc.jwks.age(time.Minute)
+	resp, err = oidc.VerifyToken(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if resp.Active {
+		t.Error("unknown kid accepted after JWKS refetch")
+	}
+}
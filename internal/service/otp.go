@@ -1,97 +1,176 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
+	"log"
 	"math/big"
-	"sync"
+	"os"
+	"sms-service/internal/domain"
+	"sms-service/internal/i18n"
 	"time"
 )
 
-type OTPStore struct {
-	mu    sync.RWMutex
-	codes map[string]*OTPData // ключ - номер телефона
+const (
+	otpLength      = 6
+	otpTTL         = 5 * time.Minute
+	otpMaxAttempts = 3
+
+	// receiptOpTimeout - таймаут операций хранилища, вызываемых вне HTTP запроса (DLR)
+	receiptOpTimeout = 5 * time.Second
+)
+
+// OTPKey - код привязан к номеру телефона и назначению; коды с разными
+// назначениями для одного номера хранятся независимо
+type OTPKey struct {
+	Purpose domain.OTPPurpose
+	Phone   string
 }
 
-type OTPData struct {
-	Code      string
-	ExpiresAt time.Time
-	Attempts  int
+func (k OTPKey) String() string {
+	return string(k.Purpose) + ":" + k.Phone
 }
 
-func NewOTPStore() *OTPStore {
-	store := &OTPStore{
-		codes: make(map[string]*OTPData),
-	}
+// OTPAttemptError - введен неверный код, Remaining - сколько попыток осталось
+type OTPAttemptError struct {
+	Remaining int
+}
 
-	go store.cleanupExpired()
+func newOTPAttemptError(attempts int) *OTPAttemptError {
+	return &OTPAttemptError{Remaining: max(0, otpMaxAttempts-attempts)}
+}
 
-	return store
+func (e *OTPAttemptError) Error() string {
+	return fmt.Sprintf("%s, %d attempts remaining", domain.ErrInvalidOTP, e.Remaining)
 }
 
-func (s *OTPStore) GenerateOTP(phone string) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (e *OTPAttemptError) Unwrap() error {
+	return domain.ErrInvalidOTP
+}
 
-	code := generateRandomCode(6)
+// OTPIssue - новый код, уже принятый провайдером к отправке
+type OTPIssue struct {
+	Key OTPKey
+	// Nonce - непустой nonce привязывает код к клиенту: при проверке он должен совпасть
+	Nonce string
+	Code  string
+	// Locale - язык SMS, с ним же текст собирается заново при повторной отправке
+	Locale string
+	SMS    *SMSResult
+}
 
-	s.codes[phone] = &OTPData{
-		Code:      code,
-		ExpiresAt: time.Now().Add(5 * time.Minute),
-		Attempts:  0,
-	}
+// OTPBinding - код, к которому сейчас привязано сообщение провайдера
+type OTPBinding struct {
+	Key    OTPKey
+	Locale string
+}
 
-	return code, nil
+// OTPStore - хранилище OTP кодов
+// Реализации: MemoryOTPStore (dev, тесты), RedisOTPStore и PostgresOTPStore (несколько реплик)
+type OTPStore interface {
+	// SaveOTP сохраняет отправленный код (см. NewOTPCode), заменяя предыдущий с тем же ключом,
+	// и привязывает к нему сообщение. Код сохраняется только после успешной отправки:
+	// при сбое провайдера у пользователя остается действующим ранее доставленный код
+	SaveOTP(ctx context.Context, issue OTPIssue) error
+	// VerifyOTP проверяет код; при успехе код удаляется.
+	// Если кода с таким назначением нет, но есть с другим, возвращает domain.ErrOTPWrongPurpose.
+	VerifyOTP(ctx context.Context, key OTPKey, code, nonce string) error
+	DeleteOTP(ctx context.Context, key OTPKey) error
+	// GetDeliveryStatus возвращает статус доставки текущего OTP тому, кто знает nonce из send-otp.
+	// Для кода без nonce или при несовпадении nonce возвращает domain.ErrOTPNotFound
+	GetDeliveryStatus(ctx context.Context, key OTPKey, nonce string) (*OTPDeliveryInfo, error)
+
+	// ReserveSend атомарно проверяет политику отправок для номера (по всем назначениям)
+	// и учитывает новую отправку; при отказе - *OTPThrottleError.
+	ReserveSend(ctx context.Context, phone string, policy *OTPSendPolicy) (*OTPSendReservation, error)
+	// ReleaseSend отменяет учет отправки, если код так и не был отправлен,
+	// чтобы сбой провайдера не расходовал квоту и не запускал паузу
+	ReleaseSend(ctx context.Context, reservation *OTPSendReservation) error
+
+	DeliveryTracker
 }
 
-func (s *OTPStore) VerifyOTP(phone, code string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+type OTPData struct {
+	CodeHash  string // HMAC кода, см. OTPHasher
+	NonceHash string // HMAC nonce клиента, пусто - код без nonce
+	ExpiresAt time.Time
+	Attempts  int
+	Locale    string // язык SMS для повторной отправки
 
-	otpData, exists := s.codes[phone]
-	if !exists {
-		return fmt.Errorf("OTP code not found for this phone number")
-	}
+	// Доставка SMS
+	MessageID       string
+	Provider        string
+	Status          domain.DeliveryStatus
+	StatusUpdatedAt time.Time
+}
 
-	if time.Now().After(otpData.ExpiresAt) {
-		delete(s.codes, phone)
-		return fmt.Errorf("OTP code has expired")
-	}
+// OTPDeliveryInfo - статус доставки OTP для клиента
+type OTPDeliveryInfo struct {
+	Provider  string
+	Status    domain.DeliveryStatus
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
 
-	if otpData.Attempts >= 3 {
-		delete(s.codes, phone)
-		return fmt.Errorf("too many failed attempts")
-	}
+// NewOTPStore создает хранилище согласно OTP_STORE: memory (по умолчанию), redis, postgres
+func NewOTPStore() (OTPStore, error) {
+	backend := os.Getenv("OTP_STORE")
 
-	if otpData.Code != code {
-		otpData.Attempts++
-		return fmt.Errorf("invalid OTP code")
+	switch backend {
+	case "", "memory":
+		hasher, err := newOTPHasherFromEnv(false)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using in-memory OTP store")
+		return NewMemoryOTPStore(hasher), nil
+	case "redis":
+		hasher, err := newOTPHasherFromEnv(true)
+		if err != nil {
+			return nil, err
+		}
+		client, err := NewRedisClient()
+		if err != nil {
+			return nil, err
+		}
+		return NewRedisOTPStore(client, hasher), nil
+	case "postgres":
+		hasher, err := newOTPHasherFromEnv(true)
+		if err != nil {
+			return nil, err
+		}
+		db, err := NewPostgresDB()
+		if err != nil {
+			return nil, err
+		}
+		return NewPostgresOTPStore(db, hasher)
+	default:
+		return nil, fmt.Errorf("unknown OTP_STORE: %s", backend)
 	}
-
-	delete(s.codes, phone)
-	return nil
 }
 
-func (s *OTPStore) DeleteOTP(phone string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.codes, phone)
+// setDeliveryStatus не дает финальному статусу откатиться назад
+func setDeliveryStatus(otpData *OTPData, status domain.DeliveryStatus) {
+	if otpData.Status.IsFinal() && !status.IsFinal() {
+		return
+	}
+	otpData.Status = status
+	otpData.StatusUpdatedAt = time.Now()
 }
 
-func (s *OTPStore) cleanupExpired() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+// NewOTPCode создает код для отправки; сохраняется он через OTPStore.SaveOTP
+func NewOTPCode() string {
+	return generateRandomCode(otpLength)
+}
 
-	for range ticker.C {
-		s.mu.Lock()
-		now := time.Now()
-		for phone, data := range s.codes {
-			if now.After(data.ExpiresAt) {
-				delete(s.codes, phone)
-			}
-		}
-		s.mu.Unlock()
+// OTPMessageText возвращает текст SMS с кодом на языке locale
+func OTPMessageText(purpose domain.OTPPurpose, locale, code string) string {
+	key := i18n.MsgSMSLoginCode
+	if purpose == domain.OTPPurposeRegister {
+		key = i18n.MsgSMSRegisterCode
 	}
+	return i18n.New(locale).T(key, code)
 }
 
 func generateRandomCode(length int) string {
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+// OTPHasher хранит вместо кода HMAC-SHA256(секрет, телефон + назначение + HMAC nonce + код),
+// чтобы дамп памяти или базы не раскрывал действующие коды. Код привязан к nonce через его HMAC,
+// поэтому при повторной отправке новый код хешируется по сохраненному хешу nonce,
+// а сам nonce хранить не нужно
+type OTPHasher struct {
+	secret []byte
+}
+
+func NewOTPHasher(secret []byte) *OTPHasher {
+	return &OTPHasher{secret: secret}
+}
+
+// newOTPHasherFromEnv читает OTP_HASH_SECRET. Для хранилища одного процесса
+// при отсутствии секрета генерируется случайный; общим хранилищам нужен
+// одинаковый секрет на всех репликах, поэтому для них он обязателен.
+func newOTPHasherFromEnv(shared bool) (*OTPHasher, error) {
+	secret := os.Getenv("OTP_HASH_SECRET")
+	if secret != "" {
+		return NewOTPHasher([]byte(secret)), nil
+	}
+
+	if shared {
+		return nil, fmt.Errorf("OTP_HASH_SECRET is required for shared OTP stores")
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate OTP hash secret: %w", err)
+	}
+
+	log.Printf("Warning: OTP_HASH_SECRET not set, using random secret for this process")
+	return NewOTPHasher(random), nil
+}
+
+// Hash возвращает hex HMAC кода, привязанного к номеру, назначению и nonce
+func (h *OTPHasher) Hash(key OTPKey, nonce, code string) string {
+	return h.HashWithNonceHash(key, h.HashNonce(key, nonce), code)
+}
+
+// HashWithNonceHash возвращает hex HMAC кода по уже вычисленному HashNonce
+func (h *OTPHasher) HashWithNonceHash(key OTPKey, nonceHash, code string) string {
+	return h.mac(key.Phone, string(key.Purpose), nonceHash, code)
+}
+
+// HashNonce возвращает hex HMAC nonce клиента, по которому он запрашивает статус доставки.
+// Для пустого nonce - пустая строка: такой код не привязан к клиенту
+func (h *OTPHasher) HashNonce(key OTPKey, nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+	return h.mac(key.Phone, string(key.Purpose), nonce, "nonce")
+}
+
+// mac - HMAC частей, разделенных нулевым байтом
+func (h *OTPHasher) mac(parts ...string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	for i, part := range parts {
+		if i > 0 {
+			mac.Write([]byte{0})
+		}
+		mac.Write([]byte(part))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyNonce сравнивает nonce с сохраненным хешем за постоянное время
+func (h *OTPHasher) VerifyNonce(hash string, key OTPKey, nonce string) bool {
+	if hash == "" || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(hash), []byte(h.HashNonce(key, nonce)))
+}
+
+// Verify сравнивает код с сохраненным хешем за постоянное время
+func (h *OTPHasher) Verify(hash string, key OTPKey, nonce, code string) bool {
+	return hmac.Equal([]byte(hash), []byte(h.Hash(key, nonce, code)))
+}
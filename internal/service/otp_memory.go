@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sms-service/internal/domain"
+	"sync"
+	"time"
+)
+
+const (
+	// receiptHoldTime - сколько хранить DLR, пришедший раньше ответа на отправку
+	receiptHoldTime = time.Minute
+	// maxEarlyReceipts - сколько ранних DLR хранить одновременно (за receiptHoldTime в Redis),
+	// чтобы отчеты с выдуманными message ID не раздували хранилище
+	maxEarlyReceipts = 10000
+)
+
+// MemoryOTPStore - хранилище OTP в памяти процесса (dev, тесты, одна реплика)
+type MemoryOTPStore struct {
+	hasher   *OTPHasher
+	mu       sync.RWMutex
+	codes    map[OTPKey]*OTPData
+	messages map[string]OTPKey          // messageRef -> код
+	early    map[string]DeliveryReceipt // messageRef -> DLR для еще не привязанного сообщения
+	sends    map[string][]time.Time     // номер телефона -> время отправок за сутки
+}
+
+func NewMemoryOTPStore(hasher *OTPHasher) *MemoryOTPStore {
+	store := &MemoryOTPStore{
+		hasher:   hasher,
+		codes:    make(map[OTPKey]*OTPData),
+		messages: make(map[string]OTPKey),
+		early:    make(map[string]DeliveryReceipt),
+		sends:    make(map[string][]time.Time),
+	}
+
+	go store.cleanupExpired()
+
+	return store
+}
+
+func (s *MemoryOTPStore) SaveOTP(ctx context.Context, issue OTPIssue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := issue.Key
+	now := time.Now()
+
+	s.removeLocked(key)
+	otpData := &OTPData{
+		CodeHash:        s.hasher.Hash(key, issue.Nonce, issue.Code),
+		NonceHash:       s.hasher.HashNonce(key, issue.Nonce),
+		ExpiresAt:       now.Add(otpTTL),
+		Attempts:        0,
+		Locale:          issue.Locale,
+		Status:          domain.DeliveryStatusQueued,
+		StatusUpdatedAt: now,
+	}
+	s.codes[key] = otpData
+
+	s.bindMessageLocked(key, otpData, issue.SMS)
+	return nil
+}
+
+// MessageOTP возвращает код, к которому привязано сообщение
+func (s *MemoryOTPStore) MessageOTP(ctx context.Context, provider, messageID string) (*OTPBinding, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, otpData := s.boundLocked(provider, messageID)
+	if otpData == nil {
+		return nil, nil
+	}
+	return &OTPBinding{Key: key, Locale: otpData.Locale}, nil
+}
+
+// ReissueOTP заменяет код, привязанный к сообщению, сохраняя nonce
+func (s *MemoryOTPStore) ReissueOTP(ctx context.Context, provider, messageID, code string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, otpData := s.boundLocked(provider, messageID)
+	if otpData == nil {
+		return false, nil
+	}
+	otpData.CodeHash = s.hasher.HashWithNonceHash(key, otpData.NonceHash, code)
+	return true, nil
+}
+
+// boundLocked возвращает действующий код, привязанный к сообщению, вызывать под s.mu
+func (s *MemoryOTPStore) boundLocked(provider, messageID string) (OTPKey, *OTPData) {
+	key, ok := s.messages[messageRef(provider, messageID)]
+	if !ok {
+		return key, nil
+	}
+	otpData, exists := s.codes[key]
+	if !exists || otpData.Provider != provider || otpData.MessageID != messageID || time.Now().After(otpData.ExpiresAt) {
+		return key, nil
+	}
+	return key, otpData
+}
+
+// Reroute переносит OTP на сообщение, повторно отправленное другим провайдером
+func (s *MemoryOTPStore) Reroute(oldProvider, oldMessageID string, sms *SMSResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.messages[messageRef(oldProvider, oldMessageID)]
+	if !ok {
+		return
+	}
+
+	otpData, exists := s.codes[key]
+	if !exists || otpData.Provider != oldProvider || otpData.MessageID != oldMessageID {
+		return
+	}
+
+	s.bindMessageLocked(key, otpData, sms)
+	log.Printf("OTP %s rerouted via %s (message_id=%s)", key, sms.Provider, sms.MessageID)
+}
+
+func (s *MemoryOTPStore) bindMessageLocked(key OTPKey, otpData *OTPData, sms *SMSResult) {
+	if otpData.MessageID != "" {
+		delete(s.messages, messageRef(otpData.Provider, otpData.MessageID))
+	}
+
+	otpData.MessageID = sms.MessageID
+	otpData.Provider = sms.Provider
+	otpData.Status = domain.DeliveryStatusSent
+	otpData.StatusUpdatedAt = time.Now()
+
+	if sms.MessageID == "" {
+		return
+	}
+	ref := messageRef(sms.Provider, sms.MessageID)
+	s.messages[ref] = key
+
+	// DLR мог прийти раньше, чем провайдер ответил на отправку
+	if receipt, ok := s.early[ref]; ok {
+		delete(s.early, ref)
+		setDeliveryStatus(otpData, receipt.Status)
+	}
+}
+
+// ApplyReceipt обновляет статус доставки по отчету провайдера
+func (s *MemoryOTPStore) ApplyReceipt(receipt DeliveryReceipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ref := messageRef(receipt.Provider, receipt.MessageID)
+
+	key, ok := s.messages[ref]
+	if !ok {
+		if _, held := s.early[ref]; !held && len(s.early) >= maxEarlyReceipts {
+			log.Printf("Early receipt %s dropped: %d receipts already held", ref, len(s.early))
+			return
+		}
+		s.early[ref] = receipt
+		return
+	}
+
+	otpData, exists := s.codes[key]
+	if !exists || otpData.Provider != receipt.Provider || otpData.MessageID != receipt.MessageID {
+		delete(s.messages, ref)
+		return
+	}
+
+	setDeliveryStatus(otpData, receipt.Status)
+	log.Printf("OTP delivery status for %s: %s (message_id=%s)", key, receipt.Status, receipt.MessageID)
+}
+
+// GetDeliveryStatus возвращает статус доставки текущего OTP
+func (s *MemoryOTPStore) GetDeliveryStatus(ctx context.Context, key OTPKey, nonce string) (*OTPDeliveryInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	otpData, exists := s.codes[key]
+	if !exists || time.Now().After(otpData.ExpiresAt) || !s.hasher.VerifyNonce(otpData.NonceHash, key, nonce) {
+		return nil, domain.ErrOTPNotFound
+	}
+
+	return &OTPDeliveryInfo{
+		Provider:  otpData.Provider,
+		Status:    otpData.Status,
+		UpdatedAt: otpData.StatusUpdatedAt,
+		ExpiresAt: otpData.ExpiresAt,
+	}, nil
+}
+
+func (s *MemoryOTPStore) VerifyOTP(ctx context.Context, key OTPKey, code, nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otpData, exists := s.codes[key]
+	if !exists {
+		if s.hasOtherPurposeLocked(key) {
+			return domain.ErrOTPWrongPurpose
+		}
+		return domain.ErrOTPNotFound
+	}
+
+	if time.Now().After(otpData.ExpiresAt) {
+		s.removeLocked(key)
+		return domain.ErrOTPExpired
+	}
+
+	if otpData.Attempts >= otpMaxAttempts {
+		s.removeLocked(key)
+		return domain.ErrOTPMaxAttempts
+	}
+
+	if !s.hasher.Verify(otpData.CodeHash, key, nonce, code) {
+		otpData.Attempts++
+		return newOTPAttemptError(otpData.Attempts)
+	}
+
+	s.removeLocked(key)
+	return nil
+}
+
+func (s *MemoryOTPStore) DeleteOTP(ctx context.Context, key OTPKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(key)
+	return nil
+}
+
+func (s *MemoryOTPStore) ReserveSend(ctx context.Context, phone string, policy *OTPSendPolicy) (*OTPSendReservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sends := recentSends(s.sends[phone], now)
+
+	wait, err := policy.reserve(sends, now)
+	if err != nil {
+		s.sends[phone] = sends
+		return nil, err
+	}
+
+	s.sends[phone] = append(sends, now)
+	return &OTPSendReservation{Phone: phone, At: now, RetryAfter: wait}, nil
+}
+
+func (s *MemoryOTPStore) ReleaseSend(ctx context.Context, reservation *OTPSendReservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sends := s.sends[reservation.Phone]
+	for i, sentAt := range sends {
+		if sentAt.Equal(reservation.At) {
+			s.sends[reservation.Phone] = append(sends[:i:i], sends[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// recentSends отбрасывает отправки старше otpSendWindow
+func recentSends(sends []time.Time, now time.Time) []time.Time {
+	for i, sentAt := range sends {
+		if now.Sub(sentAt) < otpSendWindow {
+			return sends[i:]
+		}
+	}
+	return nil
+}
+
+// hasOtherPurposeLocked - для номера есть действующий код с другим назначением
+func (s *MemoryOTPStore) hasOtherPurposeLocked(key OTPKey) bool {
+	for _, purpose := range domain.OTPPurposes {
+		if purpose == key.Purpose {
+			continue
+		}
+		otpData, exists := s.codes[OTPKey{Purpose: purpose, Phone: key.Phone}]
+		if exists && time.Now().Before(otpData.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLocked удаляет OTP вместе с индексом message ID, вызывать под s.mu
+func (s *MemoryOTPStore) removeLocked(key OTPKey) {
+	if otpData, exists := s.codes[key]; exists && otpData.MessageID != "" {
+		delete(s.messages, messageRef(otpData.Provider, otpData.MessageID))
+	}
+	delete(s.codes, key)
+}
+
+func (s *MemoryOTPStore) cleanupExpired() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for key, data := range s.codes {
+			if now.After(data.ExpiresAt) {
+				s.removeLocked(key)
+			}
+		}
+		for phone, sends := range s.sends {
+			if sends = recentSends(sends, now); len(sends) == 0 {
+				delete(s.sends, phone)
+			} else {
+				s.sends[phone] = sends
+			}
+		}
+		for ref, receipt := range s.early {
+			if now.Sub(receipt.ReceivedAt) > receiptHoldTime {
+				delete(s.early, ref)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
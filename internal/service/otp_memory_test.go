@@ -0,0 +1,20 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryOTPStoreContract(t *testing.T) {
+	runOTPStoreContract(t, func(t *testing.T) otpStoreHarness {
+		store := NewMemoryOTPStore(NewOTPHasher([]byte("test")))
+		return otpStoreHarness{
+			store: store,
+			expire: func(t *testing.T, key OTPKey) {
+				store.mu.Lock()
+				defer store.mu.Unlock()
+				store.codes[key].ExpiresAt = time.Now().Add(-time.Second)
+			},
+		}
+	})
+}
@@ -0,0 +1,550 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sms-service/internal/domain"
+	"time"
+)
+
+// События журнала otp_audit
+const (
+	otpAuditGenerated   = "generated"
+	otpAuditSent        = "sent"
+	otpAuditRerouted    = "rerouted"
+	otpAuditReissued    = "reissued"
+	otpAuditDelivery    = "delivery"
+	otpAuditVerified    = "verified"
+	otpAuditInvalid     = "invalid"
+	otpAuditExpired     = "expired"
+	otpAuditMaxAttempts = "max_attempts"
+	otpAuditDeleted     = "deleted"
+)
+
+// PostgresOTPStore - хранилище OTP в PostgreSQL с журналом событий otp_audit
+// Попытки ввода считаются под блокировкой строки (SELECT ... FOR UPDATE),
+// истекшие коды и старые записи журнала удаляются периодической очисткой.
+type PostgresOTPStore struct {
+	db             *sql.DB
+	hasher         *OTPHasher
+	auditRetention time.Duration
+}
+
+func NewPostgresOTPStore(db *sql.DB, hasher *OTPHasher) (*PostgresOTPStore, error) {
+	purgeInterval, err := durationFromEnv("OTP_PURGE_INTERVAL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	auditRetention, err := durationFromEnv("OTP_AUDIT_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &PostgresOTPStore{
+		db:             db,
+		hasher:         hasher,
+		auditRetention: auditRetention,
+	}
+
+	go store.purgeLoop(purgeInterval)
+
+	log.Printf("Using PostgreSQL OTP store (purge every %s, audit retention %s)", purgeInterval, auditRetention)
+
+	return store, nil
+}
+
+func (s *PostgresOTPStore) SaveOTP(ctx context.Context, issue OTPIssue) error {
+	if err := s.storeCode(ctx, issue); err != nil {
+		return err
+	}
+
+	bound, err := s.bindMessage(ctx, issue.Key, issue.SMS, "", "")
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return domain.ErrOTPNotFound
+	}
+	return nil
+}
+
+// storeCode записывает хеш кода, заменяя предыдущий код с тем же ключом
+func (s *PostgresOTPStore) storeCode(ctx context.Context, issue OTPIssue) error {
+	key := issue.Key
+	now := time.Now()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO otp_codes (phone, purpose, code_hash, nonce_hash, attempts, expires_at, message_id, provider, status, status_updated_at, created_at, locale)
+		VALUES ($1, $2, $3, $7, 0, $4, NULL, '', $5, $6, $6, $8)
+		ON CONFLICT (phone, purpose) DO UPDATE SET
+			code_hash = EXCLUDED.code_hash,
+			nonce_hash = EXCLUDED.nonce_hash,
+			attempts = 0,
+			locale = EXCLUDED.locale,
+			expires_at = EXCLUDED.expires_at,
+			message_id = NULL,
+			provider = '',
+			status = EXCLUDED.status,
+			status_updated_at = EXCLUDED.status_updated_at,
+			created_at = EXCLUDED.created_at`,
+		key.Phone, string(key.Purpose), s.hasher.Hash(key, issue.Nonce, issue.Code), now.Add(otpTTL), string(domain.DeliveryStatusQueued), now,
+		s.hasher.HashNonce(key, issue.Nonce), issue.Locale)
+	if err != nil {
+		return fmt.Errorf("failed to store OTP: %w", err)
+	}
+
+	if err := s.audit(ctx, tx, key, otpAuditGenerated, ""); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit OTP: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresOTPStore) VerifyOTP(ctx context.Context, key OTPKey, code, nonce string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		codeHash  string
+		attempts  int
+		expiresAt time.Time
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT code_hash, attempts, expires_at FROM otp_codes WHERE phone = $1 AND purpose = $2 FOR UPDATE`,
+		key.Phone, string(key.Purpose)).
+		Scan(&codeHash, &attempts, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		var otherPurpose bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM otp_codes WHERE phone = $1 AND purpose <> $2 AND expires_at > now())`,
+			key.Phone, string(key.Purpose)).Scan(&otherPurpose)
+		if err != nil {
+			return fmt.Errorf("failed to check OTP purposes: %w", err)
+		}
+		if otherPurpose {
+			return domain.ErrOTPWrongPurpose
+		}
+		return domain.ErrOTPNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load OTP: %w", err)
+	}
+
+	var (
+		event     string
+		verifyErr error
+	)
+
+	switch {
+	case time.Now().After(expiresAt):
+		event, verifyErr = otpAuditExpired, domain.ErrOTPExpired
+		_, err = tx.ExecContext(ctx, `DELETE FROM otp_codes WHERE phone = $1 AND purpose = $2`, key.Phone, string(key.Purpose))
+	case attempts >= otpMaxAttempts:
+		event, verifyErr = otpAuditMaxAttempts, domain.ErrOTPMaxAttempts
+		_, err = tx.ExecContext(ctx, `DELETE FROM otp_codes WHERE phone = $1 AND purpose = $2`, key.Phone, string(key.Purpose))
+	case !s.hasher.Verify(codeHash, key, nonce, code):
+		event, verifyErr = otpAuditInvalid, newOTPAttemptError(attempts+1)
+		_, err = tx.ExecContext(ctx,
+			`UPDATE otp_codes SET attempts = attempts + 1 WHERE phone = $1 AND purpose = $2`, key.Phone, string(key.Purpose))
+	default:
+		event = otpAuditVerified
+		_, err = tx.ExecContext(ctx, `DELETE FROM otp_codes WHERE phone = $1 AND purpose = $2`, key.Phone, string(key.Purpose))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update OTP: %w", err)
+	}
+
+	if err := s.audit(ctx, tx, key, event, ""); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit OTP verification: %w", err)
+	}
+
+	return verifyErr
+}
+
+func (s *PostgresOTPStore) DeleteOTP(ctx context.Context, key OTPKey) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM otp_codes WHERE phone = $1 AND purpose = $2`, key.Phone, string(key.Purpose))
+	if err != nil {
+		return fmt.Errorf("failed to delete OTP: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		if err := s.audit(ctx, tx, key, otpAuditDeleted, ""); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresOTPStore) MessageOTP(ctx context.Context, provider, messageID string) (*OTPBinding, error) {
+	var (
+		binding OTPBinding
+		purpose string
+	)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT phone, purpose, locale FROM otp_codes
+		WHERE provider = $1 AND message_id = $2 AND expires_at > now()`,
+		provider, messageID).Scan(&binding.Key.Phone, &purpose, &binding.Locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up message %s: %w", messageRef(provider, messageID), err)
+	}
+	binding.Key.Purpose = domain.OTPPurpose(purpose)
+	return &binding, nil
+}
+
+// ReissueOTP заменяет хеш кода под блокировкой строки; nonce_hash не меняется,
+// поэтому новый код проверяется с тем же nonce
+func (s *PostgresOTPStore) ReissueOTP(ctx context.Context, provider, messageID, code string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		key       OTPKey
+		purpose   string
+		nonceHash string
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT phone, purpose, nonce_hash FROM otp_codes
+		WHERE provider = $1 AND message_id = $2 AND expires_at > now()
+		FOR UPDATE`,
+		provider, messageID).Scan(&key.Phone, &purpose, &nonceHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load OTP: %w", err)
+	}
+	key.Purpose = domain.OTPPurpose(purpose)
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE otp_codes SET code_hash = $3 WHERE phone = $1 AND purpose = $2`,
+		key.Phone, string(key.Purpose), s.hasher.HashWithNonceHash(key, nonceHash, code))
+	if err != nil {
+		return false, fmt.Errorf("failed to reissue OTP: %w", err)
+	}
+
+	details := fmt.Sprintf("provider=%s message_id=%s", provider, messageID)
+	if err := s.audit(ctx, tx, key, otpAuditReissued, details); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit OTP reissue: %w", err)
+	}
+	return true, nil
+}
+
+func (s *PostgresOTPStore) Reroute(oldProvider, oldMessageID string, sms *SMSResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), receiptOpTimeout)
+	defer cancel()
+
+	var (
+		key     OTPKey
+		purpose string
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT phone, purpose FROM otp_codes WHERE provider = $1 AND message_id = $2`,
+		oldProvider, oldMessageID).Scan(&key.Phone, &purpose)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to look up message %s: %v", messageRef(oldProvider, oldMessageID), err)
+		}
+		return
+	}
+	key.Purpose = domain.OTPPurpose(purpose)
+
+	bound, err := s.bindMessage(ctx, key, sms, oldProvider, oldMessageID)
+	if err != nil {
+		log.Printf("Failed to reroute OTP %s: %v", key, err)
+		return
+	}
+	if bound {
+		log.Printf("OTP %s rerouted via %s (message_id=%s)", key, sms.Provider, sms.MessageID)
+	}
+}
+
+// bindMessage привязывает сообщение к коду; если expectedMessageID не пуст,
+// код должен быть привязан к этому сообщению провайдера expectedProvider
+// (повторная отправка после неуспешного DLR)
+func (s *PostgresOTPStore) bindMessage(ctx context.Context, key OTPKey, sms *SMSResult, expectedProvider, expectedMessageID string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		currentMessageID sql.NullString
+		currentProvider  string
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT message_id, provider FROM otp_codes WHERE phone = $1 AND purpose = $2 FOR UPDATE`,
+		key.Phone, string(key.Purpose)).Scan(&currentMessageID, &currentProvider)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load OTP: %w", err)
+	}
+
+	if expectedMessageID != "" && (currentMessageID.String != expectedMessageID || currentProvider != expectedProvider) {
+		return false, nil
+	}
+
+	status := string(domain.DeliveryStatusSent)
+	if sms.MessageID != "" {
+		var early string
+		err := tx.QueryRowContext(ctx,
+			`DELETE FROM otp_early_receipts WHERE provider = $1 AND message_id = $2 RETURNING status`,
+			sms.Provider, sms.MessageID).Scan(&early)
+		if err == nil {
+			status = early
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("failed to load early receipt: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE otp_codes
+		SET message_id = NULLIF($3, ''), provider = $4, status = $5, status_updated_at = now()
+		WHERE phone = $1 AND purpose = $2`,
+		key.Phone, string(key.Purpose), sms.MessageID, sms.Provider, status)
+	if err != nil {
+		return false, fmt.Errorf("failed to bind SMS message: %w", err)
+	}
+
+	event := otpAuditSent
+	if expectedMessageID != "" {
+		event = otpAuditRerouted
+	}
+	details := fmt.Sprintf("provider=%s message_id=%s", sms.Provider, sms.MessageID)
+	if err := s.audit(ctx, tx, key, event, details); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit SMS binding: %w", err)
+	}
+	return true, nil
+}
+
+func (s *PostgresOTPStore) ApplyReceipt(receipt DeliveryReceipt) {
+	ctx, cancel := context.WithTimeout(context.Background(), receiptOpTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to begin transaction for receipt %s: %v", receipt.MessageID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Финальный статус не откатывается к промежуточному
+	var (
+		key     OTPKey
+		purpose string
+	)
+	err = tx.QueryRowContext(ctx, `
+		UPDATE otp_codes
+		SET status = $2, status_updated_at = now()
+		WHERE provider = $3 AND message_id = $1
+		  AND NOT (status IN ('delivered', 'failed') AND $2 NOT IN ('delivered', 'failed'))
+		RETURNING phone, purpose`,
+		receipt.MessageID, string(receipt.Status), receipt.Provider).Scan(&key.Phone, &purpose)
+	key.Purpose = domain.OTPPurpose(purpose)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO otp_early_receipts (provider, message_id, status)
+			SELECT $4, $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM otp_codes WHERE provider = $4 AND message_id = $1)
+			  AND (SELECT count(*) FROM otp_early_receipts) < $3
+			ON CONFLICT (provider, message_id) DO UPDATE SET status = EXCLUDED.status, received_at = now()`,
+			receipt.MessageID, string(receipt.Status), maxEarlyReceipts, receipt.Provider)
+	case err == nil:
+		details := fmt.Sprintf("provider=%s message_id=%s status=%s stat=%s err=%s",
+			receipt.Provider, receipt.MessageID, receipt.Status, receipt.Stat, receipt.ErrorCode)
+		err = s.audit(ctx, tx, key, otpAuditDelivery, details)
+	}
+	if err != nil {
+		log.Printf("Failed to apply receipt %s: %v", receipt.MessageID, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit receipt %s: %v", receipt.MessageID, err)
+		return
+	}
+
+	if key.Phone != "" {
+		log.Printf("OTP delivery status for %s: %s (message_id=%s)", key, receipt.Status, receipt.MessageID)
+	}
+}
+
+func (s *PostgresOTPStore) GetDeliveryStatus(ctx context.Context, key OTPKey, nonce string) (*OTPDeliveryInfo, error) {
+	var (
+		info      OTPDeliveryInfo
+		status    string
+		nonceHash string
+	)
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT provider, status, status_updated_at, expires_at, nonce_hash
+		FROM otp_codes
+		WHERE phone = $1 AND purpose = $2 AND expires_at > now()`, key.Phone, string(key.Purpose)).
+		Scan(&info.Provider, &status, &info.UpdatedAt, &info.ExpiresAt, &nonceHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrOTPNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OTP status: %w", err)
+	}
+	if !s.hasher.VerifyNonce(nonceHash, key, nonce) {
+		return nil, domain.ErrOTPNotFound
+	}
+
+	info.Status = domain.DeliveryStatus(status)
+	return &info, nil
+}
+
+// ReserveSend сериализует отправки на номер через advisory lock транзакции
+func (s *PostgresOTPStore) ReserveSend(ctx context.Context, phone string, policy *OTPSendPolicy) (*OTPSendReservation, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('otp_sends:' || $1))`, phone); err != nil {
+		return nil, fmt.Errorf("failed to lock OTP sends: %w", err)
+	}
+
+	// Точность timestamptz - микросекунды, иначе ReleaseSend не найдет запись
+	now := time.Now().Truncate(time.Microsecond)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT sent_at FROM otp_sends WHERE phone = $1 AND sent_at > $2 ORDER BY sent_at`,
+		phone, now.Add(-otpSendWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OTP sends: %w", err)
+	}
+
+	var sends []time.Time
+	for rows.Next() {
+		var sentAt time.Time
+		if err := rows.Scan(&sentAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan OTP send: %w", err)
+		}
+		sends = append(sends, sentAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load OTP sends: %w", err)
+	}
+
+	wait, err := policy.reserve(sends, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO otp_sends (phone, sent_at) VALUES ($1, $2)`, phone, now); err != nil {
+		return nil, fmt.Errorf("failed to record OTP send: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit OTP send: %w", err)
+	}
+	return &OTPSendReservation{Phone: phone, At: now, RetryAfter: wait}, nil
+}
+
+func (s *PostgresOTPStore) ReleaseSend(ctx context.Context, reservation *OTPSendReservation) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM otp_sends WHERE phone = $1 AND sent_at = $2`, reservation.Phone, reservation.At)
+	if err != nil {
+		return fmt.Errorf("failed to release OTP send: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresOTPStore) audit(ctx context.Context, tx *sql.Tx, key OTPKey, event, details string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO otp_audit (phone, purpose, event, details) VALUES ($1, $2, $3, $4)`,
+		key.Phone, string(key.Purpose), event, details)
+	if err != nil {
+		return fmt.Errorf("failed to write OTP audit: %w", err)
+	}
+	return nil
+}
+
+// purgeLoop удаляет истекшие коды, старые ранние DLR и записи журнала старше срока хранения
+func (s *PostgresOTPStore) purgeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.purge()
+	}
+}
+
+func (s *PostgresOTPStore) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	queries := []struct {
+		name  string
+		query string
+		arg   time.Time
+	}{
+		{"expired codes", `DELETE FROM otp_codes WHERE expires_at < $1`, now},
+		{"early receipts", `DELETE FROM otp_early_receipts WHERE received_at < $1`, now.Add(-receiptHoldTime)},
+		{"send history", `DELETE FROM otp_sends WHERE sent_at < $1`, now.Add(-otpSendWindow)},
+		{"audit records", `DELETE FROM otp_audit WHERE created_at < $1`, now.Add(-s.auditRetention)},
+	}
+
+	for _, q := range queries {
+		result, err := s.db.ExecContext(ctx, q.query, q.arg)
+		if err != nil {
+			log.Printf("Failed to purge %s: %v", q.name, err)
+			continue
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			log.Printf("Purged %d %s", rows, q.name)
+		}
+	}
+}
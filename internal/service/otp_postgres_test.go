@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sms-service/internal/domain"
+	"testing"
+)
+
+// newTestPostgresOTPStore создает хранилище в чистой схеме с примененными миграциями
+func newTestPostgresOTPStore(t *testing.T) *PostgresOTPStore {
+	t.Helper()
+
+	db := newTestPostgres(t)
+	if err := runMigrations(context.Background(), db); err != nil {
+		t.Fatalf("runMigrations: %v", err)
+	}
+
+	store, err := NewPostgresOTPStore(db, NewOTPHasher([]byte("test")))
+	if err != nil {
+		t.Fatalf("NewPostgresOTPStore: %v", err)
+	}
+	return store
+}
+
+func expirePostgresOTP(t *testing.T, store *PostgresOTPStore, key OTPKey) {
+	t.Helper()
+
+	_, err := store.db.Exec(`UPDATE otp_codes SET expires_at = now() - interval '1 second' WHERE phone = $1 AND purpose = $2`,
+		key.Phone, string(key.Purpose))
+	if err != nil {
+		t.Fatalf("failed to expire code: %v", err)
+	}
+}
+
+func TestPostgresOTPStoreContract(t *testing.T) {
+	runOTPStoreContract(t, func(t *testing.T) otpStoreHarness {
+		store := newTestPostgresOTPStore(t)
+		return otpStoreHarness{
+			store: store,
+			expire: func(t *testing.T, key OTPKey) {
+				expirePostgresOTP(t, store, key)
+			},
+		}
+	})
+}
+
+func TestPostgresOTPStorePurgesExpiredCodes(t *testing.T) {
+	store := newTestPostgresOTPStore(t)
+	key := OTPKey{Purpose: domain.OTPPurposeLogin, Phone: "+79991234567"}
+
+	mustSaveOTP(t, store, key, "", "")
+	expirePostgresOTP(t, store, key)
+
+	store.purge()
+
+	var count int
+	if err := store.db.QueryRow(`SELECT count(*) FROM otp_codes`).Scan(&count); err != nil {
+		t.Fatalf("failed to count codes: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d codes left after purge, want 0", count)
+	}
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sms-service/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOTPStore - хранилище OTP в Redis
+//
+// Ключи:
+//
+//	otp:code:<purpose>:<phone> - hash с HMAC кода, попытками и статусом доставки, TTL = время жизни кода
+//	otp:msg:<provider>/<id>    - сообщение провайдера -> ключ кода, TTL как у кода
+//	otp:early:<provider>/<id>  - DLR, пришедший раньше ответа провайдера на отправку
+//	otp:early:count            - число ранних DLR за receiptHoldTime (не больше maxEarlyReceipts)
+//	otp:sends:<phone>          - sorted set времени отправок за сутки для квот
+type RedisOTPStore struct {
+	client *redis.Client
+	hasher *OTPHasher
+}
+
+// verifyScript атомарно проверяет хеш кода и увеличивает счетчик попыток
+// Хеши сравниваются побайтово до конца строки, без раннего выхода.
+// KEYS[1] - ключ кода; ARGV[1] - хеш введенного кода; ARGV[2] - максимум попыток
+// Для неверного кода возвращает "invalid:<число попыток>"
+var verifyScript = redis.NewScript(`
+local data = redis.call('HMGET', KEYS[1], 'code_hash', 'attempts')
+if not data[1] then
+	return 'not_found'
+end
+if tonumber(data[2]) >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return 'max_attempts'
+end
+local stored, given = data[1], ARGV[1]
+local diff = math.abs(#stored - #given)
+for i = 1, math.min(#stored, #given) do
+	diff = diff + math.abs(stored:byte(i) - given:byte(i))
+end
+if diff ~= 0 then
+	return 'invalid:' .. redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+end
+redis.call('DEL', KEYS[1])
+return 'ok'
+`)
+
+// bindMessageScript привязывает сообщение к коду и применяет ранний DLR
+// KEYS[1] - ключ кода, KEYS[2] - индекс нового message ID, KEYS[3] - ранний DLR
+// ARGV[1] - message ID, ARGV[2] - провайдер, ARGV[3] - время, ARGV[4] - ключ кода,
+// ARGV[5], ARGV[6] - ожидаемые текущие message ID и провайдер (пусто - не проверять)
+var bindMessageScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if ARGV[5] ~= '' then
+	local current = redis.call('HMGET', KEYS[1], 'message_id', 'provider')
+	if current[1] ~= ARGV[5] or current[2] ~= ARGV[6] then
+		return 0
+	end
+end
+local status = 'sent'
+if ARGV[1] ~= '' then
+	local early = redis.call('GET', KEYS[3])
+	if early then
+		status = early
+		redis.call('DEL', KEYS[3])
+	end
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl > 0 then
+		redis.call('SET', KEYS[2], ARGV[4], 'PX', ttl)
+	end
+end
+redis.call('HSET', KEYS[1], 'message_id', ARGV[1], 'provider', ARGV[2], 'status', status, 'status_updated_at', ARGV[3])
+return 1
+`)
+
+// applyReceiptScript обновляет статус, если код все еще привязан к этому сообщению
+// KEYS[1] - ключ кода; ARGV[1] - message ID, ARGV[2] - статус, ARGV[3] - время, ARGV[4] - провайдер
+var applyReceiptScript = redis.NewScript(`
+local bound = redis.call('HMGET', KEYS[1], 'message_id', 'provider')
+if bound[1] ~= ARGV[1] or bound[2] ~= ARGV[4] then
+	return 0
+end
+local current = redis.call('HGET', KEYS[1], 'status')
+local final = {delivered = true, failed = true}
+if final[current] and not final[ARGV[2]] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'status', ARGV[2], 'status_updated_at', ARGV[3])
+return 1
+`)
+
+// reissueScript заменяет хеш кода, если код все еще привязан к сообщению и nonce не сменился
+// KEYS[1] - ключ кода; ARGV[1] - message ID, ARGV[2] - провайдер, ARGV[3] - хеш nonce, ARGV[4] - новый хеш кода
+var reissueScript = redis.NewScript(`
+local bound = redis.call('HMGET', KEYS[1], 'message_id', 'provider', 'nonce_hash')
+if bound[1] ~= ARGV[1] or bound[2] ~= ARGV[2] or bound[3] ~= ARGV[3] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'code_hash', ARGV[4])
+return 1
+`)
+
+// holdEarlyReceiptScript сохраняет ранний DLR, пока их за окно receiptHoldTime не больше лимита
+// KEYS[1] - ранний DLR, KEYS[2] - счетчик окна; ARGV[1] - статус, ARGV[2] - окно в мс, ARGV[3] - лимит
+var holdEarlyReceiptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	local count = redis.call('INCR', KEYS[2])
+	if count == 1 then
+		redis.call('PEXPIRE', KEYS[2], ARGV[2])
+	end
+	if count > tonumber(ARGV[3]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+func NewRedisOTPStore(client *redis.Client, hasher *OTPHasher) *RedisOTPStore {
+	log.Printf("Using Redis OTP store")
+	return &RedisOTPStore{client: client, hasher: hasher}
+}
+
+func redisCodeKey(key OTPKey) string    { return "otp:code:" + key.String() }
+func redisMessageKey(ref string) string { return "otp:msg:" + ref }
+func redisSendsKey(phone string) string { return "otp:sends:" + phone }
+func redisEarlyKey(ref string) string   { return "otp:early:" + ref }
+
+const redisEarlyCountKey = "otp:early:count"
+
+func (s *RedisOTPStore) SaveOTP(ctx context.Context, issue OTPIssue) error {
+	key := issue.Key
+	now := time.Now()
+	codeKey := redisCodeKey(key)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, codeKey)
+		pipe.HSet(ctx, codeKey,
+			"code_hash", s.hasher.Hash(key, issue.Nonce, issue.Code),
+			"nonce_hash", s.hasher.HashNonce(key, issue.Nonce),
+			"attempts", 0,
+			"locale", issue.Locale,
+			"expires_at", now.Add(otpTTL).UnixMilli(),
+			"status", string(domain.DeliveryStatusQueued),
+			"status_updated_at", now.UnixMilli(),
+		)
+		pipe.PExpire(ctx, codeKey, otpTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store OTP in redis: %w", err)
+	}
+
+	bound, err := s.bindMessage(ctx, codeKey, issue.SMS, "", "")
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return domain.ErrOTPNotFound
+	}
+	return nil
+}
+
+func (s *RedisOTPStore) VerifyOTP(ctx context.Context, key OTPKey, code, nonce string) error {
+	result, err := verifyScript.Run(ctx, s.client, []string{redisCodeKey(key)}, s.hasher.Hash(key, nonce, code), otpMaxAttempts).Text()
+	if err != nil {
+		return fmt.Errorf("failed to verify OTP in redis: %w", err)
+	}
+
+	switch result {
+	case "ok":
+		return nil
+	case "not_found":
+		otherPurpose, err := s.hasOtherPurpose(ctx, key)
+		if err != nil {
+			return err
+		}
+		if otherPurpose {
+			return domain.ErrOTPWrongPurpose
+		}
+		// Истекшие коды удаляются Redis по TTL, поэтому не отличаются от отсутствующих
+		return domain.ErrOTPNotFound
+	case "max_attempts":
+		return domain.ErrOTPMaxAttempts
+	}
+
+	attemptsRaw, ok := strings.CutPrefix(result, "invalid:")
+	if !ok {
+		return fmt.Errorf("unexpected OTP verify result: %s", result)
+	}
+	attempts, err := strconv.Atoi(attemptsRaw)
+	if err != nil {
+		return fmt.Errorf("unexpected OTP attempts value: %s", attemptsRaw)
+	}
+	return newOTPAttemptError(attempts)
+}
+
+// hasOtherPurpose - для номера есть действующий код с другим назначением
+func (s *RedisOTPStore) hasOtherPurpose(ctx context.Context, key OTPKey) (bool, error) {
+	var others []string
+	for _, purpose := range domain.OTPPurposes {
+		if purpose != key.Purpose {
+			others = append(others, redisCodeKey(OTPKey{Purpose: purpose, Phone: key.Phone}))
+		}
+	}
+
+	count, err := s.client.Exists(ctx, others...).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check OTP purposes in redis: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *RedisOTPStore) DeleteOTP(ctx context.Context, key OTPKey) error {
+	if err := s.client.Del(ctx, redisCodeKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to delete OTP from redis: %w", err)
+	}
+	return nil
+}
+
+// MessageOTP находит код по индексу сообщения и проверяет, что он все еще привязан к нему
+func (s *RedisOTPStore) MessageOTP(ctx context.Context, provider, messageID string) (*OTPBinding, error) {
+	binding, _, err := s.messageOTP(ctx, provider, messageID)
+	return binding, err
+}
+
+func (s *RedisOTPStore) ReissueOTP(ctx context.Context, provider, messageID, code string) (bool, error) {
+	binding, nonceHash, err := s.messageOTP(ctx, provider, messageID)
+	if err != nil || binding == nil {
+		return false, err
+	}
+
+	codeHash := s.hasher.HashWithNonceHash(binding.Key, nonceHash, code)
+	reissued, err := reissueScript.Run(ctx, s.client, []string{redisCodeKey(binding.Key)},
+		messageID, provider, nonceHash, codeHash).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to reissue OTP in redis: %w", err)
+	}
+	return reissued == 1, nil
+}
+
+// messageOTP возвращает код, привязанный к сообщению, и хеш его nonce
+func (s *RedisOTPStore) messageOTP(ctx context.Context, provider, messageID string) (*OTPBinding, string, error) {
+	ref := messageRef(provider, messageID)
+	codeKey, err := s.client.Get(ctx, redisMessageKey(ref)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to look up message %s in redis: %w", ref, err)
+	}
+
+	purpose, phone, ok := strings.Cut(strings.TrimPrefix(codeKey, "otp:code:"), ":")
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected OTP key in message index: %s", codeKey)
+	}
+
+	values, err := s.client.HMGet(ctx, codeKey, "message_id", "provider", "locale", "nonce_hash").Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read OTP from redis: %w", err)
+	}
+	if redisString(values[0]) != messageID || redisString(values[1]) != provider {
+		return nil, "", nil
+	}
+
+	binding := &OTPBinding{
+		Key:    OTPKey{Purpose: domain.OTPPurpose(purpose), Phone: phone},
+		Locale: redisString(values[2]),
+	}
+	return binding, redisString(values[3]), nil
+}
+
+func (s *RedisOTPStore) Reroute(oldProvider, oldMessageID string, sms *SMSResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), receiptOpTimeout)
+	defer cancel()
+
+	oldRef := messageRef(oldProvider, oldMessageID)
+	codeKey, err := s.client.Get(ctx, redisMessageKey(oldRef)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Failed to look up message %s in redis: %v", oldRef, err)
+		}
+		return
+	}
+
+	bound, err := s.bindMessage(ctx, codeKey, sms, oldProvider, oldMessageID)
+	if err != nil {
+		log.Printf("Failed to reroute OTP %s: %v", codeKey, err)
+		return
+	}
+	if bound {
+		log.Printf("OTP %s rerouted via %s (message_id=%s)", codeKey, sms.Provider, sms.MessageID)
+	}
+}
+
+func (s *RedisOTPStore) bindMessage(ctx context.Context, codeKey string, sms *SMSResult, expectedProvider, expectedMessageID string) (bool, error) {
+	ref := messageRef(sms.Provider, sms.MessageID)
+	keys := []string{codeKey, redisMessageKey(ref), redisEarlyKey(ref)}
+
+	bound, err := bindMessageScript.Run(ctx, s.client, keys,
+		sms.MessageID, sms.Provider, time.Now().UnixMilli(), codeKey, expectedMessageID, expectedProvider).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to bind SMS message in redis: %w", err)
+	}
+	return bound == 1, nil
+}
+
+func (s *RedisOTPStore) ApplyReceipt(receipt DeliveryReceipt) {
+	ctx, cancel := context.WithTimeout(context.Background(), receiptOpTimeout)
+	defer cancel()
+
+	ref := messageRef(receipt.Provider, receipt.MessageID)
+
+	codeKey, err := s.client.Get(ctx, redisMessageKey(ref)).Result()
+	if errors.Is(err, redis.Nil) {
+		held, err := holdEarlyReceiptScript.Run(ctx, s.client,
+			[]string{redisEarlyKey(ref), redisEarlyCountKey},
+			string(receipt.Status), receiptHoldTime.Milliseconds(), maxEarlyReceipts).Int()
+		if err != nil {
+			log.Printf("Failed to hold early receipt %s in redis: %v", ref, err)
+		} else if held == 0 {
+			log.Printf("Early receipt %s dropped: limit of %d receipts reached", ref, maxEarlyReceipts)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up message %s in redis: %v", ref, err)
+		return
+	}
+
+	applied, err := applyReceiptScript.Run(ctx, s.client, []string{codeKey},
+		receipt.MessageID, string(receipt.Status), time.Now().UnixMilli(), receipt.Provider).Int()
+	if err != nil {
+		log.Printf("Failed to apply receipt %s in redis: %v", ref, err)
+		return
+	}
+
+	if applied == 1 {
+		log.Printf("OTP delivery status for %s: %s (message_id=%s)", codeKey, receipt.Status, ref)
+	}
+}
+
+func (s *RedisOTPStore) GetDeliveryStatus(ctx context.Context, key OTPKey, nonce string) (*OTPDeliveryInfo, error) {
+	values, err := s.client.HMGet(ctx, redisCodeKey(key), "provider", "status", "status_updated_at", "expires_at", "nonce_hash").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OTP status from redis: %w", err)
+	}
+	if values[1] == nil || !s.hasher.VerifyNonce(redisString(values[4]), key, nonce) {
+		return nil, domain.ErrOTPNotFound
+	}
+
+	return &OTPDeliveryInfo{
+		Provider:  redisString(values[0]),
+		Status:    domain.DeliveryStatus(redisString(values[1])),
+		UpdatedAt: redisTime(values[2]),
+		ExpiresAt: redisTime(values[3]),
+	}, nil
+}
+
+// ReserveSend читает историю и добавляет отправку в одной WATCH-транзакции,
+// повторяя ее при конкурентном изменении ключа другой репликой
+func (s *RedisOTPStore) ReserveSend(ctx context.Context, phone string, policy *OTPSendPolicy) (*OTPSendReservation, error) {
+	key := redisSendsKey(phone)
+
+	var (
+		wait time.Duration
+		now  time.Time
+	)
+	reserve := func(tx *redis.Tx) error {
+		now = time.Now().Truncate(time.Millisecond)
+		since := strconv.FormatInt(now.Add(-otpSendWindow).UnixMilli(), 10)
+
+		scores, err := tx.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "(" + since, Max: "+inf"}).Result()
+		if err != nil {
+			return err
+		}
+
+		sends := make([]time.Time, 0, len(scores))
+		for _, member := range scores {
+			sends = append(sends, redisTime(member))
+		}
+
+		wait, err = policy.reserve(sends, now)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRemRangeByScore(ctx, key, "-inf", since)
+			pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixMilli()), Member: now.UnixMilli()})
+			pipe.PExpire(ctx, key, otpSendWindow)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		err := s.client.Watch(ctx, reserve, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		var throttled *OTPThrottleError
+		if errors.As(err, &throttled) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve OTP send in redis: %w", err)
+		}
+		return &OTPSendReservation{Phone: phone, At: now, RetryAfter: wait}, nil
+	}
+
+	return nil, fmt.Errorf("failed to reserve OTP send in redis: too much contention")
+}
+
+func (s *RedisOTPStore) ReleaseSend(ctx context.Context, reservation *OTPSendReservation) error {
+	err := s.client.ZRem(ctx, redisSendsKey(reservation.Phone), reservation.At.UnixMilli()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to release OTP send in redis: %w", err)
+	}
+	return nil
+}
+
+func redisString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// redisTime читает время, сохраненное в миллисекундах
+func redisTime(value interface{}) time.Time {
+	millis, err := strconv.ParseInt(redisString(value), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis поднимает miniredis на время теста
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestRedisOTPStoreContract(t *testing.T) {
+	runOTPStoreContract(t, func(t *testing.T) otpStoreHarness {
+		server, client := newTestRedis(t)
+		return otpStoreHarness{
+			store: NewRedisOTPStore(client, NewOTPHasher([]byte("test"))),
+			expire: func(t *testing.T, key OTPKey) {
+				server.FastForward(otpTTL + time.Second)
+			},
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sms-service/internal/domain"
+	"testing"
+	"time"
+)
+
+// otpStoreHarness - хранилище под контрактным тестом и способ состарить его код
+type otpStoreHarness struct {
+	store OTPStore
+	// expire переводит код за срок жизни, не дожидаясь otpTTL
+	expire func(t *testing.T, key OTPKey)
+}
+
+// runOTPStoreContract проверяет поведение, общее для всех реализаций OTPStore.
+// newHarness вызывается для каждого подтеста и должен возвращать пустое хранилище
+func runOTPStoreContract(t *testing.T, newHarness func(t *testing.T) otpStoreHarness) {
+	login := OTPKey{Purpose: domain.OTPPurposeLogin, Phone: "+79991234567"}
+	register := OTPKey{Purpose: domain.OTPPurposeRegister, Phone: login.Phone}
+
+	t.Run("save and verify", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := mustSaveOTP(t, h.store, login, "nonce", "")
+		if len(code) != otpLength {
+			t.Errorf("code length = %d, want %d", len(code), otpLength)
+		}
+
+		if err := h.store.VerifyOTP(ctx, login, code, "nonce"); err != nil {
+			t.Fatalf("VerifyOTP: %v", err)
+		}
+		if err := h.store.VerifyOTP(ctx, login, code, "nonce"); !errors.Is(err, domain.ErrOTPNotFound) {
+			t.Errorf("second VerifyOTP: got %v, want %v", err, domain.ErrOTPNotFound)
+		}
+	})
+
+	t.Run("save replaces code", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		first := mustSaveOTP(t, h.store, login, "", "")
+		second := mustSaveOTP(t, h.store, login, "", "")
+		if first == second {
+			// Совпадение возможно с вероятностью 10^-6, проверять замену тогда нечем
+			t.Skip("new code matches the previous one")
+		}
+
+		if err := h.store.VerifyOTP(ctx, login, first, ""); !errors.Is(err, domain.ErrInvalidOTP) {
+			t.Errorf("previous code: got %v, want %v", err, domain.ErrInvalidOTP)
+		}
+		if err := h.store.VerifyOTP(ctx, login, second, ""); err != nil {
+			t.Errorf("current code: %v", err)
+		}
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := mustSaveOTP(t, h.store, login, "", "")
+		wrong := wrongOTP(code)
+
+		for remaining := otpMaxAttempts - 1; remaining >= 0; remaining-- {
+			err := h.store.VerifyOTP(ctx, login, wrong, "")
+			var attemptErr *OTPAttemptError
+			if !errors.As(err, &attemptErr) {
+				t.Fatalf("wrong code: got %v, want *OTPAttemptError", err)
+			}
+			if attemptErr.Remaining != remaining {
+				t.Errorf("remaining = %d, want %d", attemptErr.Remaining, remaining)
+			}
+		}
+
+		if err := h.store.VerifyOTP(ctx, login, code, ""); !errors.Is(err, domain.ErrOTPMaxAttempts) {
+			t.Errorf("correct code after max attempts: got %v, want %v", err, domain.ErrOTPMaxAttempts)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := mustSaveOTP(t, h.store, login, "client-nonce", "")
+
+		if err := h.store.VerifyOTP(ctx, login, code, "other-nonce"); !errors.Is(err, domain.ErrInvalidOTP) {
+			t.Errorf("other nonce: got %v, want %v", err, domain.ErrInvalidOTP)
+		}
+		if err := h.store.VerifyOTP(ctx, login, code, "client-nonce"); err != nil {
+			t.Errorf("client nonce: %v", err)
+		}
+	})
+
+	t.Run("wrong purpose", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := mustSaveOTP(t, h.store, register, "", "")
+
+		if err := h.store.VerifyOTP(ctx, login, code, ""); !errors.Is(err, domain.ErrOTPWrongPurpose) {
+			t.Errorf("got %v, want %v", err, domain.ErrOTPWrongPurpose)
+		}
+		if err := h.store.VerifyOTP(ctx, register, code, ""); err != nil {
+			t.Errorf("matching purpose: %v", err)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := mustSaveOTP(t, h.store, login, "nonce", "")
+		h.expire(t, login)
+
+		err := h.store.VerifyOTP(ctx, login, code, "nonce")
+		if !errors.Is(err, domain.ErrOTPExpired) && !errors.Is(err, domain.ErrOTPNotFound) {
+			t.Errorf("expired code: got %v, want %v or %v", err, domain.ErrOTPExpired, domain.ErrOTPNotFound)
+		}
+		if _, err := h.store.GetDeliveryStatus(ctx, login, "nonce"); !errors.Is(err, domain.ErrOTPNotFound) {
+			t.Errorf("status of expired code: got %v, want %v", err, domain.ErrOTPNotFound)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := mustSaveOTP(t, h.store, login, "", "")
+		if err := h.store.DeleteOTP(ctx, login); err != nil {
+			t.Fatalf("DeleteOTP: %v", err)
+		}
+		if err := h.store.VerifyOTP(ctx, login, code, ""); !errors.Is(err, domain.ErrOTPNotFound) {
+			t.Errorf("deleted code: got %v, want %v", err, domain.ErrOTPNotFound)
+		}
+	})
+
+	t.Run("delivery status requires nonce", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		mustSaveOTP(t, h.store, login, "nonce", "")
+
+		expectDeliveryStatus(t, h.store, login, "nonce", "primary", domain.DeliveryStatusSent)
+
+		for _, nonce := range []string{"", "other"} {
+			if _, err := h.store.GetDeliveryStatus(ctx, login, nonce); !errors.Is(err, domain.ErrOTPNotFound) {
+				t.Errorf("nonce %q: got %v, want %v", nonce, err, domain.ErrOTPNotFound)
+			}
+		}
+
+		mustSaveOTP(t, h.store, register, "", "")
+		if _, err := h.store.GetDeliveryStatus(ctx, register, ""); !errors.Is(err, domain.ErrOTPNotFound) {
+			t.Errorf("code without nonce: got %v, want %v", err, domain.ErrOTPNotFound)
+		}
+	})
+
+	t.Run("receipts", func(t *testing.T) {
+		h := newHarness(t)
+
+		mustSaveOTP(t, h.store, login, "nonce", "m1")
+		expectDeliveryStatus(t, h.store, login, "nonce", "primary", domain.DeliveryStatusSent)
+
+		// Тот же message ID у другого провайдера - другое сообщение
+		h.store.ApplyReceipt(DeliveryReceipt{Provider: "backup", MessageID: "m1", Status: domain.DeliveryStatusFailed, ReceivedAt: time.Now()})
+		expectDeliveryStatus(t, h.store, login, "nonce", "primary", domain.DeliveryStatusSent)
+
+		h.store.ApplyReceipt(DeliveryReceipt{Provider: "primary", MessageID: "m1", Status: domain.DeliveryStatusDelivered, ReceivedAt: time.Now()})
+		expectDeliveryStatus(t, h.store, login, "nonce", "primary", domain.DeliveryStatusDelivered)
+
+		// Промежуточный статус после финального не откатывает его
+		h.store.ApplyReceipt(DeliveryReceipt{Provider: "primary", MessageID: "m1", Status: domain.DeliveryStatusSent, ReceivedAt: time.Now()})
+		expectDeliveryStatus(t, h.store, login, "nonce", "primary", domain.DeliveryStatusDelivered)
+	})
+
+	t.Run("early receipt", func(t *testing.T) {
+		h := newHarness(t)
+
+		// DLR пришел раньше, чем код сохранен после ответа провайдера
+		h.store.ApplyReceipt(DeliveryReceipt{Provider: "primary", MessageID: "m1", Status: domain.DeliveryStatusDelivered, ReceivedAt: time.Now()})
+
+		mustSaveOTP(t, h.store, login, "nonce", "m1")
+		expectDeliveryStatus(t, h.store, login, "nonce", "primary", domain.DeliveryStatusDelivered)
+	})
+
+	t.Run("reroute", func(t *testing.T) {
+		h := newHarness(t)
+
+		mustSaveOTP(t, h.store, login, "nonce", "m1")
+
+		h.store.Reroute("primary", "m1", &SMSResult{Provider: "backup", MessageID: "m2"})
+		expectDeliveryStatus(t, h.store, login, "nonce", "backup", domain.DeliveryStatusSent)
+
+		// Отчет по старому сообщению больше не относится к коду
+		h.store.ApplyReceipt(DeliveryReceipt{Provider: "primary", MessageID: "m1", Status: domain.DeliveryStatusFailed, ReceivedAt: time.Now()})
+		expectDeliveryStatus(t, h.store, login, "nonce", "backup", domain.DeliveryStatusSent)
+
+		h.store.ApplyReceipt(DeliveryReceipt{Provider: "backup", MessageID: "m2", Status: domain.DeliveryStatusDelivered, ReceivedAt: time.Now()})
+		expectDeliveryStatus(t, h.store, login, "nonce", "backup", domain.DeliveryStatusDelivered)
+	})
+
+	t.Run("reissue", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+
+		code := NewOTPCode()
+		issue := OTPIssue{Key: login, Nonce: "nonce", Code: code, Locale: "ru", SMS: &SMSResult{Provider: "primary", MessageID: "m1"}}
+		if err := h.store.SaveOTP(ctx, issue); err != nil {
+			t.Fatalf("SaveOTP: %v", err)
+		}
+
+		binding, err := h.store.MessageOTP(ctx, "primary", "m1")
+		if err != nil {
+			t.Fatalf("MessageOTP: %v", err)
+		}
+		if binding == nil || binding.Key != login || binding.Locale != "ru" {
+			t.Fatalf("MessageOTP = %+v, want key %s, locale ru", binding, login)
+		}
+		if binding, _ := h.store.MessageOTP(ctx, "backup", "m1"); binding != nil {
+			t.Errorf("MessageOTP of other provider = %+v, want nil", binding)
+		}
+
+		reissued := wrongOTP(code)
+		if ok, err := h.store.ReissueOTP(ctx, "primary", "m1", reissued); err != nil || !ok {
+			t.Fatalf("ReissueOTP = %v, %v, want true", ok, err)
+		}
+
+		// Новый код проверяется с исходным nonce, старый больше не действует
+		var attemptErr *OTPAttemptError
+		if err := h.store.VerifyOTP(ctx, login, code, "nonce"); !errors.As(err, &attemptErr) {
+			t.Fatalf("VerifyOTP old code: got %v, want *OTPAttemptError", err)
+		}
+
+		h.store.Reroute("primary", "m1", &SMSResult{Provider: "backup", MessageID: "m2"})
+		if binding, _ := h.store.MessageOTP(ctx, "primary", "m1"); binding != nil {
+			t.Errorf("MessageOTP after reroute = %+v, want nil", binding)
+		}
+		if ok, _ := h.store.ReissueOTP(ctx, "primary", "m1", code); ok {
+			t.Error("ReissueOTP after reroute succeeded")
+		}
+
+		if err := h.store.VerifyOTP(ctx, login, reissued, "nonce"); err != nil {
+			t.Fatalf("VerifyOTP reissued code: %v", err)
+		}
+		if binding, _ := h.store.MessageOTP(ctx, "backup", "m2"); binding != nil {
+			t.Errorf("MessageOTP after verify = %+v, want nil", binding)
+		}
+	})
+
+	t.Run("send reservation", func(t *testing.T) {
+		ctx := context.Background()
+		h := newHarness(t)
+		policy := &OTPSendPolicy{Cooldown: time.Minute, MaxCooldown: time.Hour, HourlyLimit: 5, DailyLimit: 10}
+
+		reservation, err := h.store.ReserveSend(ctx, login.Phone, policy)
+		if err != nil {
+			t.Fatalf("ReserveSend: %v", err)
+		}
+
+		var throttleErr *OTPThrottleError
+		if _, err := h.store.ReserveSend(ctx, login.Phone, policy); !errors.As(err, &throttleErr) {
+			t.Fatalf("second ReserveSend: got %v, want *OTPThrottleError", err)
+		}
+		if throttleErr.RetryAfter <= 0 {
+			t.Errorf("RetryAfter = %s, want > 0", throttleErr.RetryAfter)
+		}
+
+		if _, err := h.store.ReserveSend(ctx, "+79990000000", policy); err != nil {
+			t.Errorf("other phone: %v", err)
+		}
+
+		if err := h.store.ReleaseSend(ctx, reservation); err != nil {
+			t.Fatalf("ReleaseSend: %v", err)
+		}
+		if _, err := h.store.ReserveSend(ctx, login.Phone, policy); err != nil {
+			t.Errorf("ReserveSend after release: %v", err)
+		}
+	})
+}
+
+// mustSaveOTP сохраняет новый код, отправленный провайдером primary
+func mustSaveOTP(t *testing.T, store OTPStore, key OTPKey, nonce, messageID string) string {
+	t.Helper()
+
+	code := NewOTPCode()
+	sms := &SMSResult{Provider: "primary", MessageID: messageID}
+	if err := store.SaveOTP(context.Background(), OTPIssue{Key: key, Nonce: nonce, Code: code, SMS: sms}); err != nil {
+		t.Fatalf("SaveOTP: %v", err)
+	}
+	return code
+}
+
+// wrongOTP возвращает код той же длины, отличный от code
+func wrongOTP(code string) string {
+	last := code[len(code)-1]
+	return code[:len(code)-1] + string('0'+(last-'0'+1)%10)
+}
+
+func expectDeliveryStatus(t *testing.T, store OTPStore, key OTPKey, nonce, provider string, status domain.DeliveryStatus) {
+	t.Helper()
+
+	info, err := store.GetDeliveryStatus(context.Background(), key, nonce)
+	if err != nil {
+		t.Fatalf("GetDeliveryStatus: %v", err)
+	}
+	if info.Status != status || info.Provider != provider {
+		t.Errorf("delivery = %s via %q, want %s via %q", info.Status, info.Provider, status, provider)
+	}
+}
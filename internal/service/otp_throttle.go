@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sms-service/internal/domain"
+	"strconv"
+	"time"
+)
+
+// otpSendWindow - глубина истории отправок, нужная для суточной квоты
+const otpSendWindow = 24 * time.Hour
+
+// OTPSendPolicy - ограничения на отправку OTP на один номер:
+// пауза между отправками, растущая вдвое с каждой отправкой за последний час,
+// и лимиты отправок в час и в сутки
+type OTPSendPolicy struct {
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+	HourlyLimit int
+	DailyLimit  int
+}
+
+// OTPSendReservation - учтенная отправка кода на номер
+type OTPSendReservation struct {
+	Phone      string
+	At         time.Time
+	RetryAfter time.Duration // ожидание до следующей разрешенной отправки
+}
+
+// OTPThrottleError - отправка запрещена политикой, RetryAfter - сколько ждать
+type OTPThrottleError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *OTPThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *OTPThrottleError) Unwrap() error {
+	return e.Err
+}
+
+// NewOTPSendPolicy читает OTP_RESEND_COOLDOWN (60s), OTP_RESEND_MAX_COOLDOWN (15m),
+// OTP_HOURLY_LIMIT (5), OTP_DAILY_LIMIT (10); лимит 0 отключает проверку
+func NewOTPSendPolicy() (*OTPSendPolicy, error) {
+	cooldown, err := durationFromEnv("OTP_RESEND_COOLDOWN", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	maxCooldown, err := durationFromEnv("OTP_RESEND_MAX_COOLDOWN", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly, err := intFromEnv("OTP_HOURLY_LIMIT", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := intFromEnv("OTP_DAILY_LIMIT", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OTPSendPolicy{
+		Cooldown:    cooldown,
+		MaxCooldown: maxCooldown,
+		HourlyLimit: hourly,
+		DailyLimit:  daily,
+	}, nil
+}
+
+// Evaluate решает, можно ли отправить код сейчас, по времени прошлых отправок
+// (по возрастанию, не старше otpSendWindow). Возвращает ожидание и причину отказа.
+func (p *OTPSendPolicy) Evaluate(sends []time.Time, now time.Time) (time.Duration, error) {
+	var hourly []time.Time
+	for _, sentAt := range sends {
+		if now.Sub(sentAt) < time.Hour {
+			hourly = append(hourly, sentAt)
+		}
+	}
+
+	if p.DailyLimit > 0 && len(sends) >= p.DailyLimit {
+		oldest := sends[len(sends)-p.DailyLimit]
+		return oldest.Add(otpSendWindow).Sub(now), domain.ErrOTPSendLimit
+	}
+
+	if p.HourlyLimit > 0 && len(hourly) >= p.HourlyLimit {
+		oldest := hourly[len(hourly)-p.HourlyLimit]
+		return oldest.Add(time.Hour).Sub(now), domain.ErrOTPSendLimit
+	}
+
+	if len(hourly) > 0 {
+		last := hourly[len(hourly)-1]
+		if wait := last.Add(p.cooldownAfter(len(hourly))).Sub(now); wait > 0 {
+			return wait, domain.ErrOTPResendCooldown
+		}
+	}
+
+	return 0, nil
+}
+
+// NextWait - ожидание до следующей отправки после только что разрешенной
+func (p *OTPSendPolicy) NextWait(sends []time.Time, now time.Time) time.Duration {
+	wait, _ := p.Evaluate(append(sends, now), now)
+	return wait
+}
+
+// cooldownAfter - пауза после n-й отправки за час: Cooldown, 2*Cooldown, 4*Cooldown...
+func (p *OTPSendPolicy) cooldownAfter(n int) time.Duration {
+	cooldown := p.Cooldown
+	for i := 1; i < n; i++ {
+		cooldown *= 2
+		if p.MaxCooldown > 0 && cooldown >= p.MaxCooldown {
+			return p.MaxCooldown
+		}
+	}
+	return cooldown
+}
+
+// reserve применяет политику к истории: при отказе возвращает *OTPThrottleError
+func (p *OTPSendPolicy) reserve(sends []time.Time, now time.Time) (time.Duration, error) {
+	wait, reason := p.Evaluate(sends, now)
+	if reason != nil {
+		return 0, &OTPThrottleError{Err: reason, RetryAfter: wait}
+	}
+	return p.NextWait(sends, now), nil
+}
+
+// intFromEnv читает целое число из переменной окружения
+func intFromEnv(name string, fallback int) (int, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return value, nil
+}
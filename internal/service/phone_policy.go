@@ -0,0 +1,363 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sms-service/internal/domain"
+	phonelib "sms-service/internal/phone"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Типы линий телефонного номера
+const (
+	PhoneLineMobile      = "mobile"
+	PhoneLineFixed       = "fixed"
+	PhoneLineVoIP        = "voip"
+	PhoneLineTollFree    = "toll_free"
+	PhoneLinePremiumRate = "premium_rate"
+	PhoneLineUnknown     = "unknown"
+)
+
+// defaultLinePrefixes - встроенный план нумерации для определения типа линии.
+// Дополняется через lineTypes.prefixes в PHONE_POLICY_FILE
+var defaultLinePrefixes = map[string]string{
+	// Россия
+	"+79":   PhoneLineMobile,
+	"+73":   PhoneLineFixed,
+	"+74":   PhoneLineFixed,
+	"+78":   PhoneLineFixed,
+	"+7800": PhoneLineTollFree,
+	"+7803": PhoneLinePremiumRate,
+	"+7804": PhoneLinePremiumRate,
+	"+7809": PhoneLinePremiumRate,
+	// Казахстан
+	"+770": PhoneLineMobile,
+	"+771": PhoneLineFixed,
+	"+772": PhoneLineFixed,
+	"+775": PhoneLineMobile,
+	"+776": PhoneLineMobile,
+	"+777": PhoneLineMobile,
+	// Беларусь
+	"+37525":  PhoneLineMobile,
+	"+37529":  PhoneLineMobile,
+	"+37533":  PhoneLineMobile,
+	"+37544":  PhoneLineMobile,
+	"+37515":  PhoneLineFixed,
+	"+37516":  PhoneLineFixed,
+	"+37517":  PhoneLineFixed,
+	"+37521":  PhoneLineFixed,
+	"+37522":  PhoneLineFixed,
+	"+37523":  PhoneLineFixed,
+	"+375602": PhoneLineVoIP,
+	"+375902": PhoneLinePremiumRate,
+}
+
+// PhonePolicyConfig - правила политики номеров (YAML из PHONE_POLICY_FILE)
+type PhonePolicyConfig struct {
+	Countries struct {
+		Allow []string `yaml:"allow"` // коды стран без "+": "7", "375"
+		Deny  []string `yaml:"deny"`
+	} `yaml:"countries"`
+
+	Ranges struct {
+		Allow []string `yaml:"allow"` // префикс "+7999" или диапазон "+79990000000-+79990099999"
+		Deny  []string `yaml:"deny"`
+	} `yaml:"ranges"`
+
+	LineTypes struct {
+		Allow    []string            `yaml:"allow"`
+		Deny     []string            `yaml:"deny"`
+		Prefixes map[string][]string `yaml:"prefixes"` // тип линии -> префиксы, дополняют встроенный план
+	} `yaml:"lineTypes"`
+
+	Blacklist     []string      `yaml:"blacklist"`
+	BlacklistFile string        `yaml:"blacklistFile"` // один номер на строку, # - комментарий
+	BlacklistPoll time.Duration `yaml:"blacklistPoll"` // как часто проверять изменения файла
+}
+
+// PhonePolicy - правила, по которым номер допускается к регистрации.
+// Одна и та же политика применяется в RegisterSendOTP и в pre-registration webhook
+type PhonePolicy struct {
+	allowCountries []string
+	denyCountries  []string
+	allowRanges    []phoneRange
+	denyRanges     []phoneRange
+	allowLines     map[string]bool
+	denyLines      map[string]bool
+	linePrefixes   map[string]string
+
+	mu            sync.RWMutex
+	blacklist     map[string]struct{}
+	fileBlacklist map[string]struct{}
+	blacklistFile string
+	blacklistMod  time.Time
+}
+
+// phoneRange - префикс номера либо включительный диапазон номеров одинаковой длины
+type phoneRange struct {
+	prefix string
+	from   string
+	to     string
+}
+
+func (r phoneRange) contains(phone string) bool {
+	if r.prefix != "" {
+		return strings.HasPrefix(phone, r.prefix)
+	}
+	return len(phone) == len(r.from) && phone >= r.from && phone <= r.to
+}
+
+// NewPhonePolicy читает политику из PHONE_POLICY_FILE. Без файла используются
+// PHONE_ALLOWED_PREFIXES (по умолчанию +7) и PHONE_BLACKLIST через запятую
+func NewPhonePolicy() (*PhonePolicy, error) {
+	config := &PhonePolicyConfig{}
+
+	if path := os.Getenv("PHONE_POLICY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read phone policy: %w", err)
+		}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse phone policy %s: %w", path, err)
+		}
+		log.Printf("Phone policy loaded from %s", path)
+	} else {
+		config.Ranges.Allow = splitList(os.Getenv("PHONE_ALLOWED_PREFIXES"), ",")
+		if len(config.Ranges.Allow) == 0 {
+			config.Ranges.Allow = []string{"+7"}
+		}
+		config.Blacklist = splitList(os.Getenv("PHONE_BLACKLIST"), ",")
+	}
+
+	return NewPhonePolicyFromConfig(config)
+}
+
+// NewPhonePolicyFromConfig строит политику из готовой конфигурации
+func NewPhonePolicyFromConfig(config *PhonePolicyConfig) (*PhonePolicy, error) {
+	policy := &PhonePolicy{
+		allowCountries: trimCountryCodes(config.Countries.Allow),
+		denyCountries:  trimCountryCodes(config.Countries.Deny),
+		allowLines:     make(map[string]bool),
+		denyLines:      make(map[string]bool),
+		linePrefixes:   make(map[string]string, len(defaultLinePrefixes)),
+		blacklist:      make(map[string]struct{}),
+		blacklistFile:  config.BlacklistFile,
+	}
+
+	var err error
+	if policy.allowRanges, err = parsePhoneRanges(config.Ranges.Allow); err != nil {
+		return nil, err
+	}
+	if policy.denyRanges, err = parsePhoneRanges(config.Ranges.Deny); err != nil {
+		return nil, err
+	}
+
+	for _, line := range config.LineTypes.Allow {
+		policy.allowLines[line] = true
+	}
+	for _, line := range config.LineTypes.Deny {
+		policy.denyLines[line] = true
+	}
+
+	for prefix, line := range defaultLinePrefixes {
+		policy.linePrefixes[prefix] = line
+	}
+	for line, prefixes := range config.LineTypes.Prefixes {
+		for _, prefix := range prefixes {
+			policy.linePrefixes[prefix] = line
+		}
+	}
+
+	for _, entry := range config.Blacklist {
+		policy.blacklist[normalizeBlacklistEntry(entry)] = struct{}{}
+	}
+
+	if policy.blacklistFile != "" {
+		if err := policy.reloadBlacklist(); err != nil {
+			return nil, err
+		}
+
+		poll := config.BlacklistPoll
+		if poll <= 0 {
+			poll = time.Minute
+		}
+		go policy.watchBlacklist(poll)
+	}
+
+	return policy, nil
+}
+
+// Check возвращает domain.ErrPhoneNotAllowed, domain.ErrPhoneTypeNotAllowed
+// или domain.ErrPhoneBlacklisted, если номер не проходит политику. phone - номер в E.164
+func (p *PhonePolicy) Check(phone string) error {
+	if len(p.allowCountries) > 0 && !hasAnyPrefix(phone, p.allowCountries) {
+		log.Printf("Phone policy: %s country is not allowed", phone)
+		return domain.ErrPhoneNotAllowed
+	}
+	if hasAnyPrefix(phone, p.denyCountries) {
+		log.Printf("Phone policy: %s country is denied", phone)
+		return domain.ErrPhoneNotAllowed
+	}
+
+	if len(p.allowRanges) > 0 && !inAnyRange(phone, p.allowRanges) {
+		log.Printf("Phone policy: %s is outside allowed ranges", phone)
+		return domain.ErrPhoneNotAllowed
+	}
+	if inAnyRange(phone, p.denyRanges) {
+		log.Printf("Phone policy: %s is in a denied range", phone)
+		return domain.ErrPhoneNotAllowed
+	}
+
+	line := p.LineType(phone)
+	if (len(p.allowLines) > 0 && !p.allowLines[line]) || p.denyLines[line] {
+		log.Printf("Phone policy: %s line type %s is not allowed", phone, line)
+		return domain.ErrPhoneTypeNotAllowed
+	}
+
+	p.mu.RLock()
+	_, blocked := p.blacklist[phone]
+	if !blocked {
+		_, blocked = p.fileBlacklist[phone]
+	}
+	p.mu.RUnlock()
+
+	if blocked {
+		return domain.ErrPhoneBlacklisted
+	}
+
+	return nil
+}
+
+// LineType определяет тип линии по самому длинному совпавшему префиксу плана нумерации
+func (p *PhonePolicy) LineType(phone string) string {
+	line := PhoneLineUnknown
+	longest := 0
+
+	for prefix, prefixLine := range p.linePrefixes {
+		if len(prefix) > longest && strings.HasPrefix(phone, prefix) {
+			line = prefixLine
+			longest = len(prefix)
+		}
+	}
+
+	return line
+}
+
+func (p *PhonePolicy) reloadBlacklist() error {
+	info, err := os.Stat(p.blacklistFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat blacklist file: %w", err)
+	}
+
+	p.mu.RLock()
+	unchanged := info.ModTime().Equal(p.blacklistMod)
+	p.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	file, err := os.Open(p.blacklistFile)
+	if err != nil {
+		return fmt.Errorf("failed to open blacklist file: %w", err)
+	}
+	defer file.Close()
+
+	blacklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if entry := strings.TrimSpace(line); entry != "" {
+			blacklist[normalizeBlacklistEntry(entry)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blacklist file: %w", err)
+	}
+
+	p.mu.Lock()
+	p.fileBlacklist = blacklist
+	p.blacklistMod = info.ModTime()
+	p.mu.Unlock()
+
+	log.Printf("Phone blacklist loaded: %d numbers from %s", len(blacklist), p.blacklistFile)
+	return nil
+}
+
+func (p *PhonePolicy) watchBlacklist(poll time.Duration) {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := p.reloadBlacklist(); err != nil {
+			// Оставляем последний успешно загруженный список
+			log.Printf("Failed to reload phone blacklist: %v", err)
+		}
+	}
+}
+
+// normalizeBlacklistEntry приводит номер из черного списка к E.164, нераспознанные записи остаются как есть
+func normalizeBlacklistEntry(entry string) string {
+	normalized, err := phonelib.Normalize(entry)
+	if err != nil {
+		log.Printf("Phone blacklist: keeping unparsed entry %q: %v", entry, err)
+		return entry
+	}
+	return normalized
+}
+
+func parsePhoneRanges(raw []string) ([]phoneRange, error) {
+	ranges := make([]phoneRange, 0, len(raw))
+
+	for _, entry := range raw {
+		from, to, isRange := strings.Cut(strings.TrimSpace(entry), "-")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+
+		if !isRange {
+			if from == "" {
+				return nil, fmt.Errorf("invalid phone prefix %q", entry)
+			}
+			ranges = append(ranges, phoneRange{prefix: from})
+			continue
+		}
+
+		if len(from) != len(to) || from > to {
+			return nil, fmt.Errorf("invalid phone range %q: bounds must have equal length and from <= to", entry)
+		}
+		ranges = append(ranges, phoneRange{from: from, to: to})
+	}
+
+	return ranges, nil
+}
+
+func inAnyRange(phone string, ranges []phoneRange) bool {
+	for _, r := range ranges {
+		if r.contains(phone) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(phone string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(phone, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimCountryCodes приводит коды стран к префиксу номера: "7" -> "+7"
+func trimCountryCodes(codes []string) []string {
+	prefixes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		prefixes = append(prefixes, "+"+strings.TrimPrefix(strings.TrimSpace(code), "+"))
+	}
+	return prefixes
+}
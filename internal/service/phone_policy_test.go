@@ -0,0 +1,139 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sms-service/internal/domain"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadExamplePhonePolicy читает phone_policy.example.yaml из корня репозитория
+func loadExamplePhonePolicy(t *testing.T) *PhonePolicyConfig {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "phone_policy.example.yaml"))
+	if err != nil {
+		t.Fatalf("read example policy: %v", err)
+	}
+	config := &PhonePolicyConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		t.Fatalf("parse example policy: %v", err)
+	}
+	return config
+}
+
+func writeBlacklist(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write blacklist: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes blacklist: %v", err)
+	}
+}
+
+func TestPhonePolicyCheckExample(t *testing.T) {
+	config := loadExamplePhonePolicy(t)
+	config.Blacklist = []string{"8 (999) 000-00-01"}
+	config.BlacklistFile = filepath.Join(t.TempDir(), "blacklist.txt")
+	config.BlacklistPoll = time.Hour
+	writeBlacklist(t, config.BlacklistFile, "# blocked\n+79990000002\n\n89990000003 # fraud\n", time.Now().Add(-time.Hour))
+
+	policy, err := NewPhonePolicyFromConfig(config)
+	if err != nil {
+		t.Fatalf("NewPhonePolicyFromConfig: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		phone string
+		want  error
+	}{
+		{"russian mobile", "+79991234567", nil},
+		{"kazakh mobile", "+77011234567", nil},
+		{"belarusian mobile", "+375291234567", nil},
+		{"country not allowed", "+12025550123", domain.ErrPhoneNotAllowed},
+		{"denied range", "+78001234567", domain.ErrPhoneNotAllowed},
+		{"fixed line", "+74951234567", domain.ErrPhoneTypeNotAllowed},
+		{"premium rate", "+78031234567", domain.ErrPhoneTypeNotAllowed},
+		{"voip from configured prefix", "+79581234567", domain.ErrPhoneTypeNotAllowed},
+		{"belarusian voip", "+375602123456", domain.ErrPhoneTypeNotAllowed},
+		{"unknown line type", "+76001234567", domain.ErrPhoneTypeNotAllowed},
+		{"inline blacklist", "+79990000001", domain.ErrPhoneBlacklisted},
+		{"blacklist file", "+79990000002", domain.ErrPhoneBlacklisted},
+		{"normalized blacklist file entry", "+79990000003", domain.ErrPhoneBlacklisted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Check(tt.phone); !errors.Is(err, tt.want) {
+				t.Errorf("Check(%s): got %v, want %v", tt.phone, err, tt.want)
+			}
+		})
+	}
+
+	// Изменения файла подхватываются при следующей проверке
+	writeBlacklist(t, config.BlacklistFile, "+79991234567\n", time.Now())
+	if err := policy.reloadBlacklist(); err != nil {
+		t.Fatalf("reloadBlacklist: %v", err)
+	}
+	if err := policy.Check("+79991234567"); !errors.Is(err, domain.ErrPhoneBlacklisted) {
+		t.Errorf("added to blacklist file: got %v, want %v", err, domain.ErrPhoneBlacklisted)
+	}
+	if err := policy.Check("+79990000002"); err != nil {
+		t.Errorf("removed from blacklist file: %v", err)
+	}
+
+	// Пропавший файл не сбрасывает последний загруженный список
+	if err := os.Remove(config.BlacklistFile); err != nil {
+		t.Fatalf("remove blacklist: %v", err)
+	}
+	if err := policy.reloadBlacklist(); err == nil {
+		t.Error("reloadBlacklist of missing file: expected error")
+	}
+	if err := policy.Check("+79991234567"); !errors.Is(err, domain.ErrPhoneBlacklisted) {
+		t.Errorf("after failed reload: got %v, want %v", err, domain.ErrPhoneBlacklisted)
+	}
+}
+
+func TestPhonePolicyRanges(t *testing.T) {
+	config := &PhonePolicyConfig{}
+	config.Countries.Deny = []string{"+375"}
+	config.Ranges.Allow = []string{"+79990000000-+79990099999", "+375"}
+	config.Ranges.Deny = []string{"+7999005"}
+
+	policy, err := NewPhonePolicyFromConfig(config)
+	if err != nil {
+		t.Fatalf("NewPhonePolicyFromConfig: %v", err)
+	}
+
+	tests := []struct {
+		phone string
+		want  error
+	}{
+		{"+79990000000", nil},
+		{"+79990099999", nil},
+		{"+79990100000", domain.ErrPhoneNotAllowed},
+		{"+7999000000", domain.ErrPhoneNotAllowed},
+		{"+79990051234", domain.ErrPhoneNotAllowed},
+		{"+375291234567", domain.ErrPhoneNotAllowed},
+	}
+	for _, tt := range tests {
+		if err := policy.Check(tt.phone); !errors.Is(err, tt.want) {
+			t.Errorf("Check(%s): got %v, want %v", tt.phone, err, tt.want)
+		}
+	}
+
+	for _, raw := range []string{"+79990099999-+79990000000", "+7999-+79990000000", " "} {
+		config := &PhonePolicyConfig{}
+		config.Ranges.Allow = []string{raw}
+		if _, err := NewPhonePolicyFromConfig(config); err == nil {
+			t.Errorf("range %q: expected error", raw)
+		}
+	}
+}
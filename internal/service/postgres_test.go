@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestPostgres подключается к TEST_DATABASE_URL и создает для теста отдельную схему,
+// чтобы тесты не зависели от состояния базы и друг от друга. Без TEST_DATABASE_URL тест пропускается
+func newTestPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("failed to create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Logf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	db, err := sql.Open("pgx", withSearchPath(t, dsn, schema))
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// withSearchPath добавляет search_path к DSN в виде URL или key=value
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	t.Helper()
+
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func migrationCount(t *testing.T) int {
+	t.Helper()
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+	return len(entries)
+}
+
+func appliedMigrations(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatalf("failed to count applied migrations: %v", err)
+	}
+	return count
+}
+
+func TestMigrationFilesAreOrdered(t *testing.T) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok || len(prefix) != 3 || !strings.HasSuffix(name, ".sql") {
+			t.Errorf("migration %s does not match NNN_name.sql", name)
+			continue
+		}
+		if other, exists := seen[prefix]; exists {
+			t.Errorf("migrations %s and %s share version %s", other, name, prefix)
+		}
+		seen[prefix] = name
+	}
+}
+
+func TestRunMigrationsIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPostgres(t)
+
+	if err := runMigrations(ctx, db); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if got, want := appliedMigrations(t, db), migrationCount(t); got != want {
+		t.Fatalf("applied %d migrations, want %d", got, want)
+	}
+
+	if err := runMigrations(ctx, db); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if got, want := appliedMigrations(t, db), migrationCount(t); got != want {
+		t.Errorf("after second run %d migrations recorded, want %d", got, want)
+	}
+}
+
+func TestRunMigrationsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPostgres(t)
+
+	// Реплики стартуют одновременно: advisory lock должен пропустить миграции только один раз
+	const replicas = 4
+	errs := make(chan error, replicas)
+	var wg sync.WaitGroup
+	for range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- runMigrations(ctx, db)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("runMigrations: %v", err)
+		}
+	}
+	if got, want := appliedMigrations(t, db), migrationCount(t); got != want {
+		t.Errorf("applied %d migrations, want %d", got, want)
+	}
+}
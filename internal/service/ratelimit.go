@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Алгоритмы ограничения частоты запросов
+const (
+	// RateLimitTokenBucket - ведро на Limit токенов, полностью наполняется за Window
+	RateLimitTokenBucket = "token_bucket"
+	// RateLimitSlidingWindow - не больше Limit запросов за любые Window
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// Измерения ключа лимита
+const (
+	RateLimitScopeIP     = "ip"
+	RateLimitScopeSubnet = "subnet" // /24 для IPv4, /64 для IPv6
+	RateLimitScopePhone  = "phone"
+)
+
+// RateLimitRule - лимит по одному измерению ключа
+type RateLimitRule struct {
+	Scope     string
+	Algorithm string
+	Limit     int
+	Window    time.Duration
+}
+
+// RateLimitResult - решение по одному запросу
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// RateLimiter учитывает запрос с ключом key по правилу rule
+// Реализации: MemoryRateLimiter (одна реплика), RedisRateLimiter (общие лимиты для всех реплик)
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, rule RateLimitRule) (*RateLimitResult, error)
+}
+
+// NewRateLimiter создает лимитер согласно RATE_LIMIT_BACKEND: memory (по умолчанию), redis
+func NewRateLimiter() (RateLimiter, error) {
+	backend := os.Getenv("RATE_LIMIT_BACKEND")
+
+	switch backend {
+	case "", "memory":
+		log.Printf("Using in-memory rate limiter")
+		return NewMemoryRateLimiter(), nil
+	case "redis":
+		client, err := NewRedisClient()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using Redis rate limiter")
+		return NewRedisRateLimiter(client), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND: %s", backend)
+	}
+}
+
+// LoadRateLimitRules читает правила маршрута из RATE_LIMIT_<ROUTE>, иначе берет defaults
+// Формат: "ip=sliding_window:20/1m;subnet=sliding_window:100/1h;phone=token_bucket:5/10m",
+// значение "off" отключает лимиты маршрута
+func LoadRateLimitRules(route, defaults string) ([]RateLimitRule, error) {
+	envName := "RATE_LIMIT_" + strings.ToUpper(strings.NewReplacer("-", "_", "/", "_").Replace(route))
+
+	raw, ok := os.LookupEnv(envName)
+	if !ok {
+		raw = defaults
+	}
+	if raw == "off" {
+		return nil, nil
+	}
+
+	rules, err := ParseRateLimitRules(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envName, err)
+	}
+	return rules, nil
+}
+
+// ParseRateLimitRules разбирает список правил, см. LoadRateLimitRules
+func ParseRateLimitRules(raw string) ([]RateLimitRule, error) {
+	var rules []RateLimitRule
+
+	for _, entry := range splitList(raw, ";") {
+		scope, spec, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("rule %q: expected scope=algorithm:limit/window", entry)
+		}
+
+		algorithm, limits, found := strings.Cut(spec, ":")
+		if !found {
+			return nil, fmt.Errorf("rule %q: expected algorithm:limit/window", entry)
+		}
+
+		limitRaw, windowRaw, found := strings.Cut(limits, "/")
+		if !found {
+			return nil, fmt.Errorf("rule %q: expected limit/window", entry)
+		}
+
+		rule := RateLimitRule{
+			Scope:     strings.TrimSpace(scope),
+			Algorithm: strings.TrimSpace(algorithm),
+		}
+
+		switch rule.Scope {
+		case RateLimitScopeIP, RateLimitScopeSubnet, RateLimitScopePhone:
+		default:
+			return nil, fmt.Errorf("rule %q: unknown scope %s", entry, rule.Scope)
+		}
+
+		switch rule.Algorithm {
+		case RateLimitTokenBucket, RateLimitSlidingWindow:
+		default:
+			return nil, fmt.Errorf("rule %q: unknown algorithm %s", entry, rule.Algorithm)
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(limitRaw))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("rule %q: invalid limit", entry)
+		}
+		rule.Limit = limit
+
+		window, err := time.ParseDuration(strings.TrimSpace(windowRaw))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("rule %q: invalid window", entry)
+		}
+		rule.Window = window
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock - управляемые часы для лимитеров
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// runRateLimiterContract проверяет алгоритмы, общие для всех реализаций RateLimiter.
+// newLimiter вызывается для каждого подтеста и должен возвращать лимитер без состояния на часах clock
+func runRateLimiterContract(t *testing.T, newLimiter func(t *testing.T, clock *fakeClock) RateLimiter) {
+	t.Run("token bucket", func(t *testing.T) {
+		clock := newFakeClock()
+		limiter := newLimiter(t, clock)
+		rule := RateLimitRule{Scope: RateLimitScopePhone, Algorithm: RateLimitTokenBucket, Limit: 3, Window: 30 * time.Second}
+
+		for want := 2; want >= 0; want-- {
+			expectAllowed(t, limiter, "phone:1", rule, want)
+		}
+		expectDenied(t, limiter, "phone:1", rule, 10*time.Second)
+
+		// Другой ключ расходует свое ведро
+		expectAllowed(t, limiter, "phone:2", rule, 2)
+
+		// Токен пополняется за Window/Limit
+		clock.Advance(10 * time.Second)
+		expectAllowed(t, limiter, "phone:1", rule, 0)
+		expectDenied(t, limiter, "phone:1", rule, 10*time.Second)
+
+		// Ведро не наполняется больше емкости
+		clock.Advance(time.Hour)
+		expectAllowed(t, limiter, "phone:1", rule, 2)
+	})
+
+	t.Run("sliding window", func(t *testing.T) {
+		clock := newFakeClock()
+		limiter := newLimiter(t, clock)
+		rule := RateLimitRule{Scope: RateLimitScopeIP, Algorithm: RateLimitSlidingWindow, Limit: 2, Window: time.Minute}
+
+		expectAllowed(t, limiter, "ip:1", rule, 1)
+		clock.Advance(10 * time.Second)
+		expectAllowed(t, limiter, "ip:1", rule, 0)
+
+		// Ждать, пока первый запрос выпадет из окна
+		clock.Advance(10 * time.Second)
+		expectDenied(t, limiter, "ip:1", rule, 40*time.Second)
+		expectAllowed(t, limiter, "ip:2", rule, 1)
+
+		clock.Advance(40 * time.Second)
+		expectAllowed(t, limiter, "ip:1", rule, 0)
+		expectDenied(t, limiter, "ip:1", rule, 10*time.Second)
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		limiter := newLimiter(t, newFakeClock())
+		rule := RateLimitRule{Scope: RateLimitScopeIP, Algorithm: "leaky_bucket", Limit: 1, Window: time.Minute}
+		if _, err := limiter.Allow(context.Background(), "ip:1", rule); err == nil {
+			t.Error("expected error for unknown algorithm")
+		}
+	})
+}
+
+func expectAllowed(t *testing.T, limiter RateLimiter, key string, rule RateLimitRule, remaining int) {
+	t.Helper()
+
+	result, err := limiter.Allow(context.Background(), key, rule)
+	if err != nil {
+		t.Fatalf("Allow(%s): %v", key, err)
+	}
+	if !result.Allowed || result.Remaining != remaining || result.Limit != rule.Limit {
+		t.Errorf("Allow(%s) = %+v, want allowed with %d remaining", key, result, remaining)
+	}
+}
+
+func expectDenied(t *testing.T, limiter RateLimiter, key string, rule RateLimitRule, retryAfter time.Duration) {
+	t.Helper()
+
+	result, err := limiter.Allow(context.Background(), key, rule)
+	if err != nil {
+		t.Fatalf("Allow(%s): %v", key, err)
+	}
+	if result.Allowed || result.RetryAfter != retryAfter {
+		t.Errorf("Allow(%s) = %+v, want denied with RetryAfter %s", key, result, retryAfter)
+	}
+}
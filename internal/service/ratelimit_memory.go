@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimiter хранит состояние лимитов в памяти процесса
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	windows map[string]*slidingWindow
+	now     func() time.Time // часы, подменяются в тестах
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	window    time.Duration
+}
+
+type slidingWindow struct {
+	hits   []time.Time
+	window time.Duration
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	limiter := &MemoryRateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		windows: make(map[string]*slidingWindow),
+		now:     time.Now,
+	}
+
+	go limiter.cleanup()
+
+	return limiter
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, rule RateLimitRule) (*RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	switch rule.Algorithm {
+	case RateLimitTokenBucket:
+		return l.allowTokenBucket(key, rule, now), nil
+	case RateLimitSlidingWindow:
+		return l.allowSlidingWindow(key, rule, now), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm: %s", rule.Algorithm)
+	}
+}
+
+func (l *MemoryRateLimiter) allowTokenBucket(key string, rule RateLimitRule, now time.Time) *RateLimitResult {
+	capacity := float64(rule.Limit)
+	perSecond := capacity / rule.Window.Seconds()
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: capacity, updatedAt: now, window: rule.Window}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*perSecond)
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+		return &RateLimitResult{Allowed: false, Limit: rule.Limit, RetryAfter: wait}
+	}
+
+	bucket.tokens--
+	return &RateLimitResult{Allowed: true, Limit: rule.Limit, Remaining: int(bucket.tokens)}
+}
+
+func (l *MemoryRateLimiter) allowSlidingWindow(key string, rule RateLimitRule, now time.Time) *RateLimitResult {
+	window, exists := l.windows[key]
+	if !exists {
+		window = &slidingWindow{window: rule.Window}
+		l.windows[key] = window
+	}
+
+	window.hits = trimHits(window.hits, now, rule.Window)
+
+	if len(window.hits) >= rule.Limit {
+		wait := window.hits[0].Add(rule.Window).Sub(now)
+		return &RateLimitResult{Allowed: false, Limit: rule.Limit, RetryAfter: wait}
+	}
+
+	window.hits = append(window.hits, now)
+	return &RateLimitResult{Allowed: true, Limit: rule.Limit, Remaining: rule.Limit - len(window.hits)}
+}
+
+// trimHits отбрасывает запросы, выпавшие из окна
+func trimHits(hits []time.Time, now time.Time, window time.Duration) []time.Time {
+	for i, hit := range hits {
+		if now.Sub(hit) < window {
+			return hits[i:]
+		}
+	}
+	return nil
+}
+
+func (l *MemoryRateLimiter) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		l.mu.Lock()
+		now := l.now()
+		for key, bucket := range l.buckets {
+			// Ведро полностью наполнилось - состояние можно забыть
+			if now.Sub(bucket.updatedAt) > bucket.window {
+				delete(l.buckets, key)
+			}
+		}
+		for key, window := range l.windows {
+			if window.hits = trimHits(window.hits, now, window.window); len(window.hits) == 0 {
+				delete(l.windows, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
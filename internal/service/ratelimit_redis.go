@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRateLimiter хранит состояние лимитов в Redis, чтобы лимиты действовали
+// на все реплики. Ключи: ratelimit:<key>, TTL не больше окна правила.
+type RedisRateLimiter struct {
+	client *redis.Client
+	now    func() time.Time // часы, подменяются в тестах
+}
+
+// tokenBucketScript - ведро токенов с ленивым пополнением
+// KEYS[1]; ARGV[1] - емкость, ARGV[2] - токенов в мс, ARGV[3] - now (мс), ARGV[4] - TTL (мс)
+// Возвращает {allowed, remaining, retry_after_ms}
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, math.floor(tokens), retry}
+`)
+
+// slidingWindowScript - журнал запросов в sorted set
+// KEYS[1]; ARGV[1] - лимит, ARGV[2] - окно (мс), ARGV[3] - now (мс), ARGV[4] - уникальный member
+// Возвращает {allowed, remaining, retry_after_ms}
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, limit - count - 1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now}
+`)
+
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client, now: time.Now}
+}
+
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, rule RateLimitRule) (*RateLimitResult, error) {
+	redisKey := "ratelimit:" + key
+	now := l.now().UnixMilli()
+	windowMs := rule.Window.Milliseconds()
+
+	var (
+		values []int64
+		err    error
+	)
+
+	switch rule.Algorithm {
+	case RateLimitTokenBucket:
+		rate := float64(rule.Limit) / float64(windowMs)
+		values, err = tokenBucketScript.Run(ctx, l.client, []string{redisKey},
+			rule.Limit, rate, now, windowMs).Int64Slice()
+	case RateLimitSlidingWindow:
+		values, err = slidingWindowScript.Run(ctx, l.client, []string{redisKey},
+			rule.Limit, windowMs, now, uniqueMember(now)).Int64Slice()
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm: %s", rule.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit in redis: %w", err)
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      rule.Limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+// uniqueMember - элемент sorted set, уникальный даже для запросов в одну миллисекунду
+func uniqueMember(now int64) string {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return fmt.Sprintf("%d-%s", now, hex.EncodeToString(suffix))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiterContract(t *testing.T) {
+	runRateLimiterContract(t, func(t *testing.T, clock *fakeClock) RateLimiter {
+		limiter := NewMemoryRateLimiter()
+		limiter.now = clock.Now
+		return limiter
+	})
+}
+
+func TestRedisRateLimiterContract(t *testing.T) {
+	runRateLimiterContract(t, func(t *testing.T, clock *fakeClock) RateLimiter {
+		_, client := newTestRedis(t)
+		limiter := NewRedisRateLimiter(client)
+		limiter.now = clock.Now
+		return limiter
+	})
+}
+
+func TestParseRateLimitRules(t *testing.T) {
+	rules, err := ParseRateLimitRules(" ip=sliding_window:20/1m; subnet = sliding_window:100/1h;phone=token_bucket:5/10m;")
+	if err != nil {
+		t.Fatalf("ParseRateLimitRules: %v", err)
+	}
+	want := []RateLimitRule{
+		{Scope: RateLimitScopeIP, Algorithm: RateLimitSlidingWindow, Limit: 20, Window: time.Minute},
+		{Scope: RateLimitScopeSubnet, Algorithm: RateLimitSlidingWindow, Limit: 100, Window: time.Hour},
+		{Scope: RateLimitScopePhone, Algorithm: RateLimitTokenBucket, Limit: 5, Window: 10 * time.Minute},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules:\n got %+v\nwant %+v", rules, want)
+	}
+
+	for _, raw := range []string{
+		"ip",
+		"ip=sliding_window",
+		"ip=sliding_window:20",
+		"user=sliding_window:20/1m",
+		"ip=leaky_bucket:20/1m",
+		"ip=sliding_window:0/1m",
+		"ip=sliding_window:x/1m",
+		"ip=sliding_window:20/0s",
+		"ip=sliding_window:20/minute",
+	} {
+		if _, err := ParseRateLimitRules(raw); err == nil {
+			t.Errorf("ParseRateLimitRules(%q): expected error", raw)
+		}
+	}
+}
+
+func TestLoadRateLimitRules(t *testing.T) {
+	defaults := "ip=sliding_window:20/1m"
+
+	rules, err := LoadRateLimitRules("send-otp", defaults)
+	if err != nil || len(rules) != 1 || rules[0].Limit != 20 {
+		t.Errorf("defaults: got %+v, %v", rules, err)
+	}
+
+	t.Setenv("RATE_LIMIT_SEND_OTP", "phone=token_bucket:3/10m")
+	rules, err = LoadRateLimitRules("send-otp", defaults)
+	if err != nil || len(rules) != 1 || rules[0].Scope != RateLimitScopePhone {
+		t.Errorf("override: got %+v, %v", rules, err)
+	}
+
+	t.Setenv("RATE_LIMIT_SEND_OTP", "off")
+	if rules, err := LoadRateLimitRules("send-otp", defaults); err != nil || rules != nil {
+		t.Errorf("off: got %+v, %v", rules, err)
+	}
+
+	t.Setenv("RATE_LIMIT_SEND_OTP", "ip=sliding_window")
+	if _, err := LoadRateLimitRules("send-otp", defaults); err == nil {
+		t.Error("invalid override: expected error")
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sms-service/internal/domain"
+	"sync"
+	"time"
+)
+
+// RefreshTokenRecord - выданный refresh token. Токены, полученные друг из друга обменом,
+// образуют семейство: при повторном использовании старого токена отзывается все семейство
+type RefreshTokenRecord struct {
+	Family        string
+	UserID        string
+	Rotated       bool // токен уже обменян на новый
+	FamilyRevoked bool // семейство отозвано
+}
+
+// RefreshTokenStore хранит хеши выданных refresh token'ов. Сами токены не сохраняются,
+// кроме последнего токена каждого семейства - он шифруется RefreshTokenSealer
+type RefreshTokenStore interface {
+	// Issue регистрирует токен нового семейства
+	Issue(ctx context.Context, token string, record RefreshTokenRecord) error
+	// Get возвращает запись токена; nil, если токен не выдавался через сервис или уже забыт
+	Get(ctx context.Context, token string) (*RefreshTokenRecord, error)
+	// Rotate атомарно помечает старый токен обмененным и регистрирует новый в том же семействе.
+	// Если старый токен уже обменян, отзывает семейство и возвращает domain.ErrRefreshTokenReused,
+	// если семейство отозвано - domain.ErrRefreshTokenRevoked
+	Rotate(ctx context.Context, oldToken, newToken string, record RefreshTokenRecord) error
+	// LiveToken возвращает последний выданный токен семейства; пустая строка, если он забыт
+	LiveToken(ctx context.Context, family string) (string, error)
+	// RevokeFamily отзывает все токены семейства
+	RevokeFamily(ctx context.Context, family string) error
+	// RevokeUser отзывает все семейства пользователя и возвращает их
+	RevokeUser(ctx context.Context, userID string) ([]string, error)
+}
+
+// NewRefreshTokenStore создает хранилище согласно REFRESH_TOKEN_STORE: memory (по умолчанию), redis.
+// Записи живут REFRESH_TOKEN_TTL (по умолчанию 2160h - максимальный срок refresh token'а в Zitadel).
+// Для redis обязателен REFRESH_TOKEN_SEAL_SECRET, общий для всех реплик
+func NewRefreshTokenStore() (RefreshTokenStore, error) {
+	ttl, err := durationFromEnv("REFRESH_TOKEN_TTL", 2160*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := os.Getenv("REFRESH_TOKEN_STORE")
+
+	switch backend {
+	case "", "memory":
+		sealer, err := newRefreshTokenSealerFromEnv(false)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using in-memory refresh token store")
+		return NewMemoryRefreshTokenStore(sealer, ttl), nil
+	case "redis":
+		sealer, err := newRefreshTokenSealerFromEnv(true)
+		if err != nil {
+			return nil, err
+		}
+		client, err := NewRedisClient()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using Redis refresh token store")
+		return NewRedisRefreshTokenStore(client, sealer, ttl), nil
+	default:
+		return nil, fmt.Errorf("unknown REFRESH_TOKEN_STORE: %s", backend)
+	}
+}
+
+// RefreshTokenRotator обменивает refresh token'ы в Zitadel и отслеживает ротацию:
+// каждый токен можно обменять один раз, повторное предъявление считается кражей.
+// Client secret есть только у сервиса, поэтому обмен возможен лишь через Refresh,
+// и учет в хранилище решает, какие токены еще действуют
+type RefreshTokenRotator struct {
+	oidc  *OIDCService
+	store RefreshTokenStore
+}
+
+func NewRefreshTokenRotator(oidc *OIDCService, store RefreshTokenStore) *RefreshTokenRotator {
+	return &RefreshTokenRotator{
+		oidc:  oidc,
+		store: store,
+	}
+}
+
+// Track начинает новое семейство для токенов, выданных при входе
+func (r *RefreshTokenRotator) Track(ctx context.Context, userID string, tokens *TokenResponse) error {
+	if tokens.RefreshToken == "" {
+		return nil
+	}
+
+	record := RefreshTokenRecord{Family: newTokenFamily(), UserID: userID}
+	if err := r.store.Issue(ctx, tokens.RefreshToken, record); err != nil {
+		return fmt.Errorf("failed to track refresh token: %w", err)
+	}
+	return nil
+}
+
+// Refresh обменивает refresh token на новые токены
+func (r *RefreshTokenRotator) Refresh(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	record, err := r.store.Get(ctx, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	if record == nil {
+		// Токен не выдавался через сервис, запись истекла или пропала вместе с хранилищем в памяти.
+		// Без записи неизвестны владелец и семейство, и отозвать его потом было бы нельзя - нужен новый вход
+		return nil, domain.ErrRefreshTokenInvalid
+	}
+	if record.FamilyRevoked {
+		return nil, domain.ErrRefreshTokenRevoked
+	}
+	if record.Rotated {
+		log.Printf("Refresh token reuse detected: family=%s, user_id=%s, revoking family", record.Family, record.UserID)
+		if err := r.revokeFamily(ctx, record.Family); err != nil {
+			log.Printf("Failed to revoke refresh token family %s: %v", record.Family, err)
+		}
+		return nil, domain.ErrRefreshTokenReused
+	}
+
+	tokens, err := r.oidc.RefreshTokens(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Без ротации в Zitadel старый токен остается действующим
+	if tokens.RefreshToken == "" || tokens.RefreshToken == refreshToken {
+		return tokens, nil
+	}
+
+	if err := r.store.Rotate(ctx, refreshToken, tokens.RefreshToken, *record); err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenReused) || errors.Is(err, domain.ErrRefreshTokenRevoked) {
+			// Токен обменян параллельно: только что выданный преемник тоже не должен действовать
+			if revokeErr := r.oidc.RevokeToken(ctx, tokens.RefreshToken, TokenTypeHintRefreshToken); revokeErr != nil {
+				log.Printf("Failed to revoke refresh token issued for family %s: %v", record.Family, revokeErr)
+			}
+			if errors.Is(err, domain.ErrRefreshTokenReused) {
+				log.Printf("Refresh token reuse detected: family=%s, user_id=%s, revoking family", record.Family, record.UserID)
+				if revokeErr := r.revokeFamily(ctx, record.Family); revokeErr != nil {
+					log.Printf("Failed to revoke refresh token family %s: %v", record.Family, revokeErr)
+				}
+			}
+			return nil, err
+		}
+		// Новые токены уже выданы Zitadel, поэтому сбой учета не должен их терять
+		log.Printf("Failed to record refresh token rotation (family=%s): %v", record.Family, err)
+	}
+
+	return tokens, nil
+}
+
+// Revoke отзывает семейство токена, чтобы ни он, ни его преемники больше не обменивались.
+// Возвращает запись токена (nil, если токен не отслеживался)
+func (r *RefreshTokenRotator) Revoke(ctx context.Context, refreshToken string) (*RefreshTokenRecord, error) {
+	record, err := r.store.Get(ctx, refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	if record == nil {
+		return nil, nil
+	}
+
+	if err := r.revokeFamily(ctx, record.Family); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// RevokeUser отзывает все отслеживаемые refresh token'ы пользователя, в том числе в Zitadel
+func (r *RefreshTokenRotator) RevokeUser(ctx context.Context, userID string) error {
+	families, err := r.store.RevokeUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user %s: %w", userID, err)
+	}
+
+	var errs []error
+	for _, family := range families {
+		if err := r.revokeLiveToken(ctx, family); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// revokeFamily отзывает семейство в хранилище и его последний токен в Zitadel
+func (r *RefreshTokenRotator) revokeFamily(ctx context.Context, family string) error {
+	if err := r.store.RevokeFamily(ctx, family); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return r.revokeLiveToken(ctx, family)
+}
+
+func (r *RefreshTokenRotator) revokeLiveToken(ctx context.Context, family string) error {
+	token, err := r.store.LiveToken(ctx, family)
+	if err != nil {
+		return fmt.Errorf("failed to load live refresh token of family %s: %w", family, err)
+	}
+	if token == "" {
+		return nil
+	}
+
+	if err := r.oidc.RevokeToken(ctx, token, TokenTypeHintRefreshToken); err != nil {
+		return fmt.Errorf("failed to revoke live refresh token of family %s: %w", family, err)
+	}
+	return nil
+}
+
+// hashToken - SHA-256 токена для ключей хранилищ
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newTokenFamily() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemoryRefreshTokenStore хранит записи в памяти процесса
+type MemoryRefreshTokenStore struct {
+	mu       sync.Mutex
+	sealer   *RefreshTokenSealer
+	ttl      time.Duration
+	tokens   map[string]*memoryRefreshToken
+	families map[string]time.Time // отозванные семейства и срок хранения отметки
+	live     map[string]memoryLiveToken
+}
+
+type memoryRefreshToken struct {
+	record    RefreshTokenRecord
+	expiresAt time.Time
+}
+
+// memoryLiveToken - зашифрованный последний токен семейства
+type memoryLiveToken struct {
+	sealed    string
+	expiresAt time.Time
+}
+
+func NewMemoryRefreshTokenStore(sealer *RefreshTokenSealer, ttl time.Duration) *MemoryRefreshTokenStore {
+	store := &MemoryRefreshTokenStore{
+		sealer:   sealer,
+		ttl:      ttl,
+		tokens:   make(map[string]*memoryRefreshToken),
+		families: make(map[string]time.Time),
+		live:     make(map[string]memoryLiveToken),
+	}
+
+	go store.cleanup()
+
+	return store
+}
+
+func (s *MemoryRefreshTokenStore) Issue(ctx context.Context, token string, record RefreshTokenRecord) error {
+	sealed, err := s.sealer.Seal(token)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(s.ttl)
+	record.Rotated = false
+	record.FamilyRevoked = false
+	s.tokens[hashToken(token)] = &memoryRefreshToken{record: record, expiresAt: expiresAt}
+	s.live[record.Family] = memoryLiveToken{sealed: sealed, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) Get(ctx context.Context, token string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tokens[hashToken(token)]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+
+	record := entry.record
+	record.FamilyRevoked = s.familyRevokedLocked(record.Family)
+	return &record, nil
+}
+
+func (s *MemoryRefreshTokenStore) Rotate(ctx context.Context, oldToken, newToken string, record RefreshTokenRecord) error {
+	sealed, err := s.sealer.Seal(newToken)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.familyRevokedLocked(record.Family) {
+		return domain.ErrRefreshTokenRevoked
+	}
+
+	if old, ok := s.tokens[hashToken(oldToken)]; ok && time.Now().Before(old.expiresAt) {
+		if old.record.Rotated {
+			s.families[record.Family] = time.Now().Add(s.ttl)
+			return domain.ErrRefreshTokenReused
+		}
+		old.record.Rotated = true
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	record.Rotated = false
+	record.FamilyRevoked = false
+	s.tokens[hashToken(newToken)] = &memoryRefreshToken{record: record, expiresAt: expiresAt}
+	s.live[record.Family] = memoryLiveToken{sealed: sealed, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) LiveToken(ctx context.Context, family string) (string, error) {
+	s.mu.Lock()
+	entry, ok := s.live[family]
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", nil
+	}
+	return s.sealer.Open(entry.sealed)
+}
+
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.families[family] = time.Now().Add(s.ttl)
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeUser(ctx context.Context, userID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(s.ttl)
+	revoked := make(map[string]struct{})
+	for _, entry := range s.tokens {
+		if entry.record.UserID == userID {
+			s.families[entry.record.Family] = expiresAt
+			revoked[entry.record.Family] = struct{}{}
+		}
+	}
+
+	families := make([]string, 0, len(revoked))
+	for family := range revoked {
+		families = append(families, family)
+	}
+	return families, nil
+}
+
+func (s *MemoryRefreshTokenStore) familyRevokedLocked(family string) bool {
+	expiresAt, ok := s.families[family]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (s *MemoryRefreshTokenStore) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for hash, entry := range s.tokens {
+			if now.After(entry.expiresAt) {
+				delete(s.tokens, hash)
+			}
+		}
+		for family, expiresAt := range s.families {
+			if now.After(expiresAt) {
+				delete(s.families, family)
+			}
+		}
+		for family, entry := range s.live {
+			if now.After(entry.expiresAt) {
+				delete(s.live, family)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sms-service/internal/domain"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// runRefreshTokenStoreContract проверяет поведение, общее для всех реализаций RefreshTokenStore.
+// newStore вызывается для каждого подтеста и должен возвращать пустое хранилище
+func runRefreshTokenStoreContract(t *testing.T, newStore func(t *testing.T) RefreshTokenStore) {
+	t.Run("issue and rotate", func(t *testing.T) {
+		ctx := context.Background()
+		store := newStore(t)
+		record := RefreshTokenRecord{Family: "family-1", UserID: "user-1"}
+
+		if err := store.Issue(ctx, "t1", record); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		expectRefreshRecord(t, store, "t1", RefreshTokenRecord{Family: "family-1", UserID: "user-1"})
+		expectLiveToken(t, store, "family-1", "t1")
+
+		if err := store.Rotate(ctx, "t1", "t2", record); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+		expectRefreshRecord(t, store, "t1", RefreshTokenRecord{Family: "family-1", UserID: "user-1", Rotated: true})
+		expectRefreshRecord(t, store, "t2", RefreshTokenRecord{Family: "family-1", UserID: "user-1"})
+		expectLiveToken(t, store, "family-1", "t2")
+
+		if got, err := store.Get(ctx, "unknown"); err != nil || got != nil {
+			t.Errorf("Get(unknown) = %+v, %v, want nil", got, err)
+		}
+	})
+
+	t.Run("reuse revokes family", func(t *testing.T) {
+		ctx := context.Background()
+		store := newStore(t)
+		record := RefreshTokenRecord{Family: "family-1", UserID: "user-1"}
+
+		if err := store.Issue(ctx, "t1", record); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		if err := store.Rotate(ctx, "t1", "t2", record); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+
+		if err := store.Rotate(ctx, "t1", "t3", record); !errors.Is(err, domain.ErrRefreshTokenReused) {
+			t.Fatalf("Rotate of rotated token: got %v, want %v", err, domain.ErrRefreshTokenReused)
+		}
+		expectRefreshRecord(t, store, "t2", RefreshTokenRecord{Family: "family-1", UserID: "user-1", FamilyRevoked: true})
+
+		// Преемник из отозванного семейства больше не обменивается
+		if err := store.Rotate(ctx, "t2", "t4", record); !errors.Is(err, domain.ErrRefreshTokenRevoked) {
+			t.Errorf("Rotate in revoked family: got %v, want %v", err, domain.ErrRefreshTokenRevoked)
+		}
+	})
+
+	t.Run("concurrent rotation", func(t *testing.T) {
+		ctx := context.Background()
+		store := newStore(t)
+		record := RefreshTokenRecord{Family: "family-1", UserID: "user-1"}
+
+		if err := store.Issue(ctx, "t1", record); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, next := range []string{"t2", "t3"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = store.Rotate(ctx, "t1", next, record)
+			}()
+		}
+		wg.Wait()
+
+		succeeded, reused := 0, 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, domain.ErrRefreshTokenReused):
+				reused++
+			default:
+				t.Errorf("Rotate: unexpected error %v", err)
+			}
+		}
+		if succeeded != 1 || reused != 1 {
+			t.Errorf("concurrent Rotate: %d succeeded, %d reused, want 1 and 1", succeeded, reused)
+		}
+	})
+
+	t.Run("revoke family", func(t *testing.T) {
+		ctx := context.Background()
+		store := newStore(t)
+
+		if err := store.Issue(ctx, "t1", RefreshTokenRecord{Family: "family-1", UserID: "user-1"}); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		if err := store.Issue(ctx, "t2", RefreshTokenRecord{Family: "family-2", UserID: "user-1"}); err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+
+		if err := store.RevokeFamily(ctx, "family-1"); err != nil {
+			t.Fatalf("RevokeFamily: %v", err)
+		}
+		expectRefreshRecord(t, store, "t1", RefreshTokenRecord{Family: "family-1", UserID: "user-1", FamilyRevoked: true})
+		expectRefreshRecord(t, store, "t2", RefreshTokenRecord{Family: "family-2", UserID: "user-1"})
+	})
+
+	t.Run("revoke user", func(t *testing.T) {
+		ctx := context.Background()
+		store := newStore(t)
+
+		issued := []struct {
+			token  string
+			record RefreshTokenRecord
+		}{
+			{"t1", RefreshTokenRecord{Family: "family-1", UserID: "user-1"}},
+			{"t2", RefreshTokenRecord{Family: "family-2", UserID: "user-1"}},
+			{"t3", RefreshTokenRecord{Family: "family-3", UserID: "user-2"}},
+		}
+		for _, tt := range issued {
+			if err := store.Issue(ctx, tt.token, tt.record); err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+		}
+		if err := store.Rotate(ctx, "t1", "t4", issued[0].record); err != nil {
+			t.Fatalf("Rotate: %v", err)
+		}
+
+		families, err := store.RevokeUser(ctx, "user-1")
+		if err != nil {
+			t.Fatalf("RevokeUser: %v", err)
+		}
+		sort.Strings(families)
+		if len(families) != 2 || families[0] != "family-1" || families[1] != "family-2" {
+			t.Errorf("RevokeUser = %v, want [family-1 family-2]", families)
+		}
+
+		expectRefreshRecord(t, store, "t4", RefreshTokenRecord{Family: "family-1", UserID: "user-1", FamilyRevoked: true})
+		expectRefreshRecord(t, store, "t2", RefreshTokenRecord{Family: "family-2", UserID: "user-1", FamilyRevoked: true})
+		expectRefreshRecord(t, store, "t3", RefreshTokenRecord{Family: "family-3", UserID: "user-2"})
+
+		if families, err := store.RevokeUser(ctx, "nobody"); err != nil || len(families) != 0 {
+			t.Errorf("RevokeUser(nobody) = %v, %v, want none", families, err)
+		}
+	})
+}
+
+func expectRefreshRecord(t *testing.T, store RefreshTokenStore, token string, want RefreshTokenRecord) {
+	t.Helper()
+
+	got, err := store.Get(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", token, err)
+	}
+	if got == nil || *got != want {
+		t.Errorf("Get(%s) = %+v, want %+v", token, got, want)
+	}
+}
+
+func expectLiveToken(t *testing.T, store RefreshTokenStore, family, want string) {
+	t.Helper()
+
+	got, err := store.LiveToken(context.Background(), family)
+	if err != nil {
+		t.Fatalf("LiveToken(%s): %v", family, err)
+	}
+	if got != want {
+		t.Errorf("LiveToken(%s) = %q, want %q", family, got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sms-service/internal/domain"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisRefreshTokenStore хранит записи в Redis, чтобы ротация учитывалась всеми репликами.
+// Ключи: refresh:<hash> (hash с полями family, user_id, rotated), refresh:family:<family> - отметка отзыва,
+// refresh:user:<userID> - множество семейств пользователя для RevokeUser,
+// refresh:live:<family> - зашифрованный последний токен семейства
+type RedisRefreshTokenStore struct {
+	client *redis.Client
+	sealer *RefreshTokenSealer
+	ttl    time.Duration
+}
+
+// rotateRefreshScript
+// KEYS[1] - старый токен, KEYS[2] - новый токен, KEYS[3] - отметка отзыва семейства, KEYS[4] - семейства пользователя,
+// KEYS[5] - последний токен семейства
+// ARGV[1] - семейство, ARGV[2] - user ID, ARGV[3] - TTL (мс), ARGV[4] - зашифрованный новый токен
+// Возвращает 'ok', 'reused' или 'revoked'
+var rotateRefreshScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[3]) == 1 then
+	return 'revoked'
+end
+local rotated = redis.call('HGET', KEYS[1], 'rotated')
+if rotated == '1' then
+	redis.call('SET', KEYS[3], '1', 'PX', ARGV[3])
+	return 'reused'
+end
+if rotated then
+	redis.call('HSET', KEYS[1], 'rotated', '1')
+end
+redis.call('HSET', KEYS[2], 'family', ARGV[1], 'user_id', ARGV[2], 'rotated', '0')
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+redis.call('SET', KEYS[5], ARGV[4], 'PX', ARGV[3])
+if ARGV[2] ~= '' then
+	redis.call('SADD', KEYS[4], ARGV[1])
+	redis.call('PEXPIRE', KEYS[4], ARGV[3])
+end
+return 'ok'
+`)
+
+func NewRedisRefreshTokenStore(client *redis.Client, sealer *RefreshTokenSealer, ttl time.Duration) *RedisRefreshTokenStore {
+	return &RedisRefreshTokenStore{
+		client: client,
+		sealer: sealer,
+		ttl:    ttl,
+	}
+}
+
+func refreshTokenKey(tokenHash string) string {
+	return "refresh:" + tokenHash
+}
+
+func refreshFamilyKey(family string) string {
+	return "refresh:family:" + family
+}
+
+func refreshUserKey(userID string) string {
+	return "refresh:user:" + userID
+}
+
+func refreshLiveKey(family string) string {
+	return "refresh:live:" + family
+}
+
+func (s *RedisRefreshTokenStore) Issue(ctx context.Context, token string, record RefreshTokenRecord) error {
+	sealed, err := s.sealer.Seal(token)
+	if err != nil {
+		return err
+	}
+
+	key := refreshTokenKey(hashToken(token))
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, "family", record.Family, "user_id", record.UserID, "rotated", "0")
+	pipe.PExpire(ctx, key, s.ttl)
+	pipe.Set(ctx, refreshLiveKey(record.Family), sealed, s.ttl)
+	if record.UserID != "" {
+		pipe.SAdd(ctx, refreshUserKey(record.UserID), record.Family)
+		pipe.PExpire(ctx, refreshUserKey(record.UserID), s.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store refresh token in redis: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisRefreshTokenStore) Get(ctx context.Context, token string) (*RefreshTokenRecord, error) {
+	fields, err := s.client.HGetAll(ctx, refreshTokenKey(hashToken(token))).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh token from redis: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	record := &RefreshTokenRecord{
+		Family:  fields["family"],
+		UserID:  fields["user_id"],
+		Rotated: fields["rotated"] == "1",
+	}
+
+	revoked, err := s.client.Exists(ctx, refreshFamilyKey(record.Family)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh token family from redis: %w", err)
+	}
+	record.FamilyRevoked = revoked == 1
+
+	return record, nil
+}
+
+func (s *RedisRefreshTokenStore) Rotate(ctx context.Context, oldToken, newToken string, record RefreshTokenRecord) error {
+	sealed, err := s.sealer.Seal(newToken)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{
+		refreshTokenKey(hashToken(oldToken)),
+		refreshTokenKey(hashToken(newToken)),
+		refreshFamilyKey(record.Family),
+		refreshUserKey(record.UserID),
+		refreshLiveKey(record.Family),
+	}
+	result, err := rotateRefreshScript.Run(ctx, s.client, keys, record.Family, record.UserID, s.ttl.Milliseconds(), sealed).Text()
+	if err != nil {
+		return fmt.Errorf("failed to rotate refresh token in redis: %w", err)
+	}
+
+	switch result {
+	case "ok":
+		return nil
+	case "reused":
+		return domain.ErrRefreshTokenReused
+	case "revoked":
+		return domain.ErrRefreshTokenRevoked
+	default:
+		return fmt.Errorf("unexpected refresh token rotate result: %s", result)
+	}
+}
+
+func (s *RedisRefreshTokenStore) LiveToken(ctx context.Context, family string) (string, error) {
+	sealed, err := s.client.Get(ctx, refreshLiveKey(family)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read live refresh token from redis: %w", err)
+	}
+	return s.sealer.Open(sealed)
+}
+
+func (s *RedisRefreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	if err := s.client.Set(ctx, refreshFamilyKey(family), "1", s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family in redis: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisRefreshTokenStore) RevokeUser(ctx context.Context, userID string) ([]string, error) {
+	families, err := s.client.SMembers(ctx, refreshUserKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh token families from redis: %w", err)
+	}
+	if len(families) == 0 {
+		return nil, nil
+	}
+
+	pipe := s.client.Pipeline()
+	for _, family := range families {
+		pipe.Set(ctx, refreshFamilyKey(family), "1", s.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh token families in redis: %w", err)
+	}
+	return families, nil
+}
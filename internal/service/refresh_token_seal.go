@@ -0,0 +1,88 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// RefreshTokenSealer шифрует последний выданный токен семейства (AES-256-GCM).
+// Он нужен, чтобы при краже цепочки или выходе отозвать в Zitadel и действующего преемника,
+// которого клиент не предъявлял
+type RefreshTokenSealer struct {
+	aead cipher.AEAD
+}
+
+// NewRefreshTokenSealer выводит ключ шифрования из секрета через SHA-256
+func NewRefreshTokenSealer(secret []byte) (*RefreshTokenSealer, error) {
+	key := sha256.Sum256(secret)
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token cipher: %w", err)
+	}
+
+	return &RefreshTokenSealer{aead: aead}, nil
+}
+
+// newRefreshTokenSealerFromEnv читает REFRESH_TOKEN_SEAL_SECRET. Для хранилища одного процесса
+// при отсутствии секрета генерируется случайный; общим хранилищам нужен
+// одинаковый секрет на всех репликах, поэтому для них он обязателен.
+func newRefreshTokenSealerFromEnv(shared bool) (*RefreshTokenSealer, error) {
+	secret := os.Getenv("REFRESH_TOKEN_SEAL_SECRET")
+	if secret != "" {
+		return NewRefreshTokenSealer([]byte(secret))
+	}
+
+	if shared {
+		return nil, fmt.Errorf("REFRESH_TOKEN_SEAL_SECRET is required for shared refresh token stores")
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token seal secret: %w", err)
+	}
+
+	log.Printf("Warning: REFRESH_TOKEN_SEAL_SECRET not set, using random secret for this process")
+	return NewRefreshTokenSealer(random)
+}
+
+// Seal возвращает base64(nonce || шифротекст)
+func (s *RefreshTokenSealer) Seal(token string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token nonce: %w", err)
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(token), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open расшифровывает результат Seal
+func (s *RefreshTokenSealer) Open(sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode sealed refresh token: %w", err)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(raw) < nonceSize {
+		return "", errors.New("sealed refresh token is too short")
+	}
+
+	token, err := s.aead.Open(nil, raw[:nonceSize], raw[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to open sealed refresh token: %w", err)
+	}
+	return string(token), nil
+}
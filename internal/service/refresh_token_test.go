@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRefreshTokenSealer(t *testing.T) *RefreshTokenSealer {
+	t.Helper()
+
+	sealer, err := NewRefreshTokenSealer([]byte("test"))
+	if err != nil {
+		t.Fatalf("NewRefreshTokenSealer: %v", err)
+	}
+	return sealer
+}
+
+func TestMemoryRefreshTokenStoreContract(t *testing.T) {
+	runRefreshTokenStoreContract(t, func(t *testing.T) RefreshTokenStore {
+		return NewMemoryRefreshTokenStore(newTestRefreshTokenSealer(t), time.Hour)
+	})
+}
+
+func TestRedisRefreshTokenStoreContract(t *testing.T) {
+	runRefreshTokenStoreContract(t, func(t *testing.T) RefreshTokenStore {
+		_, client := newTestRedis(t)
+		return NewRedisRefreshTokenStore(client, newTestRefreshTokenSealer(t), time.Hour)
+	})
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sms-service/internal/domain"
+	"sync"
+)
+
+// ResponseMutator меняет ответ перехваченного метода Zitadel до того, как он уйдет клиенту.
+// response - тело ответа в JSON-представлении protobuf; добавлять можно только поля,
+// которые есть в ответе метода, иначе Zitadel не сможет его разобрать
+type ResponseMutator func(ctx context.Context, call *domain.ZitadelWebhookRequest, response map[string]interface{}) error
+
+// ResponseMutatorRegistry хранит мутаторы по полному имени gRPC-метода
+type ResponseMutatorRegistry struct {
+	mu       sync.RWMutex
+	mutators map[string][]ResponseMutator
+}
+
+func NewResponseMutatorRegistry() *ResponseMutatorRegistry {
+	return &ResponseMutatorRegistry{
+		mutators: make(map[string][]ResponseMutator),
+	}
+}
+
+// Register добавляет мутатор для метода; мутаторы применяются в порядке регистрации
+func (r *ResponseMutatorRegistry) Register(method string, mutator ResponseMutator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mutators[method] = append(r.mutators[method], mutator)
+}
+
+// Apply применяет мутаторы метода call.FullMethod к копии call.Response и возвращает итоговый ответ.
+// call.Response не меняется: при ошибке мутатора клиент получает исходный ответ, а не частично измененный
+func (r *ResponseMutatorRegistry) Apply(ctx context.Context, call *domain.ZitadelWebhookRequest) (map[string]interface{}, error) {
+	r.mu.RLock()
+	mutators := r.mutators[call.FullMethod]
+	r.mu.RUnlock()
+
+	response := make(map[string]interface{}, len(call.Response))
+	for key, value := range call.Response {
+		response[key] = cloneJSONValue(value)
+	}
+
+	for _, mutator := range mutators {
+		if err := mutator(ctx, call, response); err != nil {
+			return nil, fmt.Errorf("response mutator for %s failed: %w", call.FullMethod, err)
+		}
+	}
+
+	return response, nil
+}
+
+// cloneJSONValue копирует значение, разобранное encoding/json, вместе с вложенными объектами и массивами
+func cloneJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneJSONValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneJSONValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sms-service/internal/domain"
+	"testing"
+)
+
+func TestApplyLeavesOriginalResponseUntouched(t *testing.T) {
+	registry := NewResponseMutatorRegistry()
+	registry.Register(domain.ZitadelMethodCreateUser, func(ctx context.Context, call *domain.ZitadelWebhookRequest, response map[string]interface{}) error {
+		response["id"] = "changed"
+		response["details"].(map[string]interface{})["sequence"] = "changed"
+		return errors.New("mutator failed halfway")
+	})
+
+	call := &domain.ZitadelWebhookRequest{
+		FullMethod: domain.ZitadelMethodCreateUser,
+		Response: map[string]interface{}{
+			"id":      "user-1",
+			"details": map[string]interface{}{"sequence": "1"},
+		},
+	}
+
+	if _, err := registry.Apply(context.Background(), call); err == nil {
+		t.Fatal("expected mutator error")
+	}
+
+	if call.Response["id"] != "user-1" {
+		t.Errorf("original id changed to %v", call.Response["id"])
+	}
+	if sequence := call.Response["details"].(map[string]interface{})["sequence"]; sequence != "1" {
+		t.Errorf("original nested field changed to %v", sequence)
+	}
+}
+
+func TestDeriveCustomerIDIsStable(t *testing.T) {
+	first, _ := deriveCustomerID(context.Background(), "user-1")
+	retry, _ := deriveCustomerID(context.Background(), "user-1")
+	other, _ := deriveCustomerID(context.Background(), "user-2")
+
+	if first != retry {
+		t.Errorf("retry produced a different ID: %s != %s", first, retry)
+	}
+	if first == other {
+		t.Errorf("different users share customer ID %s", first)
+	}
+}
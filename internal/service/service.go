@@ -2,11 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"sms-service/internal/domain"
+	phonelib "sms-service/internal/phone"
+	"strings"
 
 	"github.com/zitadel/zitadel-go/v3/pkg/client"
+	filter "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/filter/v2"
+	metadata "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/metadata/v2"
+	object "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/object/v2"
 	"github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/session/v2"
 	v2 "github.com/zitadel/zitadel-go/v3/pkg/client/zitadel/user/v2"
 	"github.com/zitadel/zitadel-go/v3/pkg/zitadel"
@@ -41,6 +49,7 @@ type IntrospectionResponse struct {
 	IssuedAt  int64  `json:"iat"`
 	ClientID  string `json:"client_id"`
 	Scope     string `json:"scope"`
+	SessionID string `json:"sid,omitempty"` // сессия Zitadel, из которой выпущен токен
 }
 
 func NewZitadelService() (*ZitadelService, error) {
@@ -97,9 +106,11 @@ func NewZitadelService() (*ZitadelService, error) {
 }
 
 // CreateUserByPhone создает пользователя в Zitadel используя только номер телефона
-func (s *ZitadelService) CreateUserByPhone(ctx context.Context, phone string) (*CreateUserResponse, error) {
-	if phone == "" {
-		return nil, fmt.Errorf("phone number is required")
+func (s *ZitadelService) CreateUserByPhone(ctx context.Context, rawPhone string) (*CreateUserResponse, error) {
+	// Username - номер в E.164, поэтому "8 912 ..." и "+7 912 ..." дают одного пользователя
+	phone, err := phonelib.Normalize(rawPhone)
+	if err != nil {
+		return nil, err
 	}
 
 	sanitizedPhone := phone
@@ -151,39 +162,158 @@ func (s *ZitadelService) CreateUserByPhone(ctx context.Context, phone string) (*
 	}, nil
 }
 
-// GetUserByPhone ищет пользователя по номеру телефона
-func (s *ZitadelService) GetUserByPhone(ctx context.Context, phone string) (string, error) {
-	username := phone
+// PhoneUser - пользователь, найденный по номеру телефона
+type PhoneUser struct {
+	UserID   string
+	Username string
+	Phone    string // номер в E.164
+}
+
+// Legacy - username в записи номера, сохраненной до нормализации
+func (u *PhoneUser) Legacy() bool {
+	return u.Username != u.Phone
+}
+
+// LookupUserByPhone ищет пользователя по номеру телефона.
+// Пользователи, созданные до нормализации, могут иметь username в исходной записи номера
+// ("89123456789", "79123456789"), поэтому поиск идет по всем записям (phone.Number.Variants).
+// Username в E.164 однозначно указывает на владельца; если его нет, а старых записей
+// несколько, возвращается domain.ErrUserAmbiguous - выбирать среди них нельзя
+func (s *ZitadelService) LookupUserByPhone(ctx context.Context, rawPhone string) (*PhoneUser, error) {
+	number, err := phonelib.ParseDefault(rawPhone)
+	if err != nil {
+		return nil, err
+	}
+	phone := number.E164()
+
+	usernames := number.Variants()
+	if raw := strings.TrimSpace(rawPhone); !slices.Contains(usernames, raw) {
+		usernames = append(usernames, raw)
+	}
+
+	queries := make([]*v2.SearchQuery, 0, len(usernames))
+	for _, username := range usernames {
+		queries = append(queries, &v2.SearchQuery{
+			Query: &v2.SearchQuery_UserNameQuery{
+				UserNameQuery: &v2.UserNameQuery{
+					UserName: username,
+				},
+			},
+		})
+	}
 
 	resp, err := s.client.UserServiceV2().ListUsers(ctx, &v2.ListUsersRequest{
 		Queries: []*v2.SearchQuery{
 			{
-				Query: &v2.SearchQuery_UserNameQuery{
-					UserNameQuery: &v2.UserNameQuery{
-						UserName: username,
-					},
+				Query: &v2.SearchQuery_OrQuery{
+					OrQuery: &v2.OrQuery{Queries: queries},
 				},
 			},
 		},
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to find user by phone: %w", err)
+		return nil, fmt.Errorf("failed to find user by phone: %w", err)
 	}
 
 	if len(resp.Result) == 0 {
-		return "", fmt.Errorf("user not found with phone: %s", phone)
+		return nil, fmt.Errorf("%w: %s", domain.ErrUserNotFound, phone)
+	}
+
+	user := resp.Result[0]
+	if len(resp.Result) > 1 {
+		exact := slices.IndexFunc(resp.Result, func(candidate *v2.User) bool { return candidate.Username == phone })
+		if exact < 0 {
+			log.Printf("Warning: %d legacy users match phone %s", len(resp.Result), phone)
+			return nil, fmt.Errorf("%w: %s", domain.ErrUserAmbiguous, phone)
+		}
+		user = resp.Result[exact]
 	}
 
-	userID := resp.Result[0].UserId
-	log.Printf("Found user by phone %s: UserID=%s", phone, userID)
-	return userID, nil
+	log.Printf("Found user by phone %s: UserID=%s", phone, user.UserId)
+	return &PhoneUser{UserID: user.UserId, Username: user.Username, Phone: phone}, nil
+}
+
+// GetUserByPhone возвращает ID пользователя с номером телефона
+func (s *ZitadelService) GetUserByPhone(ctx context.Context, rawPhone string) (string, error) {
+	user, err := s.LookupUserByPhone(ctx, rawPhone)
+	if err != nil {
+		return "", err
+	}
+	return user.UserID, nil
 }
 
 func (s *ZitadelService) FindUserByPhone(ctx context.Context, phone string) (string, error) {
 	return s.GetUserByPhone(ctx, phone)
 }
 
+// MigrateUsername переименовывает пользователя со старой записью номера в E.164.
+// Вызывается только после подтверждения владения номером (проверки OTP).
+// Ошибка не мешает входу: пользователь найден, переименование повторится при следующем входе
+func (s *ZitadelService) MigrateUsername(ctx context.Context, user *PhoneUser) {
+	if !user.Legacy() {
+		return
+	}
+
+	username := user.Phone
+	_, err := s.client.UserServiceV2().UpdateHumanUser(ctx, &v2.UpdateHumanUserRequest{
+		UserId:   user.UserID,
+		Username: &username,
+	})
+	if err != nil {
+		log.Printf("Failed to migrate username %q of user %s to %s: %v", user.Username, user.UserID, user.Phone, err)
+		return
+	}
+
+	log.Printf("Username of user %s migrated from %q to %s", user.UserID, user.Username, user.Phone)
+}
+
+// SetUserMetadata сохраняет пару ключ/значение в metadata пользователя
+func (s *ZitadelService) SetUserMetadata(ctx context.Context, userID, key, value string) error {
+	_, err := s.client.UserServiceV2().SetUserMetadata(ctx, &v2.SetUserMetadataRequest{
+		UserId: userID,
+		Metadata: []*v2.Metadata{
+			{
+				Key:   key,
+				Value: []byte(value),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set metadata %s for user %s: %w", key, userID, err)
+	}
+
+	log.Printf("Metadata %s set for user %s", key, userID)
+	return nil
+}
+
+// GetUserMetadata возвращает значение metadata пользователя; false - ключа нет
+func (s *ZitadelService) GetUserMetadata(ctx context.Context, userID, key string) (string, bool, error) {
+	resp, err := s.client.UserServiceV2().ListUserMetadata(ctx, &v2.ListUserMetadataRequest{
+		UserId: userID,
+		Filters: []*metadata.MetadataSearchFilter{
+			{
+				Filter: &metadata.MetadataSearchFilter_KeyFilter{
+					KeyFilter: &metadata.MetadataKeyFilter{
+						Key:    key,
+						Method: filter.TextFilterMethod_TEXT_FILTER_METHOD_EQUALS,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get metadata %s for user %s: %w", key, userID, err)
+	}
+
+	for _, item := range resp.Metadata {
+		if item.Key == key {
+			return string(item.Value), true, nil
+		}
+	}
+	return "", false, nil
+}
+
 func (s *ZitadelService) CreateSessionForUser(ctx context.Context, userID string) (*SessionTokenResponse, error) {
 	resp, err := s.client.SessionServiceV2().CreateSession(ctx, &session.CreateSessionRequest{
 		Checks: &session.Checks{
@@ -213,6 +343,62 @@ func (s *ZitadelService) CreateSessionForUser(ctx context.Context, userID string
 	}, nil
 }
 
+// DeleteSession завершает сессию Zitadel
+func (s *ZitadelService) DeleteSession(ctx context.Context, sessionID string) error {
+	_, err := s.client.SessionServiceV2().DeleteSession(ctx, &session.DeleteSessionRequest{
+		SessionId: sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", sessionID, err)
+	}
+
+	log.Printf("Session %s deleted", sessionID)
+	return nil
+}
+
+// DeleteUserSessions завершает все сессии пользователя, возвращает число завершенных
+func (s *ZitadelService) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	const pageSize = 100
+
+	// Сначала собираем все ID: удаление во время листинга сдвигает страницы
+	var sessionIDs []string
+	for offset := uint64(0); ; offset += pageSize {
+		resp, err := s.client.SessionServiceV2().ListSessions(ctx, &session.ListSessionsRequest{
+			Query: &object.ListQuery{Offset: offset, Limit: pageSize},
+			Queries: []*session.SearchQuery{
+				{
+					Query: &session.SearchQuery_UserIdQuery{
+						UserIdQuery: &session.UserIDQuery{Id: userID},
+					},
+				},
+			},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list sessions of user %s: %w", userID, err)
+		}
+
+		for _, sess := range resp.Sessions {
+			sessionIDs = append(sessionIDs, sess.Id)
+		}
+		if len(resp.Sessions) < pageSize {
+			break
+		}
+	}
+
+	var errs []error
+	deleted := 0
+	for _, sessionID := range sessionIDs {
+		if err := s.DeleteSession(ctx, sessionID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted++
+	}
+
+	log.Printf("Deleted %d of %d sessions of user %s", deleted, len(sessionIDs), userID)
+	return deleted, errors.Join(errs...)
+}
+
 // ValidateSessionToken валидирует session token через Session Service API
 // Возвращает информацию о сессии и пользователе
 func (s *ZitadelService) ValidateSessionToken(ctx context.Context, sessionID string) (*session.GetSessionResponse, error) {
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// Команды SMPP 3.4, используемые транспортом
+const (
+	smppGenericNack         uint32 = 0x80000000
+	smppBindTransceiver     uint32 = 0x00000009
+	smppBindTransceiverResp uint32 = 0x80000009
+	smppSubmitSM            uint32 = 0x00000004
+	smppSubmitSMResp        uint32 = 0x80000004
+	smppDeliverSM           uint32 = 0x00000005
+	smppDeliverSMResp       uint32 = 0x80000005
+	smppUnbind              uint32 = 0x00000006
+	smppUnbindResp          uint32 = 0x80000006
+	smppEnquireLink         uint32 = 0x00000015
+	smppEnquireLinkResp     uint32 = 0x80000015
+
+	smppResponseMask uint32 = 0x80000000
+)
+
+// Значения command_status
+const (
+	smppStatusOK               uint32 = 0x00000000
+	smppStatusInvalidCommandID uint32 = 0x00000003 // ESME_RINVCMDID
+	smppStatusThrottled        uint32 = 0x00000058 // ESME_RTHROTTLED
+)
+
+// Optional параметры (TLV)
+const (
+	smppTagReceiptedMessageID uint16 = 0x001E
+	smppTagMessagePayload     uint16 = 0x0424
+	smppTagMessageState       uint16 = 0x0427
+)
+
+const (
+	smppHeaderLength  = 16
+	smppMaxPDULength  = 64 * 1024
+	smppMaxShortMsg   = 140
+	smppInterfaceV34  = 0x34
+	smppESMReceipt    = 0x04 // esm_class: SMSC Delivery Receipt
+	smppCodingDefault = 0x00
+	smppCodingUCS2    = 0x08
+)
+
+// smppPDU - SMPP пакет: заголовок и тело без разбора
+type smppPDU struct {
+	CommandID uint32
+	Status    uint32
+	Sequence  uint32
+	Body      []byte
+}
+
+func (p *smppPDU) isResponse() bool {
+	return p.CommandID&smppResponseMask != 0
+}
+
+func (p *smppPDU) marshal() []byte {
+	buf := make([]byte, smppHeaderLength+len(p.Body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(buf)))
+	binary.BigEndian.PutUint32(buf[4:8], p.CommandID)
+	binary.BigEndian.PutUint32(buf[8:12], p.Status)
+	binary.BigEndian.PutUint32(buf[12:16], p.Sequence)
+	copy(buf[smppHeaderLength:], p.Body)
+	return buf
+}
+
+func readSMPPPDU(r io.Reader) (*smppPDU, error) {
+	header := make([]byte, smppHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < smppHeaderLength || length > smppMaxPDULength {
+		return nil, fmt.Errorf("invalid SMPP PDU length: %d", length)
+	}
+
+	body := make([]byte, length-smppHeaderLength)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &smppPDU{
+		CommandID: binary.BigEndian.Uint32(header[4:8]),
+		Status:    binary.BigEndian.Uint32(header[8:12]),
+		Sequence:  binary.BigEndian.Uint32(header[12:16]),
+		Body:      body,
+	}, nil
+}
+
+// smppWriter собирает тело PDU
+type smppWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *smppWriter) cstring(s string) *smppWriter {
+	w.buf.WriteString(s)
+	w.buf.WriteByte(0)
+	return w
+}
+
+func (w *smppWriter) byte(b byte) *smppWriter {
+	w.buf.WriteByte(b)
+	return w
+}
+
+func (w *smppWriter) bytes(b []byte) *smppWriter {
+	w.buf.Write(b)
+	return w
+}
+
+func (w *smppWriter) tlv(tag uint16, value []byte) *smppWriter {
+	var head [4]byte
+	binary.BigEndian.PutUint16(head[0:2], tag)
+	binary.BigEndian.PutUint16(head[2:4], uint16(len(value)))
+	w.buf.Write(head[:])
+	w.buf.Write(value)
+	return w
+}
+
+func (w *smppWriter) body() []byte {
+	return w.buf.Bytes()
+}
+
+// smppReader разбирает тело PDU
+type smppReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *smppReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = fmt.Errorf("unterminated C-Octet string at offset %d", r.pos)
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+func (r *smppReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.err = fmt.Errorf("unexpected end of PDU at offset %d", r.pos)
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *smppReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("unexpected end of PDU at offset %d", r.pos)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// tlvs читает оставшиеся optional параметры
+func (r *smppReader) tlvs() map[uint16][]byte {
+	result := make(map[uint16][]byte)
+	for r.err == nil && len(r.data)-r.pos >= 4 {
+		tag := binary.BigEndian.Uint16(r.data[r.pos : r.pos+2])
+		length := int(binary.BigEndian.Uint16(r.data[r.pos+2 : r.pos+4]))
+		r.pos += 4
+		result[tag] = r.bytes(length)
+	}
+	return result
+}
+
+// smppShortMessage - разобранное тело submit_sm/deliver_sm
+type smppShortMessage struct {
+	SourceAddr   string
+	DestAddr     string
+	ESMClass     byte
+	DataCoding   byte
+	ShortMessage []byte
+	TLVs         map[uint16][]byte
+}
+
+func parseSMPPShortMessage(body []byte) (*smppShortMessage, error) {
+	r := &smppReader{data: body}
+	msg := &smppShortMessage{}
+
+	r.cstring() // service_type
+	r.byte()    // source_addr_ton
+	r.byte()    // source_addr_npi
+	msg.SourceAddr = r.cstring()
+	r.byte() // dest_addr_ton
+	r.byte() // dest_addr_npi
+	msg.DestAddr = r.cstring()
+	msg.ESMClass = r.byte()
+	r.byte()    // protocol_id
+	r.byte()    // priority_flag
+	r.cstring() // schedule_delivery_time
+	r.cstring() // validity_period
+	r.byte()    // registered_delivery
+	r.byte()    // replace_if_present_flag
+	msg.DataCoding = r.byte()
+	r.byte() // sm_default_msg_id
+	length := int(r.byte())
+	msg.ShortMessage = r.bytes(length)
+	msg.TLVs = r.tlvs()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return msg, nil
+}
+
+// encodeSMPPText кодирует текст: ASCII как есть, остальное в UCS2
+func encodeSMPPText(text string) ([]byte, byte) {
+	ascii := true
+	for _, ch := range text {
+		if ch > 0x7F {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return []byte(text), smppCodingDefault
+	}
+
+	units := utf16.Encode([]rune(text))
+	encoded := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.BigEndian.PutUint16(encoded[i*2:], u)
+	}
+	return encoded, smppCodingUCS2
+}
+
+// smppAddress возвращает TON/NPI и адрес без "+"
+func smppAddress(addr string) (byte, byte, string) {
+	if len(addr) > 0 && addr[0] == '+' {
+		addr = addr[1:]
+	}
+	for _, ch := range addr {
+		if ch < '0' || ch > '9' {
+			return 0x05, 0x00, addr // alphanumeric
+		}
+	}
+	return 0x01, 0x01, addr // international, ISDN
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sms-service/internal/domain"
+	"testing"
+)
+
+func TestSMPPPDURoundTrip(t *testing.T) {
+	pdu := &smppPDU{CommandID: smppSubmitSMResp, Status: smppStatusThrottled, Sequence: 42, Body: []byte("msg-1\x00")}
+
+	raw := pdu.marshal()
+	if length := binary.BigEndian.Uint32(raw[0:4]); int(length) != len(raw) {
+		t.Fatalf("command_length = %d, want %d", length, len(raw))
+	}
+
+	decoded, err := readSMPPPDU(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("readSMPPPDU: %v", err)
+	}
+	if decoded.CommandID != pdu.CommandID || decoded.Status != pdu.Status || decoded.Sequence != pdu.Sequence {
+		t.Errorf("header = %+v, want %+v", decoded, pdu)
+	}
+	if !bytes.Equal(decoded.Body, pdu.Body) {
+		t.Errorf("body = %q, want %q", decoded.Body, pdu.Body)
+	}
+	if !decoded.isResponse() {
+		t.Error("submit_sm_resp is not recognized as a response")
+	}
+}
+
+func TestReadSMPPPDURejectsInvalidLength(t *testing.T) {
+	for _, length := range []uint32{0, smppHeaderLength - 1, smppMaxPDULength + 1} {
+		header := make([]byte, smppHeaderLength)
+		binary.BigEndian.PutUint32(header[0:4], length)
+
+		if _, err := readSMPPPDU(bytes.NewReader(header)); err == nil {
+			t.Errorf("length %d: expected error", length)
+		}
+	}
+}
+
+// deliverSMBody собирает тело deliver_sm с отчетом о доставке
+func deliverSMBody(esmClass byte, text string, tlvs map[uint16][]byte) []byte {
+	w := (&smppWriter{}).
+		cstring("").
+		byte(1).byte(1).cstring("79991234567").
+		byte(5).byte(0).cstring("Service").
+		byte(esmClass).
+		byte(0).byte(0).
+		cstring("").cstring("").
+		byte(0).byte(0).
+		byte(smppCodingDefault).
+		byte(0).
+		byte(byte(len(text))).bytes([]byte(text))
+	for tag, value := range tlvs {
+		w.tlv(tag, value)
+	}
+	return w.body()
+}
+
+func TestParseSMPPShortMessage(t *testing.T) {
+	body := deliverSMBody(smppESMReceipt, "id:abc stat:DELIVRD", map[uint16][]byte{
+		smppTagMessageState: {2},
+	})
+
+	msg, err := parseSMPPShortMessage(body)
+	if err != nil {
+		t.Fatalf("parseSMPPShortMessage: %v", err)
+	}
+	if msg.SourceAddr != "79991234567" || msg.DestAddr != "Service" {
+		t.Errorf("addresses = %q -> %q", msg.SourceAddr, msg.DestAddr)
+	}
+	if msg.ESMClass != smppESMReceipt {
+		t.Errorf("esm_class = 0x%02X, want 0x%02X", msg.ESMClass, smppESMReceipt)
+	}
+	if string(msg.ShortMessage) != "id:abc stat:DELIVRD" {
+		t.Errorf("short_message = %q", msg.ShortMessage)
+	}
+	if state := msg.TLVs[smppTagMessageState]; len(state) != 1 || state[0] != 2 {
+		t.Errorf("message_state TLV = %v", state)
+	}
+}
+
+func TestParseSMPPShortMessageTruncated(t *testing.T) {
+	body := deliverSMBody(smppESMReceipt, "id:abc stat:DELIVRD", nil)
+
+	if _, err := parseSMPPShortMessage(body[:len(body)-5]); err == nil {
+		t.Error("expected error for truncated short_message")
+	}
+}
+
+func TestParseSMPPReceipt(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		tlvs      map[uint16][]byte
+		messageID string
+		status    domain.DeliveryStatus
+	}{
+		{
+			name:      "text",
+			text:      "id:abc sub:001 dlvrd:001 submit date:2401011200 done date:2401011201 stat:DELIVRD err:000",
+			messageID: "abc",
+			status:    domain.DeliveryStatusDelivered,
+		},
+		{
+			name:      "tlv overrides text",
+			text:      "id:abc stat:DELIVRD",
+			tlvs:      map[uint16][]byte{smppTagReceiptedMessageID: []byte("def\x00"), smppTagMessageState: {5}},
+			messageID: "def",
+			status:    domain.DeliveryStatusFailed,
+		},
+		{
+			name:      "unknown stat",
+			text:      "id:abc stat:WHATEVER",
+			messageID: "abc",
+			status:    domain.DeliveryStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := parseSMPPShortMessage(deliverSMBody(smppESMReceipt, tt.text, tt.tlvs))
+			if err != nil {
+				t.Fatalf("parseSMPPShortMessage: %v", err)
+			}
+
+			receipt := parseSMPPReceipt(msg)
+			if receipt.MessageID != tt.messageID {
+				t.Errorf("message ID = %q, want %q", receipt.MessageID, tt.messageID)
+			}
+			if receipt.Status != tt.status {
+				t.Errorf("status = %s, want %s", receipt.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestEncodeSMPPText(t *testing.T) {
+	encoded, coding := encodeSMPPText("Code 1234")
+	if coding != smppCodingDefault || string(encoded) != "Code 1234" {
+		t.Errorf("ascii: coding=0x%02X encoded=%q", coding, encoded)
+	}
+
+	encoded, coding = encodeSMPPText("Код")
+	want := []byte{0x04, 0x1A, 0x04, 0x3E, 0x04, 0x34}
+	if coding != smppCodingUCS2 || !bytes.Equal(encoded, want) {
+		t.Errorf("ucs2: coding=0x%02X encoded=% X, want % X", coding, encoded, want)
+	}
+}
+
+func TestSMPPAddress(t *testing.T) {
+	if ton, npi, addr := smppAddress("+79991234567"); ton != 1 || npi != 1 || addr != "79991234567" {
+		t.Errorf("international: ton=%d npi=%d addr=%q", ton, npi, addr)
+	}
+	if ton, npi, addr := smppAddress("Service"); ton != 5 || npi != 0 || addr != "Service" {
+		t.Errorf("alphanumeric: ton=%d npi=%d addr=%q", ton, npi, addr)
+	}
+}
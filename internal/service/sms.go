@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sms-service/internal/domain"
+	"strings"
+	"time"
+)
+
+// SMSSender - абстракция отправки SMS через провайдера
+type SMSSender interface {
+	// Send отправляет текст на номер телефона и возвращает идентификатор сообщения у провайдера
+	Send(ctx context.Context, phone, text string) (*SMSResult, error)
+}
+
+// SMSResult - результат отправки SMS
+type SMSResult struct {
+	MessageID string `json:"message_id"`
+	Provider  string `json:"provider,omitempty"` // имя провайдера, выставляется роутером
+}
+
+// DeliveryReceipt - отчет о доставке (DLR) от провайдера
+type DeliveryReceipt struct {
+	Provider   string // имя провайдера в роутере: message ID уникален только в его пределах
+	MessageID  string
+	Status     domain.DeliveryStatus
+	Stat       string // исходный статус провайдера (DELIVRD, UNDELIV, ...)
+	ErrorCode  string
+	ReceivedAt time.Time
+}
+
+// ReceiptSource - отправитель, сам получающий DLR (например, SMPP deliver_sm)
+type ReceiptSource interface {
+	SetReceiptHandler(handler func(DeliveryReceipt))
+}
+
+// DeliveryTracker - получатель событий доставки (хранилище OTP)
+// Состояние повторной отправки хранится в нем же, поэтому DLR может принять любая реплика
+type DeliveryTracker interface {
+	ApplyReceipt(receipt DeliveryReceipt)
+	// MessageOTP возвращает код, к которому привязано сообщение; nil - код уже заменен, проверен или истек
+	MessageOTP(ctx context.Context, provider, messageID string) (*OTPBinding, error)
+	// ReissueOTP заменяет код, привязанный к сообщению, новым с тем же nonce перед повторной отправкой;
+	// false - сообщение уже не привязано к коду
+	ReissueOTP(ctx context.Context, provider, messageID, code string) (bool, error)
+	// Reroute перепривязывает OTP к сообщению, повторно отправленному через другого провайдера
+	Reroute(oldProvider, oldMessageID string, sms *SMSResult)
+}
+
+// messageRef - ключ сообщения в индексах: провайдер и его message ID
+func messageRef(provider, messageID string) string {
+	return provider + "/" + messageID
+}
+
+// newSMSProvider создает провайдера указанного типа
+// Поддерживаемые типы: console, http, smpp
+func newSMSProvider(name, kind string) (SMSSender, error) {
+	switch kind {
+	case "console":
+		return NewConsoleSMSSender(), nil
+	case "http":
+		return NewHTTPSMSSender(name)
+	case "smpp":
+		return NewSMPPSender(name)
+	default:
+		return nil, fmt.Errorf("unknown SMS provider type: %s", kind)
+	}
+}
+
+// providerEnvKey возвращает имя переменной окружения для экземпляра провайдера:
+// SMS_<NAME>_<KEY>, если она задана, иначе общую <KEY>
+func providerEnvKey(name, key string) string {
+	if name != "" {
+		specific := "SMS_" + strings.ToUpper(name) + "_" + key
+		if _, ok := os.LookupEnv(specific); ok {
+			return specific
+		}
+	}
+	return key
+}
+
+// durationFromEnv читает длительность из переменной окружения
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return value, nil
+}
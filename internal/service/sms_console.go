@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ConsoleSMSSender - отправитель для разработки, пишет SMS в лог вместо отправки.
+// Цифры текста маскируются, чтобы коды не попадали в логи; для получения кода
+// при разработке есть OTP_RETURN_CODE=true
+type ConsoleSMSSender struct{}
+
+func NewConsoleSMSSender() *ConsoleSMSSender {
+	log.Printf("Warning: using console SMS sender, messages are written to log and not delivered")
+	return &ConsoleSMSSender{}
+}
+
+func (s *ConsoleSMSSender) Send(ctx context.Context, phone, text string) (*SMSResult, error) {
+	messageID := fmt.Sprintf("console-%d", time.Now().UnixNano())
+
+	log.Printf("[SMS] to=%s id=%s text=%q", phone, messageID, maskDigits(text))
+
+	return &SMSResult{MessageID: messageID}, nil
+}
+
+// maskDigits заменяет цифры на "*"
+func maskDigits(text string) string {
+	masked := []rune(text)
+	for i, r := range masked {
+		if r >= '0' && r <= '9' {
+			masked[i] = '*'
+		}
+	}
+	return string(masked)
+}
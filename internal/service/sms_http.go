@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HTTPSMSSender отправляет SMS через HTTP API провайдера
+//
+// Запрос: POST SMS_HTTP_URL {"to": "+79991234567", "from": "...", "text": "..."}
+// с заголовком Authorization: Bearer SMS_HTTP_API_KEY.
+// Ответ: 2xx и JSON с идентификатором сообщения в поле "message_id" или "id".
+type HTTPSMSSender struct {
+	url        string
+	apiKey     string
+	senderName string
+	httpClient *http.Client
+}
+
+type httpSMSRequest struct {
+	To   string `json:"to"`
+	From string `json:"from,omitempty"`
+	Text string `json:"text"`
+}
+
+type httpSMSResponse struct {
+	MessageID string `json:"message_id"`
+	ID        string `json:"id"`
+}
+
+func NewHTTPSMSSender(name string) (*HTTPSMSSender, error) {
+	urlKey := providerEnvKey(name, "SMS_HTTP_URL")
+	url := os.Getenv(urlKey)
+	if url == "" {
+		return nil, fmt.Errorf("%s is required for http SMS provider", urlKey)
+	}
+
+	timeout, err := durationFromEnv(providerEnvKey(name, "SMS_HTTP_TIMEOUT"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Using HTTP SMS sender %q: %s", name, url)
+
+	return &HTTPSMSSender{
+		url:        url,
+		apiKey:     os.Getenv(providerEnvKey(name, "SMS_HTTP_API_KEY")),
+		senderName: os.Getenv(providerEnvKey(name, "SMS_SENDER_NAME")),
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *HTTPSMSSender) Send(ctx context.Context, phone, text string) (*SMSResult, error) {
+	payload, err := json.Marshal(httpSMSRequest{
+		To:   phone,
+		From: s.senderName,
+		Text: text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SMS request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SMS request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("SMS provider error: status=%d, body=%s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("SMS provider returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var smsResp httpSMSResponse
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &smsResp); err != nil {
+			return nil, fmt.Errorf("failed to parse SMS provider response: %w", err)
+		}
+	}
+
+	messageID := smsResp.MessageID
+	if messageID == "" {
+		messageID = smsResp.ID
+	}
+
+	log.Printf("SMS sent to %s via HTTP provider: message_id=%s", phone, messageID)
+
+	return &SMSResult{MessageID: messageID}, nil
+}
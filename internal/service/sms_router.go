@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sms-service/internal/domain"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// failoverTimeout - таймаут повторной отправки после неуспешного DLR
+const failoverTimeout = 30 * time.Second
+
+// SMSRoute - правило маршрутизации: номера с префиксом Prefix отправляются
+// через Providers в порядке приоритета
+type SMSRoute struct {
+	Prefix    string
+	Providers []string
+}
+
+// SMSRouter выбирает провайдера по префиксу номера, переключается на следующего
+// при ошибке отправки или неуспешном DLR и запоминает, кто отправил сообщение
+//
+// Роутер не хранит тексты отправленных SMS. Повторная отправка по неуспешному DLR находит код
+// по message ID в хранилище OTP (DeliveryTracker), выпускает вместо него новый с тем же nonce
+// и собирает текст заново, поэтому DLR может принять любая реплика с общим хранилищем.
+//
+// Конфигурация:
+//
+//	SMS_PROVIDERS="mts:smpp,twilio:http"   - провайдеры по умолчанию в порядке приоритета (имя:тип)
+//	SMS_ROUTES="+7=mts,twilio;+375=twilio" - маршруты по префиксу номера
+//	SMS_DLR_FAILOVER=false                 - отключить повторную отправку по неуспешному DLR
+//
+// Если SMS_PROVIDERS не задан, используется единственный провайдер SMS_PROVIDER. Значения по умолчанию нет:
+// console не отправляет SMS, и он не должен включаться в production из-за забытой переменной.
+type SMSRouter struct {
+	providers     map[string]SMSSender
+	defaultOrder  []string
+	routes        []SMSRoute // отсортированы от длинного префикса к короткому
+	failoverOnDLR bool
+
+	mu      sync.Mutex
+	tracker DeliveryTracker
+}
+
+func NewSMSRouter() (*SMSRouter, error) {
+	specs := splitList(os.Getenv("SMS_PROVIDERS"), ",")
+	if len(specs) == 0 {
+		provider := os.Getenv("SMS_PROVIDER")
+		if provider == "" {
+			return nil, fmt.Errorf("SMS_PROVIDER or SMS_PROVIDERS is required (SMS_PROVIDER=console for development)")
+		}
+		specs = []string{provider}
+	}
+
+	router := &SMSRouter{
+		providers:     make(map[string]SMSSender),
+		failoverOnDLR: os.Getenv("SMS_DLR_FAILOVER") != "false",
+	}
+
+	for _, spec := range specs {
+		name, kind, found := strings.Cut(spec, ":")
+		if !found {
+			kind = name
+		}
+		if _, exists := router.providers[name]; exists {
+			return nil, fmt.Errorf("duplicate SMS provider name: %s", name)
+		}
+
+		provider, err := newSMSProvider(name, kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize SMS provider %s: %w", name, err)
+		}
+		if source, ok := provider.(ReceiptSource); ok {
+			source.SetReceiptHandler(func(receipt DeliveryReceipt) {
+				receipt.Provider = name
+				router.HandleReceipt(receipt)
+			})
+		}
+
+		router.providers[name] = provider
+		router.defaultOrder = append(router.defaultOrder, name)
+	}
+
+	routes, err := parseSMSRoutes(os.Getenv("SMS_ROUTES"))
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		for _, name := range route.Providers {
+			if _, ok := router.providers[name]; !ok {
+				return nil, fmt.Errorf("SMS route %s references unknown provider %s", route.Prefix, name)
+			}
+		}
+	}
+	router.routes = routes
+
+	log.Printf("SMS router initialized: providers=%v, routes=%d, dlr_failover=%v",
+		router.defaultOrder, len(router.routes), router.failoverOnDLR)
+
+	return router, nil
+}
+
+// HasProvider - провайдер с таким именем настроен
+func (r *SMSRouter) HasProvider(name string) bool {
+	_, ok := r.providers[name]
+	return ok
+}
+
+// SetTracker задает получателя статусов доставки
+func (r *SMSRouter) SetTracker(tracker DeliveryTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tracker = tracker
+}
+
+func (r *SMSRouter) Send(ctx context.Context, phone, text string) (*SMSResult, error) {
+	return r.sendVia(ctx, phone, text, r.providersFor(phone))
+}
+
+// providersFor возвращает провайдеров для номера по самому длинному совпавшему префиксу
+func (r *SMSRouter) providersFor(phone string) []string {
+	for _, route := range r.routes {
+		if strings.HasPrefix(phone, route.Prefix) {
+			return route.Providers
+		}
+	}
+	return r.defaultOrder
+}
+
+func (r *SMSRouter) sendVia(ctx context.Context, phone, text string, names []string) (*SMSResult, error) {
+	var lastErr error
+
+	for _, name := range names {
+		result, err := r.providers[name].Send(ctx, phone, text)
+		if err != nil {
+			log.Printf("SMS provider %s failed for %s: %v", name, phone, err)
+			lastErr = err
+			continue
+		}
+
+		result.Provider = name
+		return result, nil
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("no SMS providers configured for %s", phone)
+	}
+	return nil, fmt.Errorf("all SMS providers failed: %w", lastErr)
+}
+
+// HandleReceipt принимает DLR от провайдера receipt.Provider; при неуспешной доставке
+// отправляет новый код через следующего провайдера маршрута
+func (r *SMSRouter) HandleReceipt(receipt DeliveryReceipt) {
+	r.mu.Lock()
+	tracker := r.tracker
+	r.mu.Unlock()
+
+	if tracker == nil {
+		return
+	}
+
+	if r.failoverOnDLR && receipt.Status == domain.DeliveryStatusFailed {
+		go r.failover(receipt, tracker)
+		return
+	}
+
+	tracker.ApplyReceipt(receipt)
+}
+
+// failover повторно отправляет код, к которому привязано недоставленное сообщение.
+// Если сообщение уже не привязано к коду или провайдеров не осталось, применяется сам DLR
+func (r *SMSRouter) failover(receipt DeliveryReceipt, tracker DeliveryTracker) {
+	ctx, cancel := context.WithTimeout(context.Background(), failoverTimeout)
+	defer cancel()
+
+	binding, err := tracker.MessageOTP(ctx, receipt.Provider, receipt.MessageID)
+	if err != nil {
+		log.Printf("SMS failover for %s: %v", messageRef(receipt.Provider, receipt.MessageID), err)
+	}
+	if binding == nil {
+		tracker.ApplyReceipt(receipt)
+		return
+	}
+
+	remaining := r.providersAfter(binding.Key.Phone, receipt.Provider)
+	if len(remaining) == 0 {
+		tracker.ApplyReceipt(receipt)
+		return
+	}
+
+	log.Printf("SMS %s via %s to %s not delivered (stat=%s), failing over to %v",
+		receipt.MessageID, receipt.Provider, binding.Key.Phone, receipt.Stat, remaining)
+
+	// Текст не хранится, поэтому отправляется новый код; старый недоставленный перестает действовать
+	code := NewOTPCode()
+	reissued, err := tracker.ReissueOTP(ctx, receipt.Provider, receipt.MessageID, code)
+	if err != nil {
+		log.Printf("SMS failover for %s failed: %v", binding.Key.Phone, err)
+	}
+	if !reissued {
+		tracker.ApplyReceipt(receipt)
+		return
+	}
+
+	result, err := r.sendVia(ctx, binding.Key.Phone, OTPMessageText(binding.Key.Purpose, binding.Locale, code), remaining)
+	if err != nil {
+		log.Printf("SMS failover for %s failed: %v", binding.Key.Phone, err)
+		tracker.ApplyReceipt(receipt)
+		return
+	}
+
+	tracker.Reroute(receipt.Provider, receipt.MessageID, result)
+}
+
+// providersAfter возвращает провайдеров маршрута номера, следующих за provider
+func (r *SMSRouter) providersAfter(phone, provider string) []string {
+	names := r.providersFor(phone)
+	for i, name := range names {
+		if name == provider {
+			return names[i+1:]
+		}
+	}
+	return nil
+}
+
+// parseSMSRoutes разбирает "+7=mts,twilio;+375=twilio"
+func parseSMSRoutes(raw string) ([]SMSRoute, error) {
+	var routes []SMSRoute
+
+	for _, entry := range splitList(raw, ";") {
+		prefix, providers, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid SMS_ROUTES entry %q, expected prefix=provider1,provider2", entry)
+		}
+
+		route := SMSRoute{
+			Prefix:    strings.TrimSpace(prefix),
+			Providers: splitList(providers, ","),
+		}
+		if len(route.Providers) == 0 {
+			return nil, fmt.Errorf("SMS route %s has no providers", route.Prefix)
+		}
+		routes = append(routes, route)
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].Prefix) > len(routes[j].Prefix)
+	})
+
+	return routes, nil
+}
+
+// splitList разбивает строку по разделителю, отбрасывая пустые элементы
+func splitList(raw, sep string) []string {
+	var result []string
+	for _, item := range strings.Split(raw, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sms-service/internal/domain"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMSProvider запоминает отправленные тексты и выдает message ID по порядку
+type fakeSMSProvider struct {
+	name string
+	mu   sync.Mutex
+	sent []string
+	err  error
+}
+
+func (p *fakeSMSProvider) Send(ctx context.Context, phone, text string) (*SMSResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.sent = append(p.sent, text)
+	return &SMSResult{MessageID: fmt.Sprintf("%s-%d", p.name, len(p.sent))}, nil
+}
+
+func (p *fakeSMSProvider) texts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.sent...)
+}
+
+func newTestSMSRouter(tracker DeliveryTracker, providers ...*fakeSMSProvider) *SMSRouter {
+	router := &SMSRouter{providers: make(map[string]SMSSender), failoverOnDLR: true, tracker: tracker}
+	for _, provider := range providers {
+		router.providers[provider.name] = provider
+		router.defaultOrder = append(router.defaultOrder, provider.name)
+	}
+	return router
+}
+
+func TestSMSRouterFailoverReissuesCode(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryOTPStore(NewOTPHasher([]byte("test")))
+	primary, backup := &fakeSMSProvider{name: "primary"}, &fakeSMSProvider{name: "backup"}
+	router := newTestSMSRouter(store, primary, backup)
+
+	key := OTPKey{Purpose: domain.OTPPurposeLogin, Phone: "+79991234567"}
+	code := NewOTPCode()
+	sms, err := router.Send(ctx, key.Phone, OTPMessageText(key.Purpose, "en", code))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := store.SaveOTP(ctx, OTPIssue{Key: key, Nonce: "nonce", Code: code, Locale: "en", SMS: sms}); err != nil {
+		t.Fatalf("SaveOTP: %v", err)
+	}
+
+	router.HandleReceipt(DeliveryReceipt{Provider: sms.Provider, MessageID: sms.MessageID, Status: domain.DeliveryStatusFailed})
+
+	var texts []string
+	for deadline := time.Now().Add(time.Second); len(texts) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		texts = backup.texts()
+	}
+	if len(texts) != 1 {
+		t.Fatalf("backup sent %d messages, want 1", len(texts))
+	}
+
+	var reissued string
+	if _, err := fmt.Sscanf(texts[0], "Your verification code: %s", &reissued); err != nil {
+		t.Fatalf("unexpected failover text %q: %v", texts[0], err)
+	}
+	if reissued == code {
+		t.Error("failover resent the original code")
+	}
+
+	expectDeliveryStatus(t, store, key, "nonce", "backup", domain.DeliveryStatusSent)
+	if err := store.VerifyOTP(ctx, key, reissued, "nonce"); err != nil {
+		t.Errorf("VerifyOTP reissued code: %v", err)
+	}
+}
+
+func TestSMSRouterFailedReceiptWithoutFallback(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryOTPStore(NewOTPHasher([]byte("test")))
+	primary := &fakeSMSProvider{name: "primary"}
+	router := newTestSMSRouter(store, primary)
+
+	key := OTPKey{Purpose: domain.OTPPurposeLogin, Phone: "+79991234567"}
+	code := NewOTPCode()
+	if err := store.SaveOTP(ctx, OTPIssue{Key: key, Nonce: "nonce", Code: code, SMS: &SMSResult{Provider: "primary", MessageID: "m1"}}); err != nil {
+		t.Fatalf("SaveOTP: %v", err)
+	}
+
+	router.failover(DeliveryReceipt{Provider: "primary", MessageID: "m1", Status: domain.DeliveryStatusFailed}, store)
+
+	expectDeliveryStatus(t, store, key, "nonce", "primary", domain.DeliveryStatusFailed)
+	if err := store.VerifyOTP(ctx, key, code, "nonce"); err != nil {
+		t.Errorf("VerifyOTP original code: %v", err)
+	}
+}
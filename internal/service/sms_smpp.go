@@ -0,0 +1,432 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sms-service/internal/domain"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errSMPPClosed = errors.New("SMPP connection closed")
+
+// smppReceiptQueueSize - отчеты о доставке, ждущие обработчика. Обработчик ходит в хранилище OTP,
+// поэтому вызывается из отдельной горутины, а не из чтения PDU, где он задерживал бы ответы SMSC
+const smppReceiptQueueSize = 1024
+
+// SMPPSender - SMPP 3.4 transceiver: держит bind, шлет enquire_link,
+// отправляет OTP через submit_sm и принимает отчеты о доставке (deliver_sm)
+type SMPPSender struct {
+	addr             string
+	systemID         string
+	password         string
+	systemType       string
+	sourceAddr       string
+	enquireInterval  time.Duration
+	responseTimeout  time.Duration
+	reconnectBackoff time.Duration
+
+	mu        sync.RWMutex
+	conn      *smppConn // nil, пока сессия не в состоянии bound
+	onReceipt func(DeliveryReceipt)
+
+	receipts chan DeliveryReceipt
+}
+
+func NewSMPPSender(name string) (*SMPPSender, error) {
+	addrKey := providerEnvKey(name, "SMPP_ADDR")
+	addr := os.Getenv(addrKey)
+	if addr == "" {
+		return nil, fmt.Errorf("%s is required for smpp SMS provider", addrKey)
+	}
+
+	systemIDKey := providerEnvKey(name, "SMPP_SYSTEM_ID")
+	systemID := os.Getenv(systemIDKey)
+	if systemID == "" {
+		return nil, fmt.Errorf("%s is required for smpp SMS provider", systemIDKey)
+	}
+
+	enquireInterval, err := durationFromEnv(providerEnvKey(name, "SMPP_ENQUIRE_LINK_INTERVAL"), 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	responseTimeout, err := durationFromEnv(providerEnvKey(name, "SMPP_RESPONSE_TIMEOUT"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := &SMPPSender{
+		addr:             addr,
+		systemID:         systemID,
+		password:         os.Getenv(providerEnvKey(name, "SMPP_PASSWORD")),
+		systemType:       os.Getenv(providerEnvKey(name, "SMPP_SYSTEM_TYPE")),
+		sourceAddr:       os.Getenv(providerEnvKey(name, "SMS_SENDER_NAME")),
+		enquireInterval:  enquireInterval,
+		responseTimeout:  responseTimeout,
+		reconnectBackoff: time.Second,
+		receipts:         make(chan DeliveryReceipt, smppReceiptQueueSize),
+	}
+
+	go sender.run()
+	go sender.deliverReceipts()
+
+	log.Printf("Using SMPP SMS sender %q: %s (system_id=%s)", name, addr, systemID)
+
+	return sender, nil
+}
+
+// SetReceiptHandler задает обработчик отчетов о доставке
+func (s *SMPPSender) SetReceiptHandler(handler func(DeliveryReceipt)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onReceipt = handler
+}
+
+func (s *SMPPSender) Send(ctx context.Context, phone, text string) (*SMSResult, error) {
+	s.mu.RLock()
+	conn := s.conn
+	s.mu.RUnlock()
+
+	if conn == nil {
+		return nil, fmt.Errorf("SMPP session is not bound")
+	}
+
+	srcTON, srcNPI, source := smppAddress(s.sourceAddr)
+	dstTON, dstNPI, dest := smppAddress(phone)
+	message, coding := encodeSMPPText(text)
+
+	w := &smppWriter{}
+	w.cstring("").
+		byte(srcTON).byte(srcNPI).cstring(source).
+		byte(dstTON).byte(dstNPI).cstring(dest).
+		byte(0).      // esm_class
+		byte(0).      // protocol_id
+		byte(0).      // priority_flag
+		cstring("").  // schedule_delivery_time
+		cstring("").  // validity_period
+		byte(1).      // registered_delivery: запрашиваем DLR
+		byte(0).      // replace_if_present_flag
+		byte(coding). // data_coding
+		byte(0)       // sm_default_msg_id
+	if len(message) <= smppMaxShortMsg {
+		w.byte(byte(len(message))).bytes(message)
+	} else {
+		w.byte(0).tlv(smppTagMessagePayload, message)
+	}
+
+	resp, err := conn.request(ctx, smppSubmitSM, w.body(), s.responseTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit SMS: %w", err)
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("submit_sm rejected by SMSC: command_status=0x%08X", resp.Status)
+	}
+
+	r := &smppReader{data: resp.Body}
+	messageID := r.cstring()
+
+	log.Printf("SMS sent to %s via SMPP: message_id=%s", phone, messageID)
+
+	return &SMSResult{MessageID: messageID}, nil
+}
+
+// run поддерживает сессию, переподключаясь с экспоненциальной задержкой
+func (s *SMPPSender) run() {
+	backoff := s.reconnectBackoff
+
+	for {
+		bound, err := s.session()
+		if bound {
+			backoff = s.reconnectBackoff
+		}
+
+		log.Printf("SMPP session to %s ended: %v, reconnecting in %s", s.addr, err, backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// session открывает соединение, выполняет bind и держит его до разрыва
+func (s *SMPPSender) session() (bool, error) {
+	netConn, err := net.DialTimeout("tcp", s.addr, s.responseTimeout)
+	if err != nil {
+		return false, fmt.Errorf("failed to dial SMSC: %w", err)
+	}
+
+	conn := newSMPPConn(netConn, s.handleInbound)
+	defer conn.close(errSMPPClosed)
+
+	bind := (&smppWriter{}).
+		cstring(s.systemID).
+		cstring(s.password).
+		cstring(s.systemType).
+		byte(smppInterfaceV34).
+		byte(0). // addr_ton
+		byte(0). // addr_npi
+		cstring("").
+		body()
+
+	resp, err := conn.request(context.Background(), smppBindTransceiver, bind, s.responseTimeout)
+	if err != nil {
+		return false, fmt.Errorf("bind_transceiver failed: %w", err)
+	}
+	if resp.Status != 0 {
+		return false, fmt.Errorf("bind_transceiver rejected: command_status=0x%08X", resp.Status)
+	}
+
+	log.Printf("SMPP transceiver bound to %s", s.addr)
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(s.enquireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.done:
+			return true, conn.err
+		case <-ticker.C:
+			resp, err := conn.request(context.Background(), smppEnquireLink, nil, s.responseTimeout)
+			if err != nil {
+				return true, fmt.Errorf("enquire_link failed: %w", err)
+			}
+			if resp.Status != 0 {
+				return true, fmt.Errorf("enquire_link rejected: command_status=0x%08X", resp.Status)
+			}
+		}
+	}
+}
+
+// handleInbound обрабатывает запросы от SMSC. Вызывается из чтения PDU, поэтому не делает I/O
+// кроме ответа SMSC
+func (s *SMPPSender) handleInbound(conn *smppConn, pdu *smppPDU) {
+	switch pdu.CommandID {
+	case smppEnquireLink:
+		conn.respond(pdu, smppEnquireLinkResp, smppStatusOK, nil)
+
+	case smppDeliverSM:
+		conn.respond(pdu, smppDeliverSMResp, s.handleDeliverSM(pdu), []byte{0})
+
+	case smppUnbind:
+		conn.respond(pdu, smppUnbindResp, smppStatusOK, nil)
+		conn.close(fmt.Errorf("unbind requested by SMSC"))
+
+	default:
+		log.Printf("SMPP unsupported command 0x%08X from SMSC", pdu.CommandID)
+		conn.respond(pdu, smppGenericNack, smppStatusInvalidCommandID, nil)
+	}
+}
+
+// handleDeliverSM ставит отчет о доставке в очередь и возвращает command_status для deliver_sm_resp.
+// При полной очереди отвечает ESME_RTHROTTLED, чтобы SMSC повторил отчет позже, а не потерял его
+func (s *SMPPSender) handleDeliverSM(pdu *smppPDU) uint32 {
+	msg, err := parseSMPPShortMessage(pdu.Body)
+	if err != nil {
+		log.Printf("SMPP failed to parse deliver_sm: %v", err)
+		return smppStatusOK
+	}
+
+	if msg.ESMClass&smppESMReceipt == 0 {
+		log.Printf("SMPP ignoring mobile originated message from %s", msg.SourceAddr)
+		return smppStatusOK
+	}
+
+	receipt := parseSMPPReceipt(msg)
+	log.Printf("SMPP delivery receipt: message_id=%s stat=%s err=%s",
+		receipt.MessageID, receipt.Stat, receipt.ErrorCode)
+
+	select {
+	case s.receipts <- receipt:
+		return smppStatusOK
+	default:
+		log.Printf("SMPP receipt queue is full, throttling SMSC: message_id=%s", receipt.MessageID)
+		return smppStatusThrottled
+	}
+}
+
+// deliverReceipts передает отчеты из очереди обработчику
+func (s *SMPPSender) deliverReceipts() {
+	for receipt := range s.receipts {
+		s.mu.RLock()
+		handler := s.onReceipt
+		s.mu.RUnlock()
+
+		if handler != nil {
+			handler(receipt)
+		}
+	}
+}
+
+// parseSMPPReceipt разбирает отчет о доставке: TLV имеют приоритет над текстом
+// "id:IIII sub:SSS dlvrd:DDD submit date:... done date:... stat:DDDDDDD err:E text:..."
+func parseSMPPReceipt(msg *smppShortMessage) DeliveryReceipt {
+	fields := make(map[string]string)
+	for _, part := range strings.Fields(string(msg.ShortMessage)) {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			fields[strings.ToLower(key)] = value
+		}
+	}
+
+	receipt := DeliveryReceipt{
+		ReceivedAt: time.Now(),
+		MessageID:  fields["id"],
+		Stat:       strings.ToUpper(fields["stat"]),
+		ErrorCode:  fields["err"],
+	}
+
+	if id, ok := msg.TLVs[smppTagReceiptedMessageID]; ok {
+		receipt.MessageID = string(bytes.TrimRight(id, "\x00"))
+	}
+	if state, ok := msg.TLVs[smppTagMessageState]; ok && len(state) == 1 {
+		receipt.Stat = smppMessageStates[state[0]]
+	}
+
+	status, ok := domain.ParseDeliveryStatus(receipt.Stat)
+	if !ok {
+		status = domain.DeliveryStatusFailed
+	}
+	receipt.Status = status
+
+	return receipt
+}
+
+// smppMessageStates - значения TLV message_state в текстовом виде
+var smppMessageStates = map[byte]string{
+	1: "ENROUTE",
+	2: "DELIVRD",
+	3: "EXPIRED",
+	4: "DELETED",
+	5: "UNDELIV",
+	6: "ACCEPTD",
+	7: "UNKNOWN",
+	8: "REJECTD",
+}
+
+// smppConn - одно TCP соединение с SMSC и сопоставление ответов по sequence_number
+type smppConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	seq     atomic.Uint32
+
+	mu      sync.Mutex
+	pending map[uint32]chan *smppPDU
+
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+func newSMPPConn(conn net.Conn, inbound func(*smppConn, *smppPDU)) *smppConn {
+	c := &smppConn{
+		conn:    conn,
+		pending: make(map[uint32]chan *smppPDU),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(inbound)
+	return c
+}
+
+func (c *smppConn) readLoop(inbound func(*smppConn, *smppPDU)) {
+	for {
+		pdu, err := readSMPPPDU(c.conn)
+		if err != nil {
+			c.close(fmt.Errorf("read failed: %w", err))
+			return
+		}
+
+		if !pdu.isResponse() {
+			inbound(c, pdu)
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[pdu.Sequence]
+		delete(c.pending, pdu.Sequence)
+		c.mu.Unlock()
+
+		if ok {
+			ch <- pdu
+		}
+	}
+}
+
+func (c *smppConn) write(pdu *smppPDU) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(pdu.marshal())
+	return err
+}
+
+// request отправляет PDU и ждет ответ с тем же sequence_number
+func (c *smppConn) request(ctx context.Context, commandID uint32, body []byte, timeout time.Duration) (*smppPDU, error) {
+	seq := c.seq.Add(1)
+	ch := make(chan *smppPDU, 1)
+
+	c.mu.Lock()
+	c.pending[seq] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(&smppPDU{CommandID: commandID, Sequence: seq, Body: body}); err != nil {
+		c.close(fmt.Errorf("write failed: %w", err))
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		if resp.CommandID == smppGenericNack {
+			return nil, fmt.Errorf("generic_nack: command_status=0x%08X", resp.Status)
+		}
+		return resp, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("response timeout for command 0x%08X", commandID)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+func (c *smppConn) respond(req *smppPDU, commandID, status uint32, body []byte) {
+	if err := c.write(&smppPDU{CommandID: commandID, Status: status, Sequence: req.Sequence, Body: body}); err != nil {
+		c.close(fmt.Errorf("write failed: %w", err))
+	}
+}
+
+func (c *smppConn) close(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"net"
+	"sms-service/internal/domain"
+	"testing"
+	"time"
+)
+
+// fakeSMSC принимает одно соединение и проигрывает сценарий теста
+type fakeSMSC struct {
+	listener net.Listener
+	conns    chan net.Conn
+}
+
+func newFakeSMSC(t *testing.T) *fakeSMSC {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	smsc := &fakeSMSC{listener: listener, conns: make(chan net.Conn, 1)}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		smsc.conns <- conn
+	}()
+	return smsc
+}
+
+func (f *fakeSMSC) accept(t *testing.T) net.Conn {
+	t.Helper()
+
+	select {
+	case conn := <-f.conns:
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("sender did not connect to SMSC")
+		return nil
+	}
+}
+
+func expectPDU(t *testing.T, conn net.Conn, commandID uint32) *smppPDU {
+	t.Helper()
+
+	pdu, err := readSMPPPDU(conn)
+	if err != nil {
+		t.Fatalf("SMSC failed to read PDU: %v", err)
+	}
+	if pdu.CommandID != commandID {
+		t.Fatalf("SMSC got command 0x%08X, want 0x%08X", pdu.CommandID, commandID)
+	}
+	return pdu
+}
+
+func writePDU(t *testing.T, conn net.Conn, pdu *smppPDU) {
+	t.Helper()
+
+	if _, err := conn.Write(pdu.marshal()); err != nil {
+		t.Fatalf("SMSC failed to write PDU: %v", err)
+	}
+}
+
+func TestSMPPSenderBindSubmitDeliver(t *testing.T) {
+	smsc := newFakeSMSC(t)
+
+	t.Setenv("SMPP_ADDR", smsc.listener.Addr().String())
+	t.Setenv("SMPP_SYSTEM_ID", "test")
+	t.Setenv("SMPP_PASSWORD", "secret")
+	t.Setenv("SMS_SENDER_NAME", "Service")
+
+	sender, err := NewSMPPSender("")
+	if err != nil {
+		t.Fatalf("NewSMPPSender: %v", err)
+	}
+
+	receipts := make(chan DeliveryReceipt, 1)
+	sender.SetReceiptHandler(func(receipt DeliveryReceipt) {
+		receipts <- receipt
+	})
+
+	conn := smsc.accept(t)
+
+	bind := expectPDU(t, conn, smppBindTransceiver)
+	r := &smppReader{data: bind.Body}
+	if systemID, password := r.cstring(), r.cstring(); systemID != "test" || password != "secret" {
+		t.Errorf("bind credentials = %q/%q", systemID, password)
+	}
+	writePDU(t, conn, &smppPDU{CommandID: smppBindTransceiverResp, Sequence: bind.Sequence, Body: []byte("smsc\x00")})
+
+	type sendResult struct {
+		result *SMSResult
+		err    error
+	}
+	sent := make(chan sendResult, 1)
+	go func() {
+		// Send до завершения bind вернет ошибку, поэтому ждем, пока сессия поднимется
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			result, err := sender.Send(context.Background(), "+79991234567", "Code 1234")
+			if err == nil || time.Now().After(deadline) {
+				sent <- sendResult{result, err}
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	submit := expectPDU(t, conn, smppSubmitSM)
+	msg, err := parseSMPPShortMessage(submit.Body)
+	if err != nil {
+		t.Fatalf("failed to parse submit_sm: %v", err)
+	}
+	if msg.DestAddr != "79991234567" || string(msg.ShortMessage) != "Code 1234" {
+		t.Errorf("submit_sm to %q: %q", msg.DestAddr, msg.ShortMessage)
+	}
+	writePDU(t, conn, &smppPDU{CommandID: smppSubmitSMResp, Sequence: submit.Sequence, Body: []byte("msg-1\x00")})
+
+	result := <-sent
+	if result.err != nil {
+		t.Fatalf("Send: %v", result.err)
+	}
+	if result.result.MessageID != "msg-1" {
+		t.Errorf("message ID = %q, want msg-1", result.result.MessageID)
+	}
+
+	writePDU(t, conn, &smppPDU{
+		CommandID: smppDeliverSM,
+		Sequence:  100,
+		Body:      deliverSMBody(smppESMReceipt, "id:msg-1 stat:DELIVRD err:000", nil),
+	})
+	deliverResp := expectPDU(t, conn, smppDeliverSMResp)
+	if deliverResp.Sequence != 100 || deliverResp.Status != smppStatusOK {
+		t.Errorf("deliver_sm_resp: sequence=%d status=0x%08X", deliverResp.Sequence, deliverResp.Status)
+	}
+
+	select {
+	case receipt := <-receipts:
+		if receipt.MessageID != "msg-1" || receipt.Status != domain.DeliveryStatusDelivered {
+			t.Errorf("receipt = %+v", receipt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("receipt was not delivered to the handler")
+	}
+
+	// Неизвестная команда (data_sm) отклоняется generic_nack с ESME_RINVCMDID
+	writePDU(t, conn, &smppPDU{CommandID: 0x00000103, Sequence: 101})
+	nack := expectPDU(t, conn, smppGenericNack)
+	if nack.Sequence != 101 || nack.Status != smppStatusInvalidCommandID {
+		t.Errorf("generic_nack: sequence=%d status=0x%08X", nack.Sequence, nack.Status)
+	}
+}
+
+func TestSMPPSenderThrottlesWhenReceiptQueueIsFull(t *testing.T) {
+	sender := &SMPPSender{receipts: make(chan DeliveryReceipt, 1)}
+	pdu := &smppPDU{CommandID: smppDeliverSM, Body: deliverSMBody(smppESMReceipt, "id:msg-1 stat:DELIVRD", nil)}
+
+	if status := sender.handleDeliverSM(pdu); status != smppStatusOK {
+		t.Fatalf("first receipt: status=0x%08X", status)
+	}
+	if status := sender.handleDeliverSM(pdu); status != smppStatusThrottled {
+		t.Errorf("receipt over capacity: status=0x%08X, want 0x%08X", status, smppStatusThrottled)
+	}
+}
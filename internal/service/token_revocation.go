@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenRevocations - локальный список отзыва access token'ов, который проверяет VerifyToken.
+// Вход выполняется через token exchange без сессии Zitadel, поэтому удаление сессий
+// не затрагивает выданные токены, а локально проверенный JWT действует до exp.
+// Отзыв через этот сервис (logout, logout-everywhere) поэтому решается здесь, а не в Zitadel
+type TokenRevocations interface {
+	// RevokeToken отзывает один токен до момента until (обычно его exp; нулевой - на TOKEN_REVOCATION_TTL)
+	RevokeToken(ctx context.Context, tokenHash string, until time.Time) error
+	// RevokeSubject отзывает все токены пользователя, выданные не позже at
+	RevokeSubject(ctx context.Context, subject string, at time.Time) error
+	// IsRevoked сообщает, отозван ли токен сам по себе или вместе со всеми токенами пользователя
+	IsRevoked(ctx context.Context, tokenHash, subject string, issuedAt time.Time) (bool, error)
+}
+
+// NewTokenRevocations создает список согласно TOKEN_REVOCATION_STORE: memory (по умолчанию), redis.
+// Отметка отзыва пользователя хранится TOKEN_REVOCATION_TTL (по умолчанию 12h - срок access token'а
+// в Zitadel по умолчанию); он не должен быть меньше срока жизни access token'ов.
+// Список в памяти действует только на своей реплике, для нескольких реплик нужен redis
+func NewTokenRevocations() (TokenRevocations, error) {
+	ttl, err := durationFromEnv("TOKEN_REVOCATION_TTL", 12*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := os.Getenv("TOKEN_REVOCATION_STORE")
+
+	switch backend {
+	case "", "memory":
+		log.Printf("Using in-memory token revocation list (ttl=%s)", ttl)
+		return NewMemoryTokenRevocations(ttl), nil
+	case "redis":
+		client, err := NewRedisClient()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using Redis token revocation list (ttl=%s)", ttl)
+		return NewRedisTokenRevocations(client, ttl), nil
+	default:
+		return nil, fmt.Errorf("unknown TOKEN_REVOCATION_STORE: %s", backend)
+	}
+}
+
+// subjectRevoked - выдан ли токен не позже отзыва пользователя. iat хранится в секундах,
+// поэтому токен, выданный в ту же секунду, что и отзыв, тоже считается отозванным
+func subjectRevoked(revokedAt, issuedAt time.Time) bool {
+	return !issuedAt.After(revokedAt)
+}
+
+// MemoryTokenRevocations хранит отметки отзыва в памяти процесса
+type MemoryTokenRevocations struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	tokens   map[string]time.Time // хеш токена -> до какого момента хранить
+	subjects map[string]time.Time // пользователь -> момент отзыва
+}
+
+func NewMemoryTokenRevocations(ttl time.Duration) *MemoryTokenRevocations {
+	revocations := &MemoryTokenRevocations{
+		ttl:      ttl,
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]time.Time),
+	}
+
+	go revocations.cleanup()
+
+	return revocations
+}
+
+func (r *MemoryTokenRevocations) RevokeToken(ctx context.Context, tokenHash string, until time.Time) error {
+	if until.IsZero() {
+		until = time.Now().Add(r.ttl)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[tokenHash] = until
+	return nil
+}
+
+func (r *MemoryTokenRevocations) RevokeSubject(ctx context.Context, subject string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if at.After(r.subjects[subject]) {
+		r.subjects[subject] = at
+	}
+	return nil
+}
+
+func (r *MemoryTokenRevocations) IsRevoked(ctx context.Context, tokenHash, subject string, issuedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if until, ok := r.tokens[tokenHash]; ok && now.Before(until) {
+		return true, nil
+	}
+	if revokedAt, ok := r.subjects[subject]; ok && now.Before(revokedAt.Add(r.ttl)) {
+		return subjectRevoked(revokedAt, issuedAt), nil
+	}
+	return false, nil
+}
+
+func (r *MemoryTokenRevocations) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.mu.Lock()
+		now := time.Now()
+		for hash, until := range r.tokens {
+			if now.After(until) {
+				delete(r.tokens, hash)
+			}
+		}
+		for subject, revokedAt := range r.subjects {
+			if now.After(revokedAt.Add(r.ttl)) {
+				delete(r.subjects, subject)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+// RedisTokenRevocations хранит отметки в Redis, чтобы отзыв действовал на всех репликах.
+// Ключи: revoked:token:<hash> - отозванный токен, revoked:subject:<sub> - момент отзыва пользователя (мс)
+type RedisTokenRevocations struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// revokeSubjectScript сохраняет момент отзыва, не сдвигая его назад
+// KEYS[1] - отметка пользователя, ARGV[1] - момент отзыва (мс), ARGV[2] - TTL (мс)
+var revokeSubjectScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) > current then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return 1
+`)
+
+func NewRedisTokenRevocations(client *redis.Client, ttl time.Duration) *RedisTokenRevocations {
+	return &RedisTokenRevocations{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func revokedTokenKey(tokenHash string) string {
+	return "revoked:token:" + tokenHash
+}
+
+func revokedSubjectKey(subject string) string {
+	return "revoked:subject:" + subject
+}
+
+func (r *RedisTokenRevocations) RevokeToken(ctx context.Context, tokenHash string, until time.Time) error {
+	ttl := r.ttl
+	if !until.IsZero() {
+		ttl = time.Until(until)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.client.Set(ctx, revokedTokenKey(tokenHash), "1", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token in redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisTokenRevocations) RevokeSubject(ctx context.Context, subject string, at time.Time) error {
+	keys := []string{revokedSubjectKey(subject)}
+	if err := revokeSubjectScript.Run(ctx, r.client, keys, at.UnixMilli(), r.ttl.Milliseconds()).Err(); err != nil {
+		return fmt.Errorf("failed to revoke tokens of %s in redis: %w", subject, err)
+	}
+	return nil
+}
+
+func (r *RedisTokenRevocations) IsRevoked(ctx context.Context, tokenHash, subject string, issuedAt time.Time) (bool, error) {
+	pipe := r.client.Pipeline()
+	tokenCmd := pipe.Exists(ctx, revokedTokenKey(tokenHash))
+	subjectCmd := pipe.Get(ctx, revokedSubjectKey(subject))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return false, fmt.Errorf("failed to read token revocations from redis: %w", err)
+	}
+
+	if tokenCmd.Val() == 1 {
+		return true, nil
+	}
+
+	raw, err := subjectCmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read token revocations from redis: %w", err)
+	}
+	revokedAt, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid revocation mark for %s: %w", subject, err)
+	}
+	return subjectRevoked(time.UnixMilli(revokedAt), issuedAt), nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sms-service/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader - заголовок, в котором Zitadel передает подпись вызова target
+const WebhookSignatureHeader = "ZITADEL-Signature"
+
+// WebhookVerifier проверяет подписи вызовов Actions V2.
+// Zitadel подписывает тело ключом target'а: "t=<unix>,v1=<hex(HMAC-SHA256(key, "<t>.<body>"))>"
+type WebhookVerifier struct {
+	keys      map[string][][]byte // target -> активные ключи (несколько на время ротации)
+	tolerance time.Duration
+	insecure  bool // ZITADEL_WEBHOOK_INSECURE=true: target'ы без ключей принимают неподписанные вызовы
+}
+
+// NewWebhookVerifier читает ключи из ZITADEL_SIGNING_KEYS и допуск по времени из ZITADEL_SIGNATURE_TOLERANCE.
+// Формат ключей: "pre-registration=key1,key2;post-create-user=key3".
+// Вызовы target'ов без ключей отклоняются, если явно не задан ZITADEL_WEBHOOK_INSECURE=true (только для разработки)
+func NewWebhookVerifier() (*WebhookVerifier, error) {
+	keys := make(map[string][][]byte)
+
+	for _, entry := range splitList(os.Getenv("ZITADEL_SIGNING_KEYS"), ";") {
+		target, list, found := strings.Cut(entry, "=")
+		target = strings.TrimSpace(target)
+		if !found || target == "" {
+			return nil, fmt.Errorf("invalid ZITADEL_SIGNING_KEYS entry: expected target=key1,key2")
+		}
+
+		for _, key := range splitList(list, ",") {
+			keys[target] = append(keys[target], []byte(key))
+		}
+		if len(keys[target]) == 0 {
+			return nil, fmt.Errorf("no signing keys configured for target %s", target)
+		}
+	}
+
+	tolerance, err := durationFromEnv("ZITADEL_SIGNATURE_TOLERANCE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookVerifier{
+		keys:      keys,
+		tolerance: tolerance,
+		insecure:  os.Getenv("ZITADEL_WEBHOOK_INSECURE") == "true",
+	}, nil
+}
+
+// HasKeys - для target настроены ключи подписи
+func (v *WebhookVerifier) HasKeys(target string) bool {
+	return len(v.keys[target]) > 0
+}
+
+// RequireTargets проверяет при старте, что для всех target'ов есть ключи.
+// С ZITADEL_WEBHOOK_INSECURE=true недостающие ключи только логируются
+func (v *WebhookVerifier) RequireTargets(targets ...string) error {
+	var missing []string
+	for _, target := range targets {
+		if !v.HasKeys(target) {
+			missing = append(missing, target)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if v.insecure {
+		log.Printf("Warning: ZITADEL_WEBHOOK_INSECURE=true, unsigned calls accepted for targets: %s", strings.Join(missing, ", "))
+		return nil
+	}
+	return fmt.Errorf("no signing keys in ZITADEL_SIGNING_KEYS for targets: %s (set ZITADEL_WEBHOOK_INSECURE=true to accept unsigned calls in development)", strings.Join(missing, ", "))
+}
+
+// Verify проверяет заголовок подписи для тела body, полученного target'ом
+func (v *WebhookVerifier) Verify(target, header string, body []byte, now time.Time) error {
+	if !v.HasKeys(target) {
+		if v.insecure {
+			return nil
+		}
+		log.Printf("No signing keys for target %s, rejecting call", target)
+		return domain.ErrSignatureInvalid
+	}
+
+	if header == "" {
+		return domain.ErrSignatureMissing
+	}
+
+	var (
+		timestamp  string
+		signatures [][]byte
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			timestamp = value
+		case "v1":
+			if signature, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	if timestamp == "" || len(signatures) == 0 {
+		return domain.ErrSignatureInvalid
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return domain.ErrSignatureInvalid
+	}
+
+	// Защита от повтора: подпись действительна только в окне tolerance
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > v.tolerance || skew < -v.tolerance {
+		return domain.ErrSignatureExpired
+	}
+
+	for _, key := range v.keys[target] {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte("."))
+		mac.Write(body)
+		expected := mac.Sum(nil)
+
+		for _, signature := range signatures {
+			if hmac.Equal(signature, expected) {
+				return nil
+			}
+		}
+	}
+
+	log.Printf("Webhook signature mismatch for target %s", target)
+	return domain.ErrSignatureInvalid
+}
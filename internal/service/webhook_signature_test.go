@@ -0,0 +1,106 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sms-service/internal/domain"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signWebhook подписывает тело так же, как Zitadel: "t=<unix>,v1=<hex(HMAC(key, "<t>.<body>"))>"
+func signWebhook(key string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookVerifierVerify(t *testing.T) {
+	t.Setenv("ZITADEL_SIGNING_KEYS", "pre-registration=old-key,new-key;post-create-user=other-key")
+	t.Setenv("ZITADEL_SIGNATURE_TOLERANCE", "5m")
+	t.Setenv("ZITADEL_WEBHOOK_INSECURE", "")
+
+	verifier, err := NewWebhookVerifier()
+	if err != nil {
+		t.Fatalf("NewWebhookVerifier: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"userId":"42"}`)
+	valid := signWebhook("new-key", now, body)
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"valid", "pre-registration", valid, body, nil},
+		{"previous key during rotation", "pre-registration", signWebhook("old-key", now, body), body, nil},
+		{"one of several signatures", "pre-registration", valid + ",v1=00ff", body, nil},
+		{"key of another target", "pre-registration", signWebhook("other-key", now, body), body, domain.ErrSignatureInvalid},
+		{"wrong key", "pre-registration", signWebhook("unknown", now, body), body, domain.ErrSignatureInvalid},
+		{"tampered body", "pre-registration", valid, []byte(`{"userId":"43"}`), domain.ErrSignatureInvalid},
+		{"too old", "pre-registration", signWebhook("new-key", now.Add(-6*time.Minute), body), body, domain.ErrSignatureExpired},
+		{"too far in the future", "pre-registration", signWebhook("new-key", now.Add(6*time.Minute), body), body, domain.ErrSignatureExpired},
+		{"within tolerance", "pre-registration", signWebhook("new-key", now.Add(-4*time.Minute), body), body, nil},
+		{"missing header", "pre-registration", "", body, domain.ErrSignatureMissing},
+		{"no timestamp", "pre-registration", "v1=00ff", body, domain.ErrSignatureInvalid},
+		{"no signature", "pre-registration", "t=1700000000", body, domain.ErrSignatureInvalid},
+		{"non-hex signature", "pre-registration", "t=1700000000,v1=zz", body, domain.ErrSignatureInvalid},
+		{"non-numeric timestamp", "pre-registration", "t=now,v1=00ff", body, domain.ErrSignatureInvalid},
+		{"garbage", "pre-registration", "garbage", body, domain.ErrSignatureInvalid},
+		{"target without keys", "unknown-target", valid, body, domain.ErrSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifier.Verify(tt.target, tt.header, tt.body, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify: got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifierWithoutKeys(t *testing.T) {
+	t.Setenv("ZITADEL_SIGNING_KEYS", "")
+
+	t.Setenv("ZITADEL_WEBHOOK_INSECURE", "")
+	verifier, err := NewWebhookVerifier()
+	if err != nil {
+		t.Fatalf("NewWebhookVerifier: %v", err)
+	}
+	if err := verifier.Verify("pre-registration", "", nil, time.Now()); !errors.Is(err, domain.ErrSignatureInvalid) {
+		t.Errorf("Verify without keys: got %v, want %v", err, domain.ErrSignatureInvalid)
+	}
+	if err := verifier.RequireTargets("pre-registration"); err == nil {
+		t.Error("RequireTargets without keys: expected error")
+	}
+
+	t.Setenv("ZITADEL_WEBHOOK_INSECURE", "true")
+	verifier, err = NewWebhookVerifier()
+	if err != nil {
+		t.Fatalf("NewWebhookVerifier: %v", err)
+	}
+	if err := verifier.Verify("pre-registration", "", nil, time.Now()); err != nil {
+		t.Errorf("Verify with ZITADEL_WEBHOOK_INSECURE: %v", err)
+	}
+	if err := verifier.RequireTargets("pre-registration"); err != nil {
+		t.Errorf("RequireTargets with ZITADEL_WEBHOOK_INSECURE: %v", err)
+	}
+}
+
+func TestNewWebhookVerifierRejectsInvalidKeys(t *testing.T) {
+	for _, raw := range []string{"pre-registration", "=key", "pre-registration= , "} {
+		t.Setenv("ZITADEL_SIGNING_KEYS", raw)
+		if _, err := NewWebhookVerifier(); err == nil {
+			t.Errorf("ZITADEL_SIGNING_KEYS=%q: expected error", raw)
+		}
+	}
+}